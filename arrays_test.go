@@ -3137,3 +3137,281 @@ func Test_Dec_Array_ValueIndirection(t *testing.T) {
 		assert.Equal(expect, actual)
 	})
 }
+
+func Test_DecWithFormat_Array_Length(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     interface{}
+		format    Format
+		expect    [3]int
+		expectErr bool
+	}{
+		{name: "default shorter slice", input: []int{1, 2}, expectErr: true},
+		{name: "default longer slice", input: []int{1, 2, 3, 4}, expectErr: true},
+		{name: "pad shorter slice", input: []int{1, 2}, format: Format{ArrayLength: ArrayPad}, expect: [3]int{1, 2, 0}},
+		{name: "pad longer slice", input: []int{1, 2, 3, 4}, format: Format{ArrayLength: ArrayPad}, expectErr: true},
+		{name: "pad empty slice", input: []int{}, format: Format{ArrayLength: ArrayPad}, expect: [3]int{}},
+		{name: "exact slice", input: []int{1, 2, 3}, format: Format{ArrayLength: ArrayExact}, expect: [3]int{1, 2, 3}},
+		{name: "exact shorter slice", input: []int{1, 2}, format: Format{ArrayLength: ArrayExact}, expectErr: true},
+		{name: "exact longer slice", input: []int{1, 2, 3, 4}, format: Format{ArrayLength: ArrayExact}, expectErr: true},
+		{name: "exact nil slice", input: []int(nil), format: Format{ArrayLength: ArrayExact}, expectErr: true},
+		{name: "truncate shorter slice", input: []int{1, 2}, format: Format{ArrayLength: ArrayTruncate}, expect: [3]int{1, 2, 0}},
+		{name: "truncate longer slice", input: []int{1, 2, 3, 4}, format: Format{ArrayLength: ArrayTruncate}, expect: [3]int{1, 2, 3}},
+		{name: "truncate longer array", input: [5]int{1, 2, 3, 4, 5}, format: Format{ArrayLength: ArrayTruncate}, expect: [3]int{1, 2, 3}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			input := MustEnc(tc.input)
+
+			var actual [3]int
+			n, err := DecWithFormat(input, &actual, &tc.format)
+
+			if tc.expectErr {
+				assert.ErrorIs(err, ErrArrayLength)
+				assert.ErrorIs(err, ErrMalformedData)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(len(input), n)
+			assert.Equal(tc.expect, actual)
+		})
+	}
+
+	t.Run("truncate packed", func(t *testing.T) {
+		assert := assert.New(t)
+		input, err := EncWithFormat([]int{1, 2, 3, 4}, &Format{Packing: PackVarint})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual [3]int
+		n, err := DecWithFormat(input, &actual, &Format{ArrayLength: ArrayTruncate})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(input), n)
+		assert.Equal([3]int{1, 2, 3}, actual)
+	})
+
+	t.Run("exact packed", func(t *testing.T) {
+		assert := assert.New(t)
+		input, err := EncWithFormat([]int{1, 2}, &Format{Packing: PackVarint})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual [3]int
+		_, err = DecWithFormat(input, &actual, &Format{ArrayLength: ArrayExact})
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("truncate raw bytes", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc([]byte{1, 2, 3, 4})
+
+		var actual [3]byte
+		n, err := DecWithFormat(input, &actual, &Format{ArrayLength: ArrayTruncate})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(input), n)
+		assert.Equal([3]byte{1, 2, 3}, actual)
+	})
+
+	t.Run("array to slice", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc([2]string{"a", "b"})
+
+		var actual []string
+		_, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal([]string{"a", "b"}, actual)
+	})
+}
+
+func Test_EncWithFormat_Array_ArrayLengths(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  interface{}
+		format Format
+		expect []byte
+	}{
+		{
+			name:  "[3]int",
+			input: [3]int{1, 2, 3},
+			expect: []byte{
+				0x41, 0x10, 0x08, // len=8, array length given
+
+				0x01, 0x03, // array length 3
+
+				0x01, 0x01,
+				0x01, 0x02,
+				0x01, 0x03,
+			},
+		},
+		{
+			name:  "[0]int",
+			input: [0]int{},
+			expect: []byte{
+				0x41, 0x10, 0x01, // len=1, array length given
+
+				0x00, // array length 0
+			},
+		},
+		{
+			name:  "[2]byte",
+			input: [2]byte{0x0a, 0x0b},
+			expect: []byte{
+				0x41, 0x12, 0x04, // len=4, raw bytes, array length given
+
+				0x01, 0x02, // array length 2
+
+				0x0a, 0x0b,
+			},
+		},
+		{
+			name:   "packed [3]int",
+			input:  [3]int{1, 2, 3},
+			format: Format{Packing: PackVarint},
+			expect: []byte{
+				0x41, 0x13, 0x06, // len=6, packed, array length given
+
+				0x01, 0x03, // array length 3
+
+				0xc2, // packing byte
+
+				0x02, 0x04, 0x06,
+			},
+		},
+		{
+			name:   "slice is unchanged",
+			input:  []int{1, 2},
+			expect: []byte{0x01, 0x04, 0x01, 0x01, 0x01, 0x02},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			tc.format.ArrayLengths = true
+			actual, err := EncWithFormat(tc.input, &tc.format)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_DecWithFormat_Array_ArrayLengths(t *testing.T) {
+	recorded := []byte{
+		0x41, 0x10, 0x08, // len=8, array length given
+
+		0x01, 0x03, // array length 3
+
+		0x01, 0x01,
+		0x01, 0x02,
+		0x01, 0x03,
+	}
+
+	t.Run("to same length array", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual [3]int
+		n, err := Dec(recorded, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(recorded), n)
+		assert.Equal([3]int{1, 2, 3}, actual)
+	})
+
+	t.Run("to slice", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual []int
+		n, err := Dec(recorded, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(recorded), n)
+		assert.Equal([]int{1, 2, 3}, actual)
+	})
+
+	policies := []ArrayLengthPolicy{ArrayExact, ArrayPad, ArrayTruncate}
+	for _, policy := range policies {
+		t.Run("to shorter array with "+policy.String(), func(t *testing.T) {
+			assert := assert.New(t)
+
+			var actual [2]int
+			_, err := DecWithFormat(recorded, &actual, &Format{ArrayLength: policy})
+
+			assert.ErrorIs(err, ErrArrayLength)
+		})
+
+		t.Run("to longer array with "+policy.String(), func(t *testing.T) {
+			assert := assert.New(t)
+
+			var actual [4]int
+			_, err := DecWithFormat(recorded, &actual, &Format{ArrayLength: policy})
+
+			assert.ErrorIs(err, ErrArrayLength)
+		})
+	}
+
+	t.Run("packed round trip", func(t *testing.T) {
+		assert := assert.New(t)
+		input, err := EncWithFormat([3]uint16{7, 8, 9}, &Format{ArrayLengths: true, Packing: PackVarint})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual [3]uint16
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(input), n)
+		assert.Equal([3]uint16{7, 8, 9}, actual)
+	})
+
+	t.Run("raw bytes round trip", func(t *testing.T) {
+		assert := assert.New(t)
+		input, err := EncWithFormat([2]byte{0x0a, 0x0b}, &Format{ArrayLengths: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual [2]byte
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(input), n)
+		assert.Equal([2]byte{0x0a, 0x0b}, actual)
+	})
+}
+
+func Test_ArrayLengthPolicy_String(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("exact", ArrayExact.String())
+	assert.Equal("pad", ArrayPad.String())
+	assert.Equal("truncate", ArrayTruncate.String())
+	assert.Equal("ArrayLengthPolicy(5)", ArrayLengthPolicy(5).String())
+}
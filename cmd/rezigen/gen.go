@@ -0,0 +1,598 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// fieldKind is the way that a field is encoded by generated code.
+type fieldKind int
+
+const (
+	// kindOther fields are encoded by calling the Enc and Dec methods of
+	// rezi.Writer and rezi.Reader.
+	kindOther fieldKind = iota
+	kindString
+	kindBool
+	kindInt
+	kindUint
+	kindFloat
+)
+
+// builtinKinds gives the fieldKind of each built-in type that is encoded
+// directly.
+var builtinKinds = map[string]fieldKind{
+	"string":  kindString,
+	"bool":    kindBool,
+	"int":     kindInt,
+	"int8":    kindInt,
+	"int16":   kindInt,
+	"int32":   kindInt,
+	"int64":   kindInt,
+	"rune":    kindInt,
+	"uint":    kindUint,
+	"uint8":   kindUint,
+	"uint16":  kindUint,
+	"uint32":  kindUint,
+	"uint64":  kindUint,
+	"byte":    kindUint,
+	"float32": kindFloat,
+	"float64": kindFloat,
+}
+
+// field is a single encoded field of a struct.
+type field struct {
+	// name is the name of the field, which is also the name it is encoded
+	// with.
+	name string
+
+	kind fieldKind
+
+	// goType is the name of the built-in type of the field. It is only set
+	// if kind is not kindOther.
+	goType string
+
+	// embeds are the embedded struct fields that the field is promoted
+	// through, outermost first. It is empty for a field declared in the
+	// struct itself.
+	embeds []embed
+}
+
+// embed is an embedded struct field whose fields are promoted.
+type embed struct {
+	name string
+
+	// typeName is the name of the struct type of the field, or of the type it
+	// points to if ptr is set.
+	typeName string
+	ptr      bool
+}
+
+// marshalMethods are the methods that make REZI encode a value of a struct type
+// without looking at its fields. An embedded struct with any of them is
+// encoded as a single field rather than having its fields promoted.
+var marshalMethods = []string{"MarshalREZI", "MarshalText", "MarshalBinary"}
+
+// structType is a struct type that methods are generated for.
+type structType struct {
+	name string
+
+	// fields holds all encoded fields, including those promoted from embedded
+	// structs, sorted by name.
+	fields []field
+}
+
+// generate parses the given files and returns formatted Go source that
+// declares REZI marshaling methods for each of the named types.
+func generate(files []string, typeNames []string) ([]byte, error) {
+	fset := token.NewFileSet()
+
+	var pkgName string
+	specs := map[string]*ast.TypeSpec{}
+	methods := map[string]map[string]bool{}
+
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if pkgName == "" {
+			pkgName = f.Name.Name
+		} else if pkgName != f.Name.Name {
+			return nil, fmt.Errorf("%s: package %s does not match package %s of other files", file, f.Name.Name, pkgName)
+		}
+
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch decl := n.(type) {
+			case *ast.TypeSpec:
+				specs[decl.Name.Name] = decl
+			case *ast.FuncDecl:
+				if decl.Recv != nil && len(decl.Recv.List) == 1 {
+					if recv := typeIdent(decl.Recv.List[0].Type); recv != nil {
+						if methods[recv.Name] == nil {
+							methods[recv.Name] = map[string]bool{}
+						}
+						methods[recv.Name][decl.Name.Name] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	// the types being generated will have MarshalREZI once the generated
+	// code is added.
+	for _, name := range typeNames {
+		if methods[name] == nil {
+			methods[name] = map[string]bool{}
+		}
+		methods[name]["MarshalREZI"] = true
+	}
+
+	var types []structType
+	for _, name := range typeNames {
+		ts, ok := specs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s is not declared in the given files", name)
+		}
+		st, err := analyzeType(ts, specs, methods)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, st)
+	}
+
+	g := &generator{pkgName: pkgName}
+	if pkgName != reziPackageName {
+		g.qual = reziPackageName + "."
+	}
+
+	return g.generate(types)
+}
+
+// analyzeType gets the encoded fields of the struct type declared by ts. specs
+// holds every type declared in the parsed files, by name; a type declared with
+// one of them as its underlying type, such as `type T U`, has the fields of the
+// struct type that it resolves to. methods holds the names of the methods
+// declared on each type.
+//
+// The exported fields of embedded structs are promoted with the same rules
+// that REZI uses: when more than one field has the same name, the one that is
+// least deeply nested is used, and if there is more than one at that depth,
+// none of them are.
+func analyzeType(ts *ast.TypeSpec, specs map[string]*ast.TypeSpec, methods map[string]map[string]bool) (structType, error) {
+	st := structType{name: ts.Name.Name}
+
+	if ts.TypeParams != nil && len(ts.TypeParams.List) > 0 {
+		return st, fmt.Errorf("type %s: generic types are not supported", st.name)
+	}
+
+	structExpr := resolveStruct(ts, specs)
+	if structExpr == nil {
+		return st, fmt.Errorf("type %s is not a struct type", st.name)
+	}
+
+	type embeddedStruct struct {
+		name   string
+		expr   *ast.StructType
+		embeds []embed
+	}
+
+	// walk the embedded structs one depth at a time so that shallower fields
+	// are found first.
+	var candidates []field
+	next := []embeddedStruct{{name: st.name, expr: structExpr}}
+	visited := map[string]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+
+		// a struct embedded more than once at the same depth is walked each
+		// time, so that its fields shadow each other.
+		level := map[string]bool{}
+		for _, es := range current {
+			if visited[es.name] {
+				continue
+			}
+			level[es.name] = true
+
+			for _, f := range es.expr.Fields.List {
+				if f.Tag != nil {
+					tag, err := strconv.Unquote(f.Tag.Value)
+					if err != nil {
+						return st, fmt.Errorf("type %s: read field tag: %w", st.name, err)
+					}
+					if _, hasRezi := reflect.StructTag(tag).Lookup("rezi"); hasRezi {
+						return st, fmt.Errorf("type %s: fields with rezi tags are not supported", st.name)
+					}
+				}
+
+				kind := kindOther
+				var goType string
+				if ident, ok := f.Type.(*ast.Ident); ok {
+					if k, builtin := builtinKinds[ident.Name]; builtin {
+						kind = k
+						goType = ident.Name
+					}
+				}
+
+				if len(f.Names) > 0 {
+					for _, n := range f.Names {
+						if n.IsExported() {
+							candidates = append(candidates, field{name: n.Name, kind: kind, goType: goType, embeds: es.embeds})
+						}
+					}
+					continue
+				}
+
+				// embedded field; its name is that of its type.
+				embeddedName := typeIdent(f.Type)
+				if embeddedName == nil {
+					return st, fmt.Errorf("type %s: cannot determine name of embedded field", st.name)
+				}
+				_, isPtr := f.Type.(*ast.StarExpr)
+
+				if _, builtin := builtinKinds[embeddedName.Name]; builtin {
+					// built-in types are never structs, and are always
+					// unexported when embedded.
+					continue
+				}
+				embeddedSpec, declared := specs[embeddedName.Name]
+				if !declared || !isTypeName(f.Type) {
+					return st, fmt.Errorf("type %s: embedded field %s: only types declared in the given files can be embedded", st.name, embeddedName.Name)
+				}
+
+				embeddedStructExpr := resolveStruct(embeddedSpec, specs)
+				if embeddedStructExpr != nil && !hasMarshalMethod(methods[embeddedName.Name]) {
+					embeds := make([]embed, len(es.embeds), len(es.embeds)+1)
+					copy(embeds, es.embeds)
+					embeds = append(embeds, embed{name: embeddedName.Name, typeName: embeddedName.Name, ptr: isPtr})
+					next = append(next, embeddedStruct{name: embeddedName.Name, expr: embeddedStructExpr, embeds: embeds})
+					continue
+				}
+
+				if !embeddedName.IsExported() {
+					if embeddedStructExpr != nil {
+						return st, fmt.Errorf("type %s: embedded field %s: unexported embedded types with marshaling methods are not supported", st.name, embeddedName.Name)
+					}
+					continue
+				}
+				candidates = append(candidates, field{name: embeddedName.Name, kind: kindOther, embeds: es.embeds})
+			}
+		}
+
+		for name := range level {
+			visited[name] = true
+		}
+	}
+
+	// candidates are in order of depth, so the first of each name is at the
+	// shallowest depth it occurs at. it is only kept if no other candidate of
+	// the same name is at that depth.
+	depths := map[string]int{}
+	counts := map[string]int{}
+	for _, f := range candidates {
+		if d, ok := depths[f.name]; ok && d < len(f.embeds) {
+			continue
+		}
+		depths[f.name] = len(f.embeds)
+		counts[f.name]++
+	}
+
+	for _, f := range candidates {
+		if depths[f.name] == len(f.embeds) && counts[f.name] == 1 {
+			st.fields = append(st.fields, f)
+		}
+	}
+
+	sort.Slice(st.fields, func(i, j int) bool {
+		return st.fields[i].name < st.fields[j].name
+	})
+
+	return st, nil
+}
+
+// resolveStruct returns the struct type that the type declared by ts is, or
+// that its underlying type resolves to through other types in specs. It
+// returns nil if ts is not a struct type.
+func resolveStruct(ts *ast.TypeSpec, specs map[string]*ast.TypeSpec) *ast.StructType {
+	typeExpr := ts.Type
+	seen := map[string]bool{ts.Name.Name: true}
+	for {
+		ident, ok := typeExpr.(*ast.Ident)
+		if !ok || seen[ident.Name] {
+			break
+		}
+		underlying, ok := specs[ident.Name]
+		if !ok || (underlying.TypeParams != nil && len(underlying.TypeParams.List) > 0) {
+			break
+		}
+		seen[ident.Name] = true
+		typeExpr = underlying.Type
+	}
+
+	structExpr, _ := typeExpr.(*ast.StructType)
+	return structExpr
+}
+
+// hasMarshalMethod returns whether the given method names include any of
+// marshalMethods.
+func hasMarshalMethod(names map[string]bool) bool {
+	for _, m := range marshalMethods {
+		if names[m] {
+			return true
+		}
+	}
+	return false
+}
+
+// isTypeName returns whether expr is the name of a type in the same package, or
+// a pointer to one.
+func isTypeName(expr ast.Expr) bool {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	_, ok := expr.(*ast.Ident)
+	return ok
+}
+
+// typeIdent returns the identifier that gives the name of the type expr, which
+// is also the name of an embedded field with that type, or nil if it cannot be
+// determined.
+func typeIdent(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return typeIdent(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	case *ast.IndexExpr:
+		return typeIdent(t.X)
+	case *ast.IndexListExpr:
+		return typeIdent(t.X)
+	default:
+		return nil
+	}
+}
+
+type generator struct {
+	pkgName string
+
+	// qual is the qualifier to use for identifiers from the rezi package. It
+	// is empty when generating code for the rezi package itself.
+	qual string
+
+	buf bytes.Buffer
+}
+
+func (g *generator) printf(format string, a ...interface{}) {
+	fmt.Fprintf(&g.buf, format, a...)
+}
+
+func (g *generator) generate(types []structType) ([]byte, error) {
+	// fmt and math are only needed for range checks and for errors about
+	// unexported embedded pointers.
+	var usesFmt, usesMath bool
+	for _, st := range types {
+		for _, f := range st.fields {
+			usesFmt = usesFmt || (f.kind != kindOther && f.goType != decFuncs[f.kind][1])
+			usesMath = usesMath || f.goType == "float32"
+			for _, e := range f.embeds {
+				usesFmt = usesFmt || (e.ptr && !ast.IsExported(e.name))
+			}
+		}
+	}
+
+	g.printf("// Code generated by rezigen; DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", g.pkgName)
+	if usesFmt || g.qual != "" {
+		g.printf("import (\n")
+		if usesFmt {
+			g.printf("\t\"fmt\"\n")
+		}
+		if usesMath {
+			g.printf("\t\"math\"\n")
+		}
+		if g.qual != "" {
+			g.printf("\n\t%q\n", reziImportPath)
+		}
+		g.printf(")\n")
+	}
+
+	for _, st := range types {
+		g.genMarshal(st)
+		g.genUnmarshal(st)
+	}
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// encFuncs gives the name of the rezi.Writer method that encodes each kind of
+// field and the type of value it takes.
+var encFuncs = map[fieldKind][2]string{
+	kindString: {"EncString", "string"},
+	kindBool:   {"EncBool", "bool"},
+	kindInt:    {"EncInt", "int64"},
+	kindUint:   {"EncUint", "uint64"},
+	kindFloat:  {"EncFloat", "float64"},
+}
+
+// decFuncs gives the name of the rezi.Reader method that decodes each kind of
+// field and the type of value it returns.
+var decFuncs = map[fieldKind][2]string{
+	kindString: {"DecString", "string"},
+	kindBool:   {"DecBool", "bool"},
+	kindInt:    {"DecInt", "int64"},
+	kindUint:   {"DecUint", "uint64"},
+	kindFloat:  {"DecFloat", "float64"},
+}
+
+// selector returns the expression that selects f from the value v it is a field
+// of.
+func (f field) selector() string {
+	return embedSelector(f.embeds) + "." + f.name
+}
+
+// embedSelector returns the expression that selects the last of the given
+// embedded fields, each of which is within the one before it, from the value v
+// they are within.
+func embedSelector(embeds []embed) string {
+	sel := "v"
+	for _, e := range embeds {
+		sel += "." + e.name
+	}
+	return sel
+}
+
+// emptyCheck returns the expression that checks whether the value of f, which
+// must not be of kindOther, is empty in the way that REZI checks it for the
+// OmitEmpty option.
+func (f field) emptyCheck() string {
+	switch f.kind {
+	case kindString:
+		return f.selector() + ` == ""`
+	case kindBool:
+		return "!" + f.selector()
+	default:
+		return f.selector() + " == 0"
+	}
+}
+
+func (g *generator) genMarshal(st structType) {
+	q := g.qual
+
+	g.printf("\n// MarshalREZI writes the REZI encoding of v to w. It implements\n")
+	g.printf("// %sMarshaler.\n", q)
+	g.printf("func (v %s) MarshalREZI(w *%sWriter) error {\n", st.name, q)
+
+	for _, f := range st.fields {
+		// a field promoted through a nil embedded pointer is not encoded.
+		var nilChecks []string
+		for i, e := range f.embeds {
+			if e.ptr {
+				nilChecks = append(nilChecks, embedSelector(f.embeds[:i+1])+" != nil")
+			}
+		}
+		if len(nilChecks) > 0 {
+			g.printf("if %s {\n", strings.Join(nilChecks, " && "))
+		}
+
+		if f.kind == kindOther {
+			g.printf("if err := w.EncFieldValue(%q, %s); err != nil {\n", f.name, f.selector())
+			g.printf("return err\n")
+			g.printf("}\n")
+		} else {
+			fn := encFuncs[f.kind]
+			arg := f.selector()
+			if f.goType != fn[1] {
+				arg = fmt.Sprintf("%s(%s)", fn[1], arg)
+			}
+			g.printf("if ok, err := w.EncField(%q, %s); err != nil {\n", f.name, f.emptyCheck())
+			g.printf("return err\n")
+			g.printf("} else if ok {\n")
+			g.printf("if err := w.%s(%s); err != nil {\n", fn[0], arg)
+			g.printf("return err\n")
+			g.printf("}\n")
+			g.printf("}\n")
+		}
+
+		if len(nilChecks) > 0 {
+			g.printf("}\n")
+		}
+	}
+
+	g.printf("return nil\n")
+	g.printf("}\n")
+}
+
+func (g *generator) genUnmarshal(st structType) {
+	q := g.qual
+
+	g.printf("\n// UnmarshalREZI reads the REZI encoding of v from r. It implements\n")
+	g.printf("// %sUnmarshaler.\n", q)
+	g.printf("func (v *%s) UnmarshalREZI(r *%sReader) error {\n", st.name, q)
+	g.printf("for r.More() {\n")
+	g.printf("name, err := r.DecField()\n")
+	g.printf("if err != nil {\n")
+	g.printf("return err\n")
+	g.printf("}\n\n")
+
+	g.printf("switch name {\n")
+	for _, f := range st.fields {
+		g.printf("case %q:\n", f.name)
+		for i, e := range f.embeds {
+			if e.ptr {
+				sel := embedSelector(f.embeds[:i+1])
+				g.printf("if %s == nil {\n", sel)
+				if ast.IsExported(e.name) {
+					g.printf("%s = new(%s)\n", sel, e.typeName)
+				} else {
+					// REZI cannot allocate these with reflection, so neither
+					// do the generated methods.
+					const errFmt = "return r.FieldError(fmt.Errorf(\"cannot set embedded pointer to unexported struct type %s.%s: %%w\", %sErrInvalidType))\n"
+					g.printf(errFmt, g.pkgName, e.typeName, q)
+				}
+				g.printf("}\n")
+			}
+		}
+		if f.kind == kindOther {
+			g.printf("if err := r.Dec(&%s); err != nil {\n", f.selector())
+			g.printf("return err\n")
+			g.printf("}\n")
+			continue
+		}
+
+		fn := decFuncs[f.kind]
+		g.printf("fv, err := r.%s()\n", fn[0])
+		g.printf("if err != nil {\n")
+		g.printf("return err\n")
+		g.printf("}\n")
+		val := "fv"
+		if f.goType != fn[1] {
+			val = fmt.Sprintf("%s(fv)", f.goType)
+			g.genRangeCheck(f, fn[1])
+		}
+		g.printf("%s = %s\n", f.selector(), val)
+	}
+	g.printf("default:\n")
+	g.printf("return r.UnknownFieldError(%q)\n", st.name)
+	g.printf("}\n")
+	g.printf("}\n\n")
+
+	g.printf("return nil\n")
+	g.printf("}\n")
+}
+
+// genRangeCheck writes code that returns an error matching rezi.ErrRange if
+// fv, a value of decType decoded for field f, does not fit in the type of f.
+// The error is reported through the Reader so that it gives the offset of the
+// value and the field in its path.
+func (g *generator) genRangeCheck(f field, decType string) {
+	q := g.qual
+
+	if f.kind == kindFloat {
+		// only overflow to infinity is an error; a loss of precision is not.
+		g.printf("if !math.IsInf(fv, 0) && math.IsInf(float64(%s(fv)), 0) {\n", f.goType)
+		g.printf("return r.FieldError(fmt.Errorf(\"decoded value %%v overflows %s: %%w\", fv, %sErrRange))\n", f.goType, q)
+		g.printf("}\n")
+		return
+	}
+
+	g.printf("if %s(%s(fv)) != fv {\n", decType, f.goType)
+	g.printf("return r.FieldError(fmt.Errorf(\"decoded value %%d overflows %s: %%w\", fv, %sErrRange))\n", f.goType, q)
+	g.printf("}\n")
+}
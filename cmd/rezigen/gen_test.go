@@ -0,0 +1,295 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_defaultOutputName(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  string
+		expect string
+	}{
+		{name: "normal file", input: "person.go", expect: "person_rezi.go"},
+		{name: "test file", input: "person_test.go", expect: "person_rezi_test.go"},
+		{name: "file in dir", input: filepath.Join("models", "person.go"), expect: filepath.Join("models", "person_rezi.go")},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual := defaultOutputName(tc.input)
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_generate(t *testing.T) {
+	const src = `package people
+
+import "strings"
+
+type Person struct {
+	Name   string
+	Number int
+	Tags   []string
+	secret bool
+}
+
+type Number int
+
+type Player Person
+
+type Reading struct {
+	Level int8
+	Ratio float32
+}
+
+type Base struct {
+	ID   int
+	Name string
+}
+
+type Extra struct {
+	Level int
+}
+
+type Employee struct {
+	Base
+	*Extra
+	Name string
+}
+
+type rank struct {
+	Rank int
+}
+
+type Manager struct {
+	*rank
+	Name string
+}
+
+type Label struct {
+	Text string
+}
+
+type Stamp struct {
+	Unix int64
+}
+
+func (s Stamp) MarshalText() ([]byte, error) { return nil, nil }
+
+type Logged struct {
+	Stamp
+	Count int
+}
+
+type Remote struct {
+	strings.Builder
+}
+
+type Series struct {
+	Values []int ` + "`" + `json:"values" rezi:",packed"` + "`" + `
+}
+`
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "people.go")
+	if err := os.WriteFile(file, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("struct type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual, err := generate([]string{file}, []string{"Person"})
+		if !assert.NoError(err) {
+			return
+		}
+
+		code := string(actual)
+		assert.Contains(code, "package people")
+		assert.Contains(code, `"github.com/dekarrin/rezi/v2"`)
+		assert.Contains(code, "func (v Person) MarshalREZI(w *rezi.Writer) error")
+		assert.Contains(code, "func (v *Person) UnmarshalREZI(r *rezi.Reader) error")
+		assert.Contains(code, `w.EncField("Number", v.Number == 0)`)
+		assert.Contains(code, "w.EncInt(int64(v.Number))")
+		assert.Contains(code, `w.EncFieldValue("Tags", v.Tags)`)
+		assert.Contains(code, "v.Number = int(fv)")
+		assert.Contains(code, "name, err := r.DecField()")
+		assert.Contains(code, `return r.UnknownFieldError("Person")`)
+		assert.NotContains(code, "secret")
+
+		// fields must be encoded in order of name
+		assert.Less(strings.Index(code, `EncField("Name"`), strings.Index(code, `EncField("Number"`))
+		assert.Less(strings.Index(code, `EncField("Number"`), strings.Index(code, `EncFieldValue("Tags"`))
+	})
+
+	t.Run("type with struct underlying type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual, err := generate([]string{file}, []string{"Player"})
+		if !assert.NoError(err) {
+			return
+		}
+
+		code := string(actual)
+		assert.Contains(code, "func (v Player) MarshalREZI(w *rezi.Writer) error")
+		assert.Contains(code, "w.EncInt(int64(v.Number))")
+		assert.Contains(code, `w.EncFieldValue("Tags", v.Tags)`)
+	})
+
+	t.Run("narrow numbers are range-checked", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual, err := generate([]string{file}, []string{"Reading"})
+		if !assert.NoError(err) {
+			return
+		}
+
+		code := string(actual)
+		assert.Contains(code, `"math"`)
+		assert.Contains(code, "if int64(int8(fv)) != fv {")
+		assert.Contains(code, "math.IsInf(float64(float32(fv)), 0)")
+		assert.Contains(code, `w.EncField("Ratio", v.Ratio == 0)`)
+		assert.Contains(code, "rezi.ErrRange")
+		assert.Contains(code, "return r.FieldError(")
+	})
+
+	t.Run("no range checks", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual, err := generate([]string{file}, []string{"Label"})
+		if !assert.NoError(err) {
+			return
+		}
+
+		code := string(actual)
+		assert.NotContains(code, `"fmt"`)
+		assert.NotContains(code, `"math"`)
+	})
+
+	t.Run("embedded structs", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual, err := generate([]string{file}, []string{"Employee"})
+		if !assert.NoError(err) {
+			return
+		}
+
+		code := string(actual)
+		assert.Contains(code, "w.EncInt(int64(v.Base.ID))")
+		assert.Contains(code, "w.EncString(v.Name)")
+		assert.NotContains(code, "v.Base.Name", "shadowed field was encoded")
+		assert.Contains(code, "if v.Extra != nil {")
+		assert.Contains(code, "v.Extra = new(Extra)")
+		assert.Contains(code, "v.Extra.Level = int(fv)")
+		assert.NotContains(code, `"Base"`)
+	})
+
+	t.Run("embedded unexported pointer", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual, err := generate([]string{file}, []string{"Manager"})
+		if !assert.NoError(err) {
+			return
+		}
+
+		code := string(actual)
+		assert.Contains(code, `"fmt"`)
+		assert.Contains(code, "if v.rank == nil {")
+		assert.Contains(code, "cannot set embedded pointer to unexported struct type people.rank")
+		assert.NotContains(code, "new(rank)")
+	})
+
+	t.Run("embedded struct with marshaling methods", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual, err := generate([]string{file}, []string{"Logged"})
+		if !assert.NoError(err) {
+			return
+		}
+
+		code := string(actual)
+		assert.Contains(code, `w.EncFieldValue("Stamp", v.Stamp)`)
+		assert.NotContains(code, "Unix")
+	})
+
+	t.Run("embedded type from another package", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := generate([]string{file}, []string{"Remote"})
+
+		assert.Error(err)
+	})
+
+	t.Run("non-struct type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := generate([]string{file}, []string{"Number"})
+
+		assert.Error(err)
+	})
+
+	t.Run("field with rezi tag", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := generate([]string{file}, []string{"Series"})
+
+		assert.Error(err)
+	})
+
+	t.Run("undeclared type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := generate([]string{file}, []string{"Team"})
+
+		assert.Error(err)
+	})
+}
+
+func Test_generate_RepoFixtures(t *testing.T) {
+	// the struct fixtures of the rezi package tests that have rezi tags
+	// cannot be generated for; the rest are checked against the reflective
+	// encoding in the rezi package tests.
+	files := []string{
+		filepath.Join("..", "..", "structs_test.go"),
+		filepath.Join("..", "..", "defaults_test.go"),
+		filepath.Join("..", "..", "fieldids_test.go"),
+	}
+
+	testCases := []struct {
+		name      string
+		typeName  string
+		expectErr bool
+	}{
+		{name: "multi member", typeName: "testStructMultiMember"},
+		{name: "embedded unexported pointer", typeName: "testStructEmbedUnexportedPtr"},
+		{name: "ambiguous embedded fields", typeName: "testStructEmbedAmbiguous"},
+		{name: "tuple", typeName: "testStructTuple", expectErr: true},
+		{name: "omitempty", typeName: "testStructOmitEmpty", expectErr: true},
+		{name: "required", typeName: "testStructRequired", expectErr: true},
+		{name: "numbered", typeName: "testNumberedStruct", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := generate(files, []string{tc.typeName})
+
+			if tc.expectErr {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
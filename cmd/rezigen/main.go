@@ -0,0 +1,143 @@
+/*
+Rezigen generates MarshalREZI and UnmarshalREZI methods for struct types so
+that they can be encoded and decoded by REZI without the use of reflection.
+
+The generated methods produce and consume the same bytes as the reflection-based
+encoding that REZI performs on structs, so they can be added to or removed from
+a type without affecting any data that has already been encoded.
+
+Usage:
+
+	rezigen -type T[,T...] [-output file] [file ...]
+
+Rezigen is intended to be run by go generate, by placing a directive in the
+file that declares the types:
+
+	//go:generate go run github.com/dekarrin/rezi/v2/cmd/rezigen -type Animal,Habitat
+
+The flags are:
+
+	-type
+		A comma-separated list of the names of the struct types to generate
+		methods for. Required.
+	-output
+		The file to write the generated code to. Defaults to the name of the
+		first source file with "_rezi" added before its extension, or before
+		"_test" if it is a test file.
+
+If no files are given, the file named by the GOFILE environment variable (set by
+go generate) is used. All of the named types must be declared in the given
+files.
+
+A named type can be one declared with another struct type from the given files
+as its underlying type, such as "type T U"; it is given methods for the fields
+of U.
+
+Fields whose type is a built-in string, bool, integer, or float type are
+encoded and decoded with the typed methods of rezi.Writer and rezi.Reader, such
+as EncString and DecString. A decoded number that does not fit in the type of
+its field results in an error matching rezi.ErrRange, reported by
+rezi.Reader's FieldError method at the same offset and path as it is without
+the generated methods. Fields of all other types are encoded and
+decoded by calling the EncFieldValue and Dec methods, which will in turn use
+the generated methods of any field whose type has them.
+
+Each field is begun with rezi.Writer's EncField or EncFieldValue method, which
+apply the Format options that change how struct fields are written, such as
+OmitEmpty and FieldNameTable, as well as the fields selected by rezi.EncFields.
+The generated methods therefore produce the same bytes as reflection under
+every Format.
+
+The exported fields of embedded structs are promoted to the outer struct with
+the same rules that REZI uses, and a nil embedded pointer is allocated when a
+field promoted through it is decoded, unless its type is unexported, in which
+case an error is returned as it is by REZI. Only types declared in the given
+files can be embedded, so that rezigen can tell whether they are structs; an
+embedded struct whose type has a MarshalREZI, MarshalText, or MarshalBinary
+method declared in the given files, or that is one of the types being
+generated for, is encoded as a single field instead.
+
+Types with fields that have a rezi struct tag are not supported.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// reziImportPath is the import path of the package that generated code
+	// calls into.
+	reziImportPath = "github.com/dekarrin/rezi/v2"
+
+	// reziPackageName is the name of the package at reziImportPath.
+	reziPackageName = "rezi"
+)
+
+var (
+	flagTypes  = flag.String("type", "", "comma-separated list of struct type names; required")
+	flagOutput = flag.String("output", "", "output file name; default srcdir/<file>_rezi.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of rezigen:\n")
+	fmt.Fprintf(os.Stderr, "\trezigen -type T[,T...] [-output file] [file ...]\n")
+	fmt.Fprintf(os.Stderr, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if *flagTypes == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	typeNames := strings.Split(*flagTypes, ",")
+
+	files := flag.Args()
+	if len(files) == 0 {
+		goFile := os.Getenv("GOFILE")
+		if goFile == "" {
+			fmt.Fprintf(os.Stderr, "rezigen: no files given and GOFILE is not set\n")
+			os.Exit(2)
+		}
+		files = []string{goFile}
+	}
+
+	output := *flagOutput
+	if output == "" {
+		output = defaultOutputName(files[0])
+	}
+
+	src, err := generate(files, typeNames)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rezigen: %s\n", err)
+		os.Exit(1)
+	}
+
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "rezigen: write output: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// defaultOutputName gives the name of the file that code generated from the
+// types in file is written to if no name is given.
+func defaultOutputName(file string) string {
+	dir, base := filepath.Split(file)
+	base = strings.TrimSuffix(base, ".go")
+
+	if strings.HasSuffix(base, "_test") {
+		base = strings.TrimSuffix(base, "_test") + "_rezi_test.go"
+	} else {
+		base += "_rezi.go"
+	}
+
+	return filepath.Join(dir, base)
+}
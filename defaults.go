@@ -0,0 +1,118 @@
+package rezi
+
+// defaults.go contains functions for handling struct fields that are absent
+// from decoded data.
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Defaulter is implemented by struct types that give default values for
+// fields that are absent from decoded data. When a struct whose pointer type
+// implements Defaulter is decoded, DefaultREZI is called on a new value of the
+// struct type, and every field that is absent from the data and that does not
+// have the default option in its rezi tag is set to its value in that new
+// value.
+type Defaulter interface {
+	DefaultREZI()
+}
+
+var refDefaulterType = reflect.TypeOf((*Defaulter)(nil)).Elem()
+
+// parseDefault returns the value given by the default option of the rezi tag
+// of sf. Only fields of bool, string, integer, and float types may have a
+// default.
+func parseDefault(sf reflect.StructField, s string) (reflect.Value, error) {
+	v := reflect.New(sf.Type).Elem()
+
+	var err error
+	switch sf.Type.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(s)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(s, 0, sf.Type.Bits())
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(s, 0, sf.Type.Bits())
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(s, sf.Type.Bits())
+		v.SetFloat(f)
+	default:
+		return v, errorf("field .%s has default option but is not a bool, string, integer, or float", sf.Name).wrap(ErrInvalidType)
+	}
+
+	if err != nil {
+		return v, errorf("rezi tag of field .%s: invalid default %q: %s", sf.Name, s, err).wrap(ErrInvalidType)
+	}
+	return v, nil
+}
+
+// decAbsentFields handles the fields of the struct target that are not in
+// dec.fields after it has been decoded. If any of them are required, an error
+// is returned. Otherwise, each one that has a default is set to it and added
+// to dec.fields.
+func decAbsentFields(target reflect.Value, fs *fields, dec *decoded[any], msgTypeName string) error {
+	present := map[string]bool{}
+	for _, fi := range dec.fields {
+		present[fi.Name] = true
+	}
+
+	var missing []string
+	for _, fi := range fs.ByOrder {
+		if fi.Required && !present[fi.Name] {
+			missing = append(missing, "."+fi.Name)
+		}
+	}
+	if len(missing) > 0 {
+		s := ""
+		if len(missing) > 1 {
+			s = "s"
+		}
+		return errorDecf(0, "%s is missing required field%s %s", msgTypeName, s, strings.Join(missing, ", ")).wrap(ErrMissingField)
+	}
+
+	var defaults reflect.Value
+	if fs.Defaulter {
+		defaults = reflect.New(target.Type())
+		defaults.Interface().(Defaulter).DefaultREZI()
+		defaults = defaults.Elem()
+	}
+
+	for _, fi := range fs.ByOrder {
+		if present[fi.Name] {
+			continue
+		}
+
+		var def reflect.Value
+		if fi.Default.IsValid() {
+			def = fi.Default
+		} else if defaults.IsValid() {
+			var ok bool
+			def, ok = fieldByIndex(defaults, fi.Index)
+			if !ok {
+				def = reflect.Zero(target.Type().FieldByIndex(fi.Index).Type)
+			}
+		} else {
+			continue
+		}
+
+		field, err := settableFieldByIndex(target, fi.Index)
+		if err != nil {
+			return errorDecf(0, "%s.%s: %v", msgTypeName, fi.Name, err)
+		}
+		field.Set(def)
+		dec.fields = append(dec.fields, fi)
+	}
+
+	return nil
+}
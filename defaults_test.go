@@ -0,0 +1,282 @@
+package rezi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testStructRequired struct {
+	Name  string `rezi:",required"`
+	Level int    `rezi:",required"`
+	Note  string
+}
+
+type testStructDefaults struct {
+	Name    string  `rezi:",default=Jane"`
+	Level   int8    `rezi:",default=-3"`
+	Mask    uint16  `rezi:",default=0xff"`
+	Ratio   float32 `rezi:",default=0.5"`
+	Enabled bool    `rezi:",default=true"`
+	Note    string
+}
+
+type testStructDefaulter struct {
+	Name  string `rezi:",default=Jade"`
+	Level int
+	Note  string
+}
+
+func (s *testStructDefaulter) DefaultREZI() {
+	s.Name = "Rose"
+	s.Level = 413
+}
+
+func Test_Dec_Struct_Required(t *testing.T) {
+	t.Run("all required fields present", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructRequired{Name: "Jake", Level: 2})
+		expect := testStructRequired{Name: "Jake", Level: 2}
+
+		var actual testStructRequired
+		_, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("required fields missing", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(struct{ Note string }{Note: "hi"})
+
+		var actual testStructRequired
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMissingField)
+		assert.ErrorContains(err, ".Level, .Name")
+	})
+
+	t.Run("required field missing from empty struct", func(t *testing.T) {
+		assert := assert.New(t)
+		input := []byte{0x00}
+
+		var actual testStructRequired
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMissingField)
+	})
+
+	t.Run("nested struct missing required field", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(map[string]struct{ Name string }{"a": {Name: "Dirk"}})
+
+		var actual map[string]testStructRequired
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMissingField)
+		assert.ErrorContains(err, ".Level")
+	})
+}
+
+func Test_Dec_Struct_Defaults(t *testing.T) {
+	t.Run("defaults applied to absent fields", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(struct{ Note string }{Note: "hi"})
+		expect := testStructDefaults{Name: "Jane", Level: -3, Mask: 0xff, Ratio: 0.5, Enabled: true, Note: "hi"}
+
+		actual := testStructDefaults{Name: "Roxy"}
+		_, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("defaults not applied to present fields", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testStructDefaults{Name: "Roxy", Level: 0, Mask: 1, Ratio: 0, Enabled: false, Note: "hi"}
+		input := MustEnc(expect)
+
+		var actual testStructDefaults
+		_, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("Defaulter", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(struct{ Note string }{Note: "hi"})
+		expect := testStructDefaulter{Name: "Jade", Level: 413, Note: "hi"}
+
+		actual := testStructDefaulter{Level: 8}
+		_, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("Defaulter with empty struct", func(t *testing.T) {
+		assert := assert.New(t)
+		input := []byte{0x00}
+		expect := testStructDefaulter{Name: "Jade", Level: 413}
+
+		var actual testStructDefaulter
+		_, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+}
+
+func Test_Dec_Struct_InvalidDefault(t *testing.T) {
+	testCases := []struct {
+		name string
+		dest interface{}
+	}{
+		{
+			name: "unparsable int",
+			dest: &struct {
+				A int `rezi:",default=ten"`
+			}{},
+		},
+		{
+			name: "int out of range",
+			dest: &struct {
+				A int8 `rezi:",default=300"`
+			}{},
+		},
+		{
+			name: "unsupported type",
+			dest: &struct {
+				A []int `rezi:",default=1"`
+			}{},
+		},
+		{
+			name: "required with default",
+			dest: &struct {
+				A int `rezi:",required,default=1"`
+			}{},
+		},
+		{
+			name: "omitempty with default",
+			dest: &struct {
+				A int `rezi:",omitempty,default=5"`
+			}{},
+		},
+		{
+			name: "required with omitempty",
+			dest: &struct {
+				A int `rezi:",required,omitempty"`
+			}{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := Dec([]byte{0x00}, tc.dest)
+
+			assert.ErrorIs(err, ErrInvalidType)
+		})
+	}
+}
+
+func Test_Struct_Defaults_RoundTrip(t *testing.T) {
+	t.Run("OmitEmpty keeps defaulted fields", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testStructDefaults{Name: "", Level: 0, Enabled: false, Note: "hi"}
+
+		input, err := EncWithFormat(expect, &Format{OmitEmpty: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testStructDefaults
+		_, err = Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("OmitEmpty keeps required fields", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testStructRequired{Name: "", Level: 0}
+
+		input, err := EncWithFormat(expect, &Format{OmitEmpty: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testStructRequired
+		_, err = Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("OmitEmpty still omits other fields", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := MustEnc(struct {
+			Name  string
+			Level int
+		}{})
+
+		actual, err := EncWithFormat(testStructRequired{}, &Format{OmitEmpty: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("EncFields gives unselected defaulted fields the zero value", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testStructDefaults{Note: "hi"}
+
+		input, err := EncFields(testStructDefaults{Name: "Roxy", Level: 4, Enabled: true, Note: "hi"}, "Note")
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testStructDefaults
+		_, err = Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("EncFields gives unselected required fields the zero value", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testStructRequired{Note: "hi"}
+
+		input, err := EncFields(testStructRequired{Name: "Jake", Level: 2, Note: "hi"}, "Note")
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testStructRequired
+		_, err = Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+}
@@ -0,0 +1,497 @@
+package rezi
+
+// diff.go contains functions for creating and applying patches that turn the
+// encoding of one value into the encoding of another.
+
+import (
+	"bytes"
+	"hash/crc32"
+	"reflect"
+	"sort"
+)
+
+// Diff returns a patch that turns the data that Enc returns for from into the
+// data that Enc returns for to, which must be of the same type. The patch is
+// itself REZI-encoded, and only holds the struct fields, map entries, and
+// ranges of slice items that differ between the two, so it is usually much
+// smaller than the encoding of to when little has changed. Apply it with
+// [Patch].
+func Diff(from, to interface{}) (patch []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	info, err := canEncode(from)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(from) != reflect.TypeOf(to) {
+		return nil, errorf("cannot diff %T and %T", from, to).wrap(ErrInvalidType)
+	}
+
+	data, err := encWithTypeInfo(from, info, newSession(nil))
+	if err != nil {
+		return nil, err
+	}
+	edits, err := diffValue(data, 0, reflect.ValueOf(from), reflect.ValueOf(to), info, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return Enc(patchData{
+		Size:     len(data),
+		Checksum: crc32.ChecksumIEEE(data),
+		Edits:    edits,
+	})
+}
+
+// Patch applies a patch created by [Diff] to data, which must be exactly the
+// data that the patch was created from, and returns the patched data. Only the
+// changed parts are written and the headers of the values that enclose them
+// are updated, so the type of the encoded value is not needed. data itself is
+// not modified.
+//
+// If the patch was created from different data, the returned error will match
+// [ErrMalformedData].
+func Patch(data, patch []byte) (patched []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	var p patchData
+	if _, err := Dec(patch, &p); err != nil {
+		return nil, errorf("patch: %v", err)
+	}
+	return applyPatch(data, p)
+}
+
+// patchEdit is a single change made by a patch. It replaces the bytes from
+// Start to End in the old data with Data.
+type patchEdit struct {
+	Start int
+	End   int
+	Data  []byte
+
+	// Headers holds the offset in the old data of the count header of every
+	// value that encloses the edited bytes, from the outermost to the
+	// innermost.
+	Headers []int
+}
+
+// patchData is the content of a patch created by Diff.
+type patchData struct {
+	// Size and Checksum are the length and CRC-32 checksum of the old data,
+	// used to check that a patch is applied to the data it was made for.
+	Size     int
+	Checksum uint32
+
+	// Edits holds the changes to make, ordered by their Start.
+	Edits []patchEdit
+}
+
+// diffEntry is the location of a field of a struct or an entry of a map
+// within the encoded contents of the struct or map.
+type diffEntry struct {
+	// key holds the encoded field name or map key.
+	key string
+
+	// start, value, and end are the offsets of the key, the value, and the
+	// end of the value.
+	start, value, end int
+}
+
+// diffValue returns the edits that turn the encoded value at the start of
+// old into the encoding of nv. old is at the given offset within the data the
+// edits are for, and holds the encoding of ov. Both ov and nv have type info
+// ti, and headers holds the offsets of the count headers of the values that
+// enclose them.
+//
+// If ov and nv are both structs, maps, slices, or arrays, only the fields,
+// entries, or items within them that changed are edited. Otherwise, all of the
+// encoded value is replaced.
+func diffValue(old []byte, offset int, ov, nv reflect.Value, ti typeInfo, headers []int) ([]patchEdit, error) {
+	n, err := skipValue(old, shapeOf(ti))
+	if err != nil {
+		return nil, err
+	}
+	old = old[:n]
+
+	newData, err := encWithTypeInfo(nv.Interface(), ti, newSession(nil))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(old, newData) {
+		return nil, nil
+	}
+	replace := []patchEdit{{Start: offset, End: offset + n, Data: newData, Headers: headers}}
+
+	for i := 0; i < ti.Indir; i++ {
+		if ov.IsNil() || nv.IsNil() {
+			return replace, nil
+		}
+		ov, nv = ov.Elem(), nv.Elem()
+	}
+	ti.Indir = 0
+
+	if ti.Main != mtStruct && ti.Main != mtMap && ti.Main != mtSlice && ti.Main != mtArray {
+		return replace, nil
+	}
+	if ti.Main == mtMap && (ov.IsNil() || nv.IsNil()) {
+		return replace, nil
+	}
+	if ti.Main == mtSlice && (ov.IsNil() || nv.IsNil()) {
+		return replace, nil
+	}
+
+	// only structs with field names and slices with a header for each item
+	// can be edited in part.
+	oldHdr, err := decCountHeader(old)
+	if err != nil {
+		return nil, err
+	}
+	newHdr, err := decCountHeader(newData)
+	if err != nil {
+		return nil, err
+	}
+	if oldHdr.v.Version != 0 || newHdr.v.Version != 0 || oldHdr.v.ArrayLength || newHdr.v.ArrayLength {
+		return replace, nil
+	}
+
+	oldContents, contentsAt, err := pathContents(old)
+	if err != nil {
+		return nil, err
+	}
+	newContents, _, err := pathContents(newData)
+	if err != nil {
+		return nil, err
+	}
+
+	inner := make([]int, len(headers), len(headers)+1)
+	copy(inner, headers)
+	inner = append(inner, offset)
+	offset += contentsAt
+
+	switch ti.Main {
+	case mtStruct:
+		return diffStruct(oldContents, newContents, offset, ov, nv, ti, inner)
+	case mtMap:
+		return diffMap(oldContents, newContents, offset, ov, nv, ti, inner)
+	default:
+		return diffItems(oldContents, newContents, offset, ov, nv, ti, inner)
+	}
+}
+
+// diffStruct returns the edits that turn the encoded fields of struct ov in
+// before into the encoded fields of struct nv in after. before is at the given
+// offset within the data the edits are for.
+func diffStruct(before, after []byte, offset int, ov, nv reflect.Value, ti typeInfo, headers []int) ([]patchEdit, error) {
+	fieldEntry := func(data []byte) (int, valueShape, error) {
+		name, err := decString(data)
+		if err != nil {
+			return 0, shapeCounted, err
+		}
+		if fi, ok := ti.Fields.ByName[name.v]; ok {
+			return name.n, shapeOf(fi.Type), nil
+		}
+		if _, ok := ti.Fields.Embedded[name.v]; ok {
+			return name.n, shapeCounted, nil
+		}
+		return 0, shapeCounted, errorf("field name .%s does not exist in %s", name.v, ov.Type()).wrap(ErrMalformedData, ErrInvalidType)
+	}
+
+	changed := func(o, n diffEntry) ([]patchEdit, error) {
+		name, err := decString([]byte(o.key))
+		if err != nil {
+			return nil, err
+		}
+		fi, ok := ti.Fields.ByName[name.v]
+		if !ok {
+			// an embedded struct encoded as a nested struct is replaced
+			// whole.
+			return []patchEdit{{Start: offset + o.value, End: offset + o.end, Data: after[n.value:n.end], Headers: headers}}, nil
+		}
+		ofv, _ := fieldByIndex(ov, fi.Index)
+		nfv, _ := fieldByIndex(nv, fi.Index)
+		return diffValue(before[o.value:], offset+o.value, ofv, nfv, fi.Type, headers)
+	}
+
+	return diffEntries(before, after, offset, headers, fieldEntry, changed)
+}
+
+// diffMap returns the edits that turn the encoded entries of map ov in before
+// into the encoded entries of map nv in after. before is at the given offset
+// within the data the edits are for.
+func diffMap(before, after []byte, offset int, ov, nv reflect.Value, ti typeInfo, headers []int) ([]patchEdit, error) {
+	keyShape := shapeOf(*ti.KeyType)
+	valShape := shapeOf(*ti.ValType)
+	mapEntry := func(data []byte) (int, valueShape, error) {
+		n, err := skipValue(data, keyShape)
+		return n, valShape, err
+	}
+
+	changed := func(o, n diffEntry) ([]patchEdit, error) {
+		k := reflect.New(ov.Type().Key())
+		if _, err := decWithTypeInfo([]byte(o.key), k.Interface(), *ti.KeyType, nil); err != nil {
+			return nil, err
+		}
+		ovv, nvv := ov.MapIndex(k.Elem()), nv.MapIndex(k.Elem())
+		if !ovv.IsValid() || !nvv.IsValid() {
+			// the key could not be looked up, such as a NaN float.
+			return []patchEdit{{Start: offset + o.value, End: offset + o.end, Data: after[n.value:n.end], Headers: headers}}, nil
+		}
+		return diffValue(before[o.value:], offset+o.value, ovv, nvv, *ti.ValType, headers)
+	}
+
+	return diffEntries(before, after, offset, headers, mapEntry, changed)
+}
+
+// diffEntries returns the edits that turn the encoded struct fields or map
+// entries in before into those in after. Both are in the same order, as they
+// are sorted when they are encoded. before is at the given offset within the
+// data the edits are for. keyLen gives the length of the key at the start of
+// the data it is given and the shape of the value that follows, and changed
+// gives the edits for an entry whose key is in both before and after but whose
+// value differs.
+func diffEntries(before, after []byte, offset int, headers []int, keyLen func([]byte) (int, valueShape, error), changed func(o, n diffEntry) ([]patchEdit, error)) ([]patchEdit, error) {
+	oldEntries, err := readEntries(before, keyLen)
+	if err != nil {
+		return nil, err
+	}
+	newEntries, err := readEntries(after, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	oldIndex := make(map[string]int, len(oldEntries))
+	for i, e := range oldEntries {
+		oldIndex[e.key] = i
+	}
+	inNew := make(map[string]bool, len(newEntries))
+	for _, e := range newEntries {
+		inNew[e.key] = true
+	}
+
+	var edits []patchEdit
+	next := 0
+	for _, ne := range newEntries {
+		i, ok := oldIndex[ne.key]
+		if ok && i < next {
+			// the entries are not in the same order in both, so replace all
+			// of them.
+			return []patchEdit{{Start: offset, End: offset + len(before), Data: after, Headers: headers}}, nil
+		}
+		if !ok {
+			// added entries go ahead of the first old entry that follows the
+			// last one that was kept.
+			at := len(before)
+			if next < len(oldEntries) {
+				at = oldEntries[next].start
+			}
+			edits = append(edits, patchEdit{Start: offset + at, End: offset + at, Data: after[ne.start:ne.end], Headers: headers})
+			continue
+		}
+
+		for ; next < i; next++ {
+			if oe := oldEntries[next]; !inNew[oe.key] {
+				edits = append(edits, patchEdit{Start: offset + oe.start, End: offset + oe.end, Headers: headers})
+			}
+		}
+		next = i + 1
+
+		oe := oldEntries[i]
+		if bytes.Equal(before[oe.value:oe.end], after[ne.value:ne.end]) {
+			continue
+		}
+		entryEdits, err := changed(oe, ne)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, entryEdits...)
+	}
+	for ; next < len(oldEntries); next++ {
+		if oe := oldEntries[next]; !inNew[oe.key] {
+			edits = append(edits, patchEdit{Start: offset + oe.start, End: offset + oe.end, Headers: headers})
+		}
+	}
+
+	return edits, nil
+}
+
+// readEntries returns the location of every struct field or map entry in the
+// encoded contents of a struct or map.
+func readEntries(data []byte, keyLen func([]byte) (int, valueShape, error)) ([]diffEntry, error) {
+	var entries []diffEntry
+	var i int
+	for i < len(data) {
+		kn, shape, err := keyLen(data[i:])
+		if err != nil {
+			return nil, errorDecf(i, "%s", err)
+		}
+		vn, err := skipValue(data[i+kn:], shape)
+		if err != nil {
+			return nil, errorDecf(i+kn, "%s", err)
+		}
+		entries = append(entries, diffEntry{
+			key:   string(data[i : i+kn]),
+			start: i,
+			value: i + kn,
+			end:   i + kn + vn,
+		})
+		i += kn + vn
+	}
+	return entries, nil
+}
+
+// diffItems returns the edits that turn the encoded items of slice or array ov
+// in before into the encoded items of nv in after. before is at the given
+// offset within the data the edits are for. The items that are the same at the
+// start and end of both are kept, and the range between them is replaced,
+// unless it has the same number of items in both, in which case each item is
+// edited.
+func diffItems(before, after []byte, offset int, ov, nv reflect.Value, ti typeInfo, headers []int) ([]patchEdit, error) {
+	shape := shapeOf(*ti.ValType)
+	oldItems, err := readItems(before, shape)
+	if err != nil {
+		return nil, err
+	}
+	newItems, err := readItems(after, shape)
+	if err != nil {
+		return nil, err
+	}
+
+	item := func(data []byte, bounds []int, i int) []byte {
+		return data[bounds[i]:bounds[i+1]]
+	}
+	oldLen, newLen := len(oldItems)-1, len(newItems)-1
+
+	prefix := 0
+	for prefix < oldLen && prefix < newLen && bytes.Equal(item(before, oldItems, prefix), item(after, newItems, prefix)) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < oldLen-prefix && suffix < newLen-prefix && bytes.Equal(item(before, oldItems, oldLen-1-suffix), item(after, newItems, newLen-1-suffix)) {
+		suffix++
+	}
+
+	if oldLen-suffix-prefix != newLen-suffix-prefix {
+		oldStart, oldEnd := oldItems[prefix], oldItems[oldLen-suffix]
+		newStart, newEnd := newItems[prefix], newItems[newLen-suffix]
+		return []patchEdit{{Start: offset + oldStart, End: offset + oldEnd, Data: after[newStart:newEnd], Headers: headers}}, nil
+	}
+
+	var edits []patchEdit
+	for i := prefix; i < oldLen-suffix; i++ {
+		itemEdits, err := diffValue(before[oldItems[i]:], offset+oldItems[i], ov.Index(i), nv.Index(i), *ti.ValType, headers)
+		if err != nil {
+			return nil, err
+		}
+		edits = append(edits, itemEdits...)
+	}
+	return edits, nil
+}
+
+// readItems returns the offset of every item in the encoded contents of a
+// slice or array, followed by the offset of the end of the last item.
+func readItems(data []byte, shape valueShape) ([]int, error) {
+	bounds := []int{0}
+	var i int
+	for i < len(data) {
+		n, err := skipValue(data[i:], shape)
+		if err != nil {
+			return nil, errorDecf(i, "%s", err)
+		}
+		i += n
+		bounds = append(bounds, i)
+	}
+	return bounds, nil
+}
+
+// applyPatch returns data with the edits of p made to it. The count headers of
+// the values that enclose each edit are changed to match the new length of
+// their contents.
+func applyPatch(data []byte, p patchData) ([]byte, error) {
+	if len(data) != p.Size || crc32.ChecksumIEEE(data) != p.Checksum {
+		return nil, errorf("patch was not created from this data").wrap(ErrMalformedData)
+	}
+
+	// the change in length of the contents of each header, and the header
+	// that encloses it.
+	grow := map[int]int{}
+	parent := map[int]int{}
+	for _, e := range p.Edits {
+		if e.Start < 0 || e.End < e.Start || e.End > len(data) {
+			return nil, errorf("patch edit %d:%d is out of range", e.Start, e.End).wrap(ErrMalformedData)
+		}
+		for i, h := range e.Headers {
+			if h < 0 || h >= e.Start {
+				return nil, errorf("patch header at %d does not come before its edit", h).wrap(ErrMalformedData)
+			}
+			if _, ok := grow[h]; !ok {
+				grow[h] = 0
+			}
+			if i > 0 {
+				parent[h] = e.Headers[i-1]
+			}
+		}
+		if len(e.Headers) > 0 {
+			grow[e.Headers[len(e.Headers)-1]] += len(e.Data) - (e.End - e.Start)
+		}
+	}
+
+	// headers within other headers come after them, so going from the last
+	// to the first handles the inner ones first.
+	headers := make([]int, 0, len(grow))
+	for h := range grow {
+		headers = append(headers, h)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(headers)))
+
+	edits := make([]patchEdit, len(p.Edits), len(p.Edits)+len(headers))
+	copy(edits, p.Edits)
+	for _, h := range headers {
+		hdr, err := decCountHeader(data[h:])
+		if err != nil {
+			return nil, errorDecf(h, "patch header: %v", err)
+		}
+		count, err := decInt[tLen](data[h:])
+		if err != nil {
+			return nil, errorDecf(h, "patch header: %v", err)
+		}
+		newCount := count.v + grow[h]
+		if hdr.v.IsNil() || hdr.v.Reference || count.v < 0 || newCount < 0 {
+			return nil, errorDecf(h, "patch header is not a count").wrap(ErrMalformedData)
+		}
+
+		newHdr := encCount(tLen(newCount), &hdr.v)
+		edits = append(edits, patchEdit{Start: h, End: h + count.n, Data: newHdr})
+		if up, ok := parent[h]; ok {
+			grow[up] += grow[h] + len(newHdr) - count.n
+		}
+	}
+
+	// insertions are made before anything else that starts at the same place.
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Start != edits[j].Start {
+			return edits[i].Start < edits[j].Start
+		}
+		return edits[i].End == edits[i].Start && edits[j].End != edits[j].Start
+	})
+
+	patched := make([]byte, 0, len(data))
+	var at int
+	for _, e := range edits {
+		if e.Start < at {
+			return nil, errorf("patch edits overlap at %d", e.Start).wrap(ErrMalformedData)
+		}
+		patched = append(patched, data[at:e.Start]...)
+		patched = append(patched, e.Data...)
+		at = e.End
+	}
+	patched = append(patched, data[at:]...)
+
+	return patched, nil
+}
@@ -0,0 +1,175 @@
+package rezi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Diff_Patch(t *testing.T) {
+	testCases := []struct {
+		name   string
+		from   interface{}
+		modify func(r *testRecord)
+		to     interface{}
+	}{
+		{name: "unchanged", modify: func(r *testRecord) {}},
+		{name: "int field", modify: func(r *testRecord) { r.ID = 612 }},
+		{name: "string field grows headers", modify: func(r *testRecord) { r.Name = strings.Repeat("Sburb", 80) }},
+		{name: "nested field", modify: func(r *testRecord) { r.Info.AverageAge = -1 }},
+		{name: "field added", modify: func(r *testRecord) { r.Note = "new" }},
+		{name: "map entry changed", modify: func(r *testRecord) { r.Scores["Terezi"] = 413 }},
+		{name: "map entry added", modify: func(r *testRecord) { r.Scores["Aradia"] = 1 }},
+		{name: "map entry removed", modify: func(r *testRecord) { delete(r.Scores, "Karkat") }},
+		{
+			name: "map entries added and removed",
+			modify: func(r *testRecord) {
+				delete(r.Scores, "Karkat")
+				r.Scores["Kanaya"] = 2
+				r.Scores["Zahhak"] = 3
+			},
+		},
+		{name: "map set to nil", modify: func(r *testRecord) { r.Scores = nil }},
+		{name: "slice item field", modify: func(r *testRecord) { r.Items[1].Count = 20 }},
+		{name: "slice appended", modify: func(r *testRecord) { r.Items = append(r.Items, testRecordItem{Name: "d"}) }},
+		{name: "slice item removed", modify: func(r *testRecord) { r.Items = append(r.Items[:1], r.Items[2:]...) }},
+		{
+			name: "slice item inserted at start",
+			modify: func(r *testRecord) {
+				r.Items = append([]testRecordItem{{Name: "z"}}, r.Items...)
+			},
+		},
+		{name: "slice emptied", modify: func(r *testRecord) { r.Items = []testRecordItem{} }},
+		{name: "pointer set", modify: func(r *testRecord) { r.Latest = &testRecordItem{Name: "latest"} }},
+		{name: "pointer set to nil", modify: func(r *testRecord) { r.Latest = nil }},
+		{name: "packed item", modify: func(r *testRecord) { r.Weights[0] = 1000 }},
+		{name: "array item", modify: func(r *testRecord) { r.Fixed[2] = "zz" }},
+		{
+			name: "several changes",
+			modify: func(r *testRecord) {
+				r.ID = 1
+				r.Info.Taxonomy[0] = strings.Repeat("i", 300)
+				r.Items[0].Name = "A"
+				r.Items[2].Count = 300
+				r.Scores["Vriska"] = 8
+			},
+		},
+		{name: "top-level int", from: 413, to: 612},
+		{name: "top-level slice", from: []string{"a", "b"}, to: []string{"a", "b", "c"}},
+		{name: "top-level pointer", from: &testRecordItem{Name: "a"}, to: &testRecordItem{Name: "b"}},
+		{name: "numbered struct", from: testNumberedStruct{Name: "a", Count: 1}, to: testNumberedStruct{Name: "a", Count: 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			from, to := tc.from, tc.to
+			if tc.modify != nil {
+				record := testRecordValue()
+				from = record
+
+				modified := testRecordValue()
+				tc.modify(&modified)
+				to = modified
+			}
+			data := MustEnc(from)
+			original := append([]byte(nil), data...)
+
+			patch, err := Diff(from, to)
+			if !assert.NoError(err) {
+				return
+			}
+			actual, err := Patch(data, patch)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(MustEnc(to), actual)
+			assert.Equal(original, data, "data was modified")
+		})
+	}
+}
+
+func Test_Diff_OnlyChanges(t *testing.T) {
+	t.Run("unchanged has no edits", func(t *testing.T) {
+		assert := assert.New(t)
+		record := testRecordValue()
+
+		patch, err := Diff(record, testRecordValue())
+		if !assert.NoError(err) {
+			return
+		}
+		var p patchData
+		if _, err := Dec(patch, &p); !assert.NoError(err) {
+			return
+		}
+
+		assert.Empty(p.Edits)
+	})
+
+	t.Run("one field of a large record", func(t *testing.T) {
+		assert := assert.New(t)
+		from := testRecordValue()
+		from.Scores = map[string]int{}
+		for i := 0; i < 200; i++ {
+			from.Items = append(from.Items, testRecordItem{Name: strings.Repeat("n", i%20), Count: i})
+			from.Scores[strings.Repeat("k", i%50)+string(rune('a'+i%26))] = i
+		}
+		to := testRecordValue()
+		to.Items = append([]testRecordItem(nil), from.Items...)
+		to.Scores = from.Scores
+		to.Items[150].Count = -150
+
+		patch, err := Diff(from, to)
+		if !assert.NoError(err) {
+			return
+		}
+		var p patchData
+		if _, err := Dec(patch, &p); !assert.NoError(err) {
+			return
+		}
+
+		assert.Len(p.Edits, 1)
+		assert.Less(len(patch), len(MustEnc(to))/20)
+	})
+}
+
+func Test_Diff_Errors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Diff(1, "1")
+
+	assert.ErrorIs(err, ErrInvalidType)
+}
+
+func Test_Patch_Errors(t *testing.T) {
+	from := testRecordValue()
+	to := testRecordValue()
+	to.ID = 612
+	patch, err := Diff(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		data  []byte
+		patch []byte
+	}{
+		{name: "different data", data: MustEnc(to), patch: patch},
+		{name: "truncated data", data: MustEnc(from)[:10], patch: patch},
+		{name: "malformed patch", data: MustEnc(from), patch: patch[:len(patch)/2]},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := Patch(tc.data, tc.patch)
+
+			assert.ErrorIs(err, ErrMalformedData)
+		})
+	}
+}
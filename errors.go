@@ -3,6 +3,7 @@ package rezi
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 )
 
@@ -46,8 +47,108 @@ var (
 	// decoded. Any error returned from this package that was caused by this
 	// will return true for the expression errors.Is(err, ErrMalformedData).
 	ErrMalformedData = errors.New("data cannot be interpretered")
+
+	// ErrMissingField indicates that a struct field with the required option
+	// in its rezi tag was absent from the data being decoded. Any error
+	// returned from this package that was caused by this will return true for
+	// the expression errors.Is(err, ErrMissingField).
+	ErrMissingField = errors.New("required field is missing from data")
+
+	// ErrRange indicates that a decoded number cannot be represented by the
+	// type it was being decoded to, such as 300 being decoded to an int8 or -1
+	// being decoded to a uint32. Any error returned from this package that was
+	// caused by this will return true for the expression
+	// errors.Is(err, ErrRange).
+	ErrRange = errors.New("decoded value is out of range of the receiver")
+
+	// ErrArrayLength indicates that data being decoded to an array has a
+	// different number of items than the array has, and the [ArrayLengthPolicy]
+	// in use does not allow it. Any error returned from this package that was
+	// caused by this will return true for the expression
+	// errors.Is(err, ErrArrayLength), and also for
+	// errors.Is(err, ErrMalformedData).
+	ErrArrayLength = errors.New("data does not have the same length as the array")
+
+	// ErrInvalidUTF8 indicates that a string being encoded or decoded is not
+	// valid UTF-8 and the [UTF8Policy] in use does not allow it. Any error
+	// returned from this package that was caused by this will return true for
+	// the expression errors.Is(err, ErrInvalidUTF8). If it was caused while
+	// decoding, it will also return true for errors.Is(err, ErrMalformedData).
+	ErrInvalidUTF8 = errors.New("string is not valid UTF-8")
+
+	// ErrNotFound indicates that the value at a path given to [DecPath] or
+	// [EncPath] is not in the data, such as when the path has a field that was
+	// not encoded, an index past the end of a slice, a key that is not in a
+	// map, or a nil pointer before its end. Any error returned from this
+	// package that was caused by this will return true for the expression
+	// errors.Is(err, ErrNotFound).
+	ErrNotFound = errors.New("value at path is not in data")
 )
 
+// DecodeError gives the details of a problem with data that occurred while it
+// was being decoded. Any error returned from this package that was caused by
+// such a problem can be converted to a DecodeError with the expression
+// errors.As(err, &decErr), where decErr is a *DecodeError.
+type DecodeError struct {
+	// Offset is the number of bytes into the data that the problem occurred.
+	Offset int
+
+	// Path is the sequence of steps from the top-level value being decoded to
+	// the value that could not be decoded. It is empty if the problem is with
+	// the top-level value itself.
+	Path FieldPath
+
+	// Type is the type of the value that could not be decoded. It is nil if the
+	// type could not be determined.
+	Type reflect.Type
+
+	// Err is the error returned from the decoding function.
+	Err error
+}
+
+// Error returns the message of the original error, which includes Offset.
+func (e *DecodeError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// PathStep is a single step into a struct, slice, array, or map on the way to a
+// value within decoded data.
+type PathStep struct {
+	// Kind is the kind of value that the step is taken into. It will be one of
+	// reflect.Struct, reflect.Slice, reflect.Array, or reflect.Map.
+	Kind reflect.Kind
+
+	// Field is the name of the field that the step is to. It is only set when
+	// Kind is reflect.Struct.
+	Field string
+
+	// Index is the index of the item that the step is to. It is only set when
+	// Kind is reflect.Slice or reflect.Array.
+	Index int
+
+	// Key is the key of the map value that the step is to. It is only set when
+	// Kind is reflect.Map.
+	Key interface{}
+}
+
+// String returns the step as it would appear in a Go selector or index
+// expression, such as ".Name", "[3]", or "[Name]".
+func (p PathStep) String() string {
+	switch p.Kind {
+	case reflect.Struct:
+		return "." + p.Field
+	case reflect.Map:
+		return fmt.Sprintf("[%v]", p.Key)
+	default:
+		return fmt.Sprintf("[%d]", p.Index)
+	}
+}
+
 // reziError is the concrete type of errors returned by all exported functions.
 // It is intended to be used and compared against error types with the errors.Is
 // API.
@@ -63,6 +164,13 @@ type reziError struct {
 	// internal use; set when a reziError is being wrapped and indicates it
 	// should not print its offset
 	hideOffset bool
+
+	// step is the step into a value that was taken to reach the value whose
+	// decoding caused the wrapped error. It is nil if no step was taken.
+	step *PathStep
+
+	// typ is the type of the value being decoded when the error occurred.
+	typ reflect.Type
 }
 
 // Wrapf takes an offset and applies it to an existing error returned from rezi.
@@ -179,6 +287,43 @@ func (e reziError) totalOffset() (offset int, ok bool) {
 	return e.offset, true
 }
 
+// path returns all steps taken through values to reach the value that caused
+// the error.
+func (e reziError) path() FieldPath {
+	var steps FieldPath
+	if e.step != nil {
+		steps = append(steps, *e.step)
+	}
+
+	for _, wrapped := range e.cause {
+		if reziErr, ok := wrapped.(reziError); ok {
+			return append(steps, reziErr.path()...)
+		}
+	}
+	return steps
+}
+
+// decodedType returns the type of the innermost value that was being decoded
+// when the error occurred, or nil if none is known.
+func (e reziError) decodedType() reflect.Type {
+	for _, wrapped := range e.cause {
+		if reziErr, ok := wrapped.(reziError); ok {
+			if t := reziErr.decodedType(); t != nil {
+				return t
+			}
+			break
+		}
+	}
+	return e.typ
+}
+
+// withStep returns a copy of e that records that the value whose decoding
+// caused it was reached by taking the given step.
+func (e reziError) withStep(step PathStep) reziError {
+	e.step = &step
+	return e
+}
+
 func (e reziError) wrap(wrapped ...error) reziError {
 	e.cause = append(e.cause, wrapped...)
 	for _, w := range wrapped {
@@ -232,6 +377,31 @@ func (e reziError) Unwrap() []error {
 	return wrapped
 }
 
+// As sets target to a *DecodeError that describes e if target is a
+// **DecodeError and e was caused by a problem with decoded data. Otherwise, it
+// returns false.
+//
+// This function is for interaction with the errors API.
+func (e reziError) As(target interface{}) bool {
+	decErr, ok := target.(**DecodeError)
+	if !ok {
+		return false
+	}
+
+	offset, ok := e.totalOffset()
+	if !ok {
+		return false
+	}
+
+	*decErr = &DecodeError{
+		Offset: offset,
+		Path:   e.path(),
+		Type:   e.decodedType(),
+		Err:    e,
+	}
+	return true
+}
+
 // Is returns whether Error either Is itself the given target error, or one of
 // its causes is.
 //
@@ -1,6 +1,7 @@
 package rezi
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"reflect"
@@ -290,7 +291,7 @@ func Test_reziError_totalOffset_string(t *testing.T) {
 
 			// create the error
 			var dest string
-			_, err := Dec(tc.input, &dest)
+			_, err := DecWithFormat(tc.input, &dest, &Format{InvalidUTF8: UTF8Reject})
 			if !assert.Error(err) {
 				return
 			}
@@ -344,11 +345,11 @@ func Test_reziError_totalOffset_binary(t *testing.T) {
 			name: "decode bin: string error",
 			input: []byte{
 				/* byte count = 11 */ 0x01, 0x0a,
-				/*  data  (string) */ 0x41, 0x80, 0x04, 0x41, 0x42, 0x43, 0xc3, // "ABC" followed by invalid seq, len=8
+				/*  data  (string) */ 0x41, 0x80, 0x08, 0x41, 0x42, 0x43, 0xc3, // "ABC" with byte count past the data, len=8
 				/* number (int32)  */ 0x01, 0x01, 0x0a, // 10, len=3
 			},
-			expectTotalOffset: 8,
-			expectErrText:     "data: invalid UTF-8 encoding",
+			expectTotalOffset: 5,
+			expectErrText:     "data: decoded string byte count",
 		},
 		{
 			name: "decode bin: number error",
@@ -457,7 +458,7 @@ func Test_reziError_totalOffset_struct(t *testing.T) {
 			assert := assert.New(t)
 
 			var dest testStructMultiMember
-			_, err := Dec(tc.input, &dest)
+			_, err := DecWithFormat(tc.input, &dest, &Format{InvalidUTF8: UTF8Reject})
 			if !assert.Error(err) {
 				return
 			}
@@ -528,7 +529,7 @@ func Test_reziError_totalOffset_text(t *testing.T) {
 			assert := assert.New(t)
 
 			var dest testText
-			_, err := Dec(tc.input, &dest)
+			_, err := DecWithFormat(tc.input, &dest, &Format{InvalidUTF8: UTF8Reject})
 			if !assert.Error(err) {
 				return
 			}
@@ -564,7 +565,7 @@ func Test_reziError_totalOffset_text(t *testing.T) {
 func Test_reziError_totalOffset_slice(t *testing.T) {
 	stringSlice := func(data []byte) error {
 		var sDest []string
-		_, err := Dec(data, &sDest)
+		_, err := DecWithFormat(data, &sDest, &Format{InvalidUTF8: UTF8Reject})
 		return err
 	}
 	intSlice := func(data []byte) error {
@@ -650,7 +651,7 @@ func Test_reziError_totalOffset_slice(t *testing.T) {
 func Test_reziError_totalOffset_array(t *testing.T) {
 	stringArr := func(data []byte) error {
 		var sDest [5]string
-		_, err := Dec(data, &sDest)
+		_, err := DecWithFormat(data, &sDest, &Format{InvalidUTF8: UTF8Reject})
 		return err
 	}
 	intArr := func(data []byte) error {
@@ -736,12 +737,12 @@ func Test_reziError_totalOffset_array(t *testing.T) {
 func Test_reziError_totalOffset_map(t *testing.T) {
 	stringIntMap := func(data []byte) error {
 		var siDest map[string]int
-		_, err := Dec(data, &siDest)
+		_, err := DecWithFormat(data, &siDest, &Format{InvalidUTF8: UTF8Reject})
 		return err
 	}
 	intStringMap := func(data []byte) error {
 		var isDest map[int]string
-		_, err := Dec(data, &isDest)
+		_, err := DecWithFormat(data, &isDest, &Format{InvalidUTF8: UTF8Reject})
 		return err
 	}
 
@@ -844,3 +845,148 @@ func Test_reziError_totalOffset_map(t *testing.T) {
 		})
 	}
 }
+
+type testDecodeErrorRecord struct {
+	Name   string
+	Tags   []string
+	Scores map[string]int
+}
+
+func Test_DecodeError(t *testing.T) {
+	record := testDecodeErrorRecord{
+		Name:   "Nepeta",
+		Tags:   []string{"a", "b", "z"},
+		Scores: map[string]int{"x": 8},
+	}
+	recordData := MustEnc(record)
+
+	// offsetOf gives the offset of the last byte of seq in the encoded record
+	offsetOf := func(seq ...byte) int {
+		return bytes.Index(recordData, seq) + len(seq) - 1
+	}
+
+	// replace the last byte of seq in the encoded record to corrupt it
+	corruptRecord := func(new byte, seq ...byte) []byte {
+		data := make([]byte, len(recordData))
+		copy(data, recordData)
+		data[offsetOf(seq...)] = new
+		return data
+	}
+
+	testCases := []struct {
+		name         string
+		input        []byte
+		dest         interface{}
+		expectOffset int
+		expectPath   FieldPath
+		expectStr    string
+		expectType   reflect.Type
+	}{
+		{
+			name:         "top-level value",
+			input:        []byte{0x02, 0x01},
+			dest:         new(int),
+			expectOffset: 1,
+			expectPath:   nil,
+			expectStr:    "",
+			expectType:   reflect.TypeOf(0),
+		},
+		{
+			name:         "struct field slice item",
+			input:        corruptRecord(0xc3, 'z'),
+			dest:         &testDecodeErrorRecord{},
+			expectOffset: offsetOf('z'),
+			expectPath: FieldPath{
+				{Kind: reflect.Struct, Field: "Tags"},
+				{Kind: reflect.Slice, Index: 2},
+			},
+			expectStr:  ".Tags[2]",
+			expectType: reflect.TypeOf(""),
+		},
+		{
+			name:         "struct field map value",
+			input:        corruptRecord(0x0f, 'x', 0x01),
+			dest:         &testDecodeErrorRecord{},
+			expectOffset: offsetOf('x', 0x01) + 1,
+			expectPath: FieldPath{
+				{Kind: reflect.Struct, Field: "Scores"},
+				{Kind: reflect.Map, Key: "x"},
+			},
+			expectStr:  ".Scores[x]",
+			expectType: reflect.TypeOf(0),
+		},
+		{
+			name: "array item",
+			input: []byte{
+				0x01, 0x04,
+				0x01, 0x01,
+				0x02, 0x01, // missing a byte
+			},
+			dest:         &[2]int{},
+			expectOffset: 5,
+			expectPath: FieldPath{
+				{Kind: reflect.Array, Index: 1},
+			},
+			expectStr:  "[1]",
+			expectType: reflect.TypeOf(0),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := DecWithFormat(tc.input, tc.dest, &Format{InvalidUTF8: UTF8Reject})
+			if !assert.Error(err) {
+				return
+			}
+
+			var decErr *DecodeError
+			if !assert.True(errors.As(err, &decErr), "error is not a DecodeError") {
+				return
+			}
+
+			assert.Equal(tc.expectOffset, decErr.Offset)
+			assert.Equal(tc.expectPath, decErr.Path)
+			assert.Equal(tc.expectStr, decErr.Path.String())
+			assert.Equal(tc.expectType, decErr.Type)
+			assert.Equal(err.Error(), decErr.Error())
+			assert.ErrorIs(decErr, Error)
+		})
+	}
+
+	t.Run("encoding error is not a DecodeError", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := Enc(testBinary{number: 8, encErr: errors.New("bad")})
+		if !assert.Error(err) {
+			return
+		}
+
+		var decErr *DecodeError
+		assert.False(errors.As(err, &decErr))
+	})
+}
+
+func Test_PathStep_String(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  PathStep
+		expect string
+	}{
+		{name: "struct", input: PathStep{Kind: reflect.Struct, Field: "Name"}, expect: ".Name"},
+		{name: "slice", input: PathStep{Kind: reflect.Slice, Index: 3}, expect: "[3]"},
+		{name: "array", input: PathStep{Kind: reflect.Array, Index: 0}, expect: "[0]"},
+		{name: "map", input: PathStep{Kind: reflect.Map, Key: "Terezi"}, expect: "[Terezi]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual := tc.input.String()
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package rezi
+
+// fieldids.go contains functions for encoding and decoding the keys of struct
+// fields that are identified by a numeric ID rather than by name.
+
+import (
+	"io"
+)
+
+// numberedFieldsVersion is the version given in the EXT byte of a struct that
+// has at least one field with an ID.
+const numberedFieldsVersion = 3
+
+// valueShape is how the length of an encoded value can be found without
+// knowing its type. It is given in the key of each numbered field so that
+// fields with unknown IDs can be skipped.
+type valueShape int
+
+const (
+	// shapeSized is a value whose INFO byte gives the number of bytes that
+	// follow it, such as an integer or a float.
+	shapeSized valueShape = iota
+
+	// shapeByte is a value that is a single byte, which is a bool.
+	shapeByte
+
+	// shapeCounted is a value whose header is followed by an integer giving
+	// the number of bytes after it, such as a string, slice, or struct.
+	shapeCounted
+
+	numValueShapes
+)
+
+// shapeOf returns the valueShape of values with the given type info.
+func shapeOf(ti typeInfo) valueShape {
+	switch ti.Main {
+	case mtIntegral, mtFloat:
+		return shapeSized
+	case mtBool:
+		return shapeByte
+	default:
+		return shapeCounted
+	}
+}
+
+// encFieldKey encodes the key of a numbered field. It is the ID of the field
+// shifted left by 2 bits, with the valueShape of the field in the low 2 bits.
+func encFieldKey(fi fieldInfo) []byte {
+	key := fi.ID<<2 | int(shapeOf(fi.Type))
+	return encInt(analyzed[tLen]{v: key})
+}
+
+// decFieldKey decodes the key of a numbered field.
+func decFieldKey(data []byte) (id int, shape valueShape, n int, err error) {
+	key, err := decInt[tLen](data)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	id = key.v >> 2
+	shape = valueShape(key.v & 0x03)
+	if id < 1 || shape >= numValueShapes {
+		return 0, 0, 0, errorDecf(0, "invalid field key %d", key.v).wrap(ErrMalformedData)
+	}
+	return id, shape, key.n, nil
+}
+
+// skipValue returns the number of bytes taken up by the encoded value at the
+// start of data, which has the given shape.
+func skipValue(data []byte, shape valueShape) (int, error) {
+	if shape == shapeByte && len(data) > 0 && (data[0] == 0x00 || data[0] == 0x01) {
+		return 1, nil
+	}
+
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return 0, err
+	}
+
+	// nils have nothing after the header, and references and sized values
+	// have nothing after the bytes that the header gives the length of.
+	var n int
+	if hdr.v.NilAt > 0 {
+		n = hdr.n
+	} else if hdr.v.Reference || shape != shapeCounted {
+		n = hdr.n + hdr.v.Length
+	} else {
+		count, err := decInt[tLen](data)
+		if err != nil {
+			return 0, err
+		}
+		if count.v < 0 {
+			return 0, errorDecf(0, "count < 0").wrap(ErrMalformedData)
+		}
+		n = count.n + count.v
+	}
+
+	if n > len(data) {
+		return 0, errorDecf(0, "%s", io.ErrUnexpectedEOF).wrap(ErrMalformedData)
+	}
+	return n, nil
+}
@@ -0,0 +1,172 @@
+package rezi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testNumberedStruct struct {
+	Count int    `rezi:",id=1"`
+	Name  string `rezi:",id=2"`
+	On    bool   `rezi:",id=3"`
+	Note  string
+}
+
+// testRenamedNumberedStruct has the same fields as testNumberedStruct, but renamed
+// and without Note.
+type testRenamedNumberedStruct struct {
+	Total   int    `rezi:",id=1"`
+	Label   string `rezi:",id=2"`
+	Enabled bool   `rezi:",id=3"`
+	Note    string
+}
+
+func Test_Enc_NumberedStruct(t *testing.T) {
+	assert := assert.New(t)
+	input := testNumberedStruct{Count: 300, Name: "a", On: true, Note: "b"}
+	expect := []byte{
+		0x41, 0x03, 0x19, // len=25, numbered
+		0x01, 0x04, // id=1, sized
+		0x02, 0x01, 0x2c, // 300
+		0x01, 0x0a, // id=2, counted
+		0x41, 0x82, 0x01, 0x61, // "a"
+		0x41, 0x82, 0x04, 0x4e, 0x6f, 0x74, 0x65, // "Note"
+		0x41, 0x82, 0x01, 0x62, // "b"
+		0x01, 0x0d, // id=3, byte
+		0x01, // true
+	}
+
+	actual, err := Enc(input)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal(expect, actual)
+}
+
+func Test_Dec_NumberedStruct(t *testing.T) {
+	t.Run("renamed fields", func(t *testing.T) {
+		assert := assert.New(t)
+		input := testNumberedStruct{Count: 300, Name: "a", On: true, Note: "b"}
+		expect := testRenamedNumberedStruct{Total: 300, Label: "a", Enabled: true, Note: "b"}
+
+		data, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testRenamedNumberedStruct
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("unknown ids are skipped", func(t *testing.T) {
+		assert := assert.New(t)
+		type removed struct {
+			ID      int                `rezi:",id=1"`
+			Float   float64            `rezi:",id=2"`
+			Flag    bool               `rezi:",id=3"`
+			FlagPtr *bool              `rezi:",id=4"`
+			Nums    []int              `rezi:",id=5"`
+			Packed  []int              `rezi:",id=6,packed"`
+			Map     map[string]float32 `rezi:",id=7"`
+			Sub     testNumberedStruct `rezi:",id=8"`
+			NilPtr  *string            `rezi:",id=9"`
+			Last    string             `rezi:",id=10"`
+		}
+		type kept struct {
+			ID   int    `rezi:",id=1"`
+			Last string `rezi:",id=10"`
+		}
+		flag := false
+		input := removed{
+			ID:      -4,
+			Float:   2.5,
+			Flag:    true,
+			FlagPtr: &flag,
+			Nums:    []int{1, 2, 3},
+			Packed:  []int{4, 5},
+			Map:     map[string]float32{"x": 1.5},
+			Sub:     testNumberedStruct{Count: 1, Name: "n", Note: "o"},
+			Last:    "end",
+		}
+
+		data, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual kept
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(kept{ID: -4, Last: "end"}, actual)
+	})
+
+	t.Run("unnumbered receiver", func(t *testing.T) {
+		assert := assert.New(t)
+		type unnumbered struct {
+			Count int
+			Note  string
+		}
+		input := testNumberedStruct{Count: 300, Name: "a", On: true, Note: "b"}
+
+		data, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual unnumbered
+		_, err = Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(unnumbered{Note: "b"}, actual)
+	})
+
+	t.Run("unnumbered data", func(t *testing.T) {
+		assert := assert.New(t)
+		type unnumbered struct {
+			Count int
+			Note  string
+		}
+		input := unnumbered{Count: 300, Note: "b"}
+
+		data, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testNumberedStruct
+		_, err = Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(testNumberedStruct{Count: 300, Note: "b"}, actual)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		assert := assert.New(t)
+		data := []byte{
+			0x41, 0x03, 0x03, // len=3, numbered
+			0x01, 0x03, // id=0
+			0x00,
+		}
+
+		var actual testNumberedStruct
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+}
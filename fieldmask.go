@@ -0,0 +1,148 @@
+package rezi
+
+// fieldmask.go contains functions for encoding only selected fields of the
+// structs within a value.
+
+import (
+	"reflect"
+	"strings"
+)
+
+// fieldMask gives the fields of a struct that are selected to be encoded. Each
+// selected field maps to the mask for the structs within it, which is nil if
+// all of their fields are selected.
+type fieldMask map[string]fieldMask
+
+// newFieldMask returns the mask that selects the fields with the given paths
+// within a value of type t with type info ti. Each path is a sequence of field
+// names separated by dots, such as "Info.AverageAge"; a leading dot is
+// optional. Slices, arrays, maps, and pointers between the structs on a path
+// are passed through, so a path selects the field in every struct they hold.
+func newFieldMask(t reflect.Type, ti typeInfo, paths []string) (fieldMask, error) {
+	mask := fieldMask{}
+
+	for _, path := range paths {
+		names := strings.Split(strings.TrimPrefix(path, "."), ".")
+
+		m := mask
+		cur, curType := ti, t
+		for i, name := range names {
+			if name == "" {
+				return nil, errorf("invalid field %q: empty field name", path).wrap(ErrInvalidType)
+			}
+			st, stType, ok := maskedStruct(curType, cur)
+			if !ok {
+				return nil, errorf("invalid field %q: .%s is not a struct", path, strings.Join(names[:i], ".")).wrap(ErrInvalidType)
+			}
+			fi, ok := st.Fields.ByName[name]
+			if !ok {
+				return nil, errorf("invalid field %q: struct has no field .%s", path, name).wrap(ErrInvalidType)
+			}
+
+			sub, selected := m[name]
+			if selected && sub == nil {
+				// all of the field is already selected
+				break
+			}
+			if i == len(names)-1 {
+				m[name] = nil
+				break
+			}
+			if sub == nil {
+				sub = fieldMask{}
+				m[name] = sub
+			}
+			m = sub
+			cur, curType = fi.Type, stType.FieldByIndex(fi.Index).Type
+		}
+	}
+
+	return mask, nil
+}
+
+// maskedStruct returns the type info and type of the structs that a mask
+// applies to within a value of type t with type info ti, which are either those
+// of the value itself or of the items of the slices, arrays, and maps it holds.
+// A struct that implements Marshaler is given by its fields, as its MarshalREZI
+// method may apply the mask with [Writer.EncField].
+func maskedStruct(t reflect.Type, ti typeInfo) (typeInfo, reflect.Type, bool) {
+	t = derefType(t)
+	for ti.Main == mtSlice || ti.Main == mtArray || ti.Main == mtMap {
+		ti = *ti.ValType
+		t = derefType(t.Elem())
+	}
+
+	if ti.Main == mtRezi && t.Kind() == reflect.Struct {
+		info, err := encStructTypeInfo(t)
+		if err != nil {
+			return ti, t, false
+		}
+		ti = info
+	}
+	return ti, t, ti.Main == mtStruct
+}
+
+// derefType returns the type that t points to through any number of pointers.
+// It returns nil if t is nil.
+func derefType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// selects returns whether the mask selects the field with the given name. A
+// nil mask selects every field.
+func (m fieldMask) selects(name string) bool {
+	if m == nil {
+		return true
+	}
+	_, ok := m[name]
+	return ok
+}
+
+// selectsField returns whether the mask of the session selects the field with
+// the given name.
+func (s *session) selectsField(name string) bool {
+	return s == nil || s.mask.selects(name)
+}
+
+// selectedFields returns the fields that are selected by the mask of the
+// session, along with every field that is required or has a default. Those are
+// kept so that they are not absent when decoded, and are encoded as the zero
+// value if they are not selected.
+func (s *session) selectedFields(fields []fieldInfo) []fieldInfo {
+	if s == nil || s.mask == nil {
+		return fields
+	}
+
+	var selected []fieldInfo
+	for _, fi := range fields {
+		if s.selectsField(fi.Name) || fi.Required || fi.Default.IsValid() {
+			selected = append(selected, fi)
+		}
+	}
+	return selected
+}
+
+// enterField sets the mask of the session to the one for the structs within
+// the field with the given name until the returned function is called.
+func (s *session) enterField(name string) (leave func()) {
+	if s == nil || s.mask == nil {
+		return func() {}
+	}
+	m := s.mask
+	s.mask = m[name]
+	return func() { s.mask = m }
+}
+
+// suspendMask stops the mask of the session from being applied until the
+// returned function is called.
+func (s *session) suspendMask() (resume func()) {
+	if s == nil || s.mask == nil {
+		return func() {}
+	}
+	m := s.mask
+	s.mask = nil
+	return func() { s.mask = m }
+}
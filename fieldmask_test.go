@@ -0,0 +1,198 @@
+package rezi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newFieldMask(t *testing.T) {
+	info, err := encTypeInfo(reflect.TypeOf(testRecord{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name      string
+		paths     []string
+		expect    fieldMask
+		expectErr bool
+	}{
+		{name: "none", paths: nil, expect: fieldMask{}},
+		{name: "field", paths: []string{"Name"}, expect: fieldMask{"Name": nil}},
+		{name: "leading dot", paths: []string{".Name"}, expect: fieldMask{"Name": nil}},
+		{
+			name:   "nested",
+			paths:  []string{"Info.AverageAge"},
+			expect: fieldMask{"Info": {"AverageAge": nil}},
+		},
+		{
+			name:   "whole field after nested",
+			paths:  []string{"Info.AverageAge", "Info"},
+			expect: fieldMask{"Info": nil},
+		},
+		{
+			name:   "nested after whole field",
+			paths:  []string{"Info", "Info.AverageAge"},
+			expect: fieldMask{"Info": nil},
+		},
+		{
+			name:   "through slice, pointer, and map",
+			paths:  []string{"Items.Name", "Latest.Count", "ByKey.Name"},
+			expect: fieldMask{"Items": {"Name": nil}, "Latest": {"Count": nil}, "ByKey": {"Name": nil}},
+		},
+		{name: "unknown field", paths: []string{"Nope"}, expectErr: true},
+		{name: "unknown nested field", paths: []string{"Info.Nope"}, expectErr: true},
+		{name: "step into non-struct", paths: []string{"Name.Length"}, expectErr: true},
+		{name: "empty field name", paths: []string{"Info..AverageAge"}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := newFieldMask(reflect.TypeOf(testRecord{}), info, tc.paths)
+			if tc.expectErr {
+				assert.ErrorIs(err, ErrInvalidType)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_EncFields(t *testing.T) {
+	record := testRecordValue()
+
+	testCases := []struct {
+		name   string
+		input  interface{}
+		fields []string
+		expect []byte
+	}{
+		{
+			name:   "top-level fields",
+			input:  record,
+			fields: []string{"Name", "Items"},
+			expect: MustEnc(struct {
+				Name  string
+				Items []testRecordItem
+			}{Name: record.Name, Items: record.Items}),
+		},
+		{
+			name:   "nested field",
+			input:  record,
+			fields: []string{"Name", "Info.AverageAge"},
+			expect: MustEnc(struct {
+				Name string
+				Info struct{ AverageAge int }
+			}{Name: record.Name, Info: struct{ AverageAge int }{AverageAge: 6}}),
+		},
+		{
+			name:   "through slice and pointer",
+			input:  record,
+			fields: []string{"Items.Count", "Latest.Name"},
+			expect: MustEnc(struct {
+				Items  []struct{ Count int }
+				Latest *struct{ Name string }
+			}{
+				Items:  []struct{ Count int }{{Count: 1}, {Count: 2}, {Count: 3}},
+				Latest: &struct{ Name string }{Name: "strife"},
+			}),
+		},
+		{
+			name:   "through map value",
+			input:  record,
+			fields: []string{"ByKey.Count"},
+			expect: MustEnc(struct {
+				ByKey map[string]struct{ Count int }
+			}{ByKey: map[string]struct{ Count int }{"k": {Count: 5}}}),
+		},
+		{
+			name:   "no fields",
+			input:  record,
+			fields: nil,
+			expect: MustEnc(struct{}{}),
+		},
+		{
+			name:   "top-level slice",
+			input:  record.Items,
+			fields: []string{"Name"},
+			expect: MustEnc([]struct{ Name string }{{Name: "lusus"}, {Name: "husktop"}, {Name: "sylladex"}}),
+		},
+		{
+			name:   "struct map keys are kept whole",
+			input:  map[testRecordItem]testRecordItem{{Name: "k", Count: 5}: {Name: "v", Count: 6}},
+			fields: []string{"Count"},
+			expect: MustEnc(map[testRecordItem]struct{ Count int }{{Name: "k", Count: 5}: {Count: 6}}),
+		},
+		{
+			name:   "tuple fields are given the zero value",
+			input:  testStructTuple{Value: 8, Name: "Vriska"},
+			fields: []string{"Name"},
+			expect: MustEnc(testStructTuple{Name: "Vriska"}),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := EncFields(tc.input, tc.fields...)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+
+	t.Run("decodes to the original type", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testRecord{Name: record.Name, Info: testRecordInfo{AverageAge: 6}}
+
+		data, err := EncFields(record, "Name", "Info.AverageAge")
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testRecord
+		_, err = Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("with format", func(t *testing.T) {
+		assert := assert.New(t)
+		f := &Format{FieldNameTable: true}
+		expect, err := EncWithFormat(struct{ Items []struct{ Name string } }{
+			Items: []struct{ Name string }{{Name: "lusus"}, {Name: "husktop"}, {Name: "sylladex"}},
+		}, f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		actual, err := EncFieldsWithFormat(record, f, "Items.Name")
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		assert := assert.New(t)
+
+		_, err := EncFields(record, "Info.Nope")
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+}
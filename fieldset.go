@@ -0,0 +1,121 @@
+package rezi
+
+// fieldset.go contains functions for recording which struct fields were present
+// in decoded data.
+
+import (
+	"reflect"
+	"strings"
+)
+
+// FieldPath is the sequence of steps from the top-level value being decoded to
+// a value within it. In a [FieldSet], the last step is always into a struct.
+type FieldPath []PathStep
+
+// String returns the path as it would appear in a Go selector expression on the
+// top-level value, such as ".Name", ".Items[3].Name", or "[Karkat].Age".
+func (p FieldPath) String() string {
+	var sb strings.Builder
+	for _, step := range p {
+		sb.WriteString(step.String())
+	}
+	return sb.String()
+}
+
+// FieldSet gives the fields of every struct that was decoded by a call to
+// [DecWithFields] that were and were not present in the decoded data. This
+// allows a field that was decoded as the zero value of its type to be told
+// apart from a field that was not given at all.
+//
+// Fields that are absent from the data are in Absent even when they were set
+// to a default value. Fields of structs decoded as map keys are not included.
+type FieldSet struct {
+	// Present holds the path of every field that was in the data. The fields
+	// of a struct are given after the fields of any structs within them.
+	Present []FieldPath
+
+	// Absent holds the path of every field that was not in the data.
+	Absent []FieldPath
+}
+
+// Has returns whether the field with the given path was present in the decoded
+// data. The path is given in the same form returned by [FieldPath.String],
+// except that the leading dot is optional as it is for [DecPath]. Has returns
+// false if the path is not valid.
+func (fs FieldSet) Has(path string) bool {
+	parts, err := parsePath(path)
+	if err != nil {
+		return false
+	}
+	var sb strings.Builder
+	for _, part := range parts {
+		sb.WriteString(part.String())
+	}
+	path = sb.String()
+
+	for _, p := range fs.Present {
+		if p.String() == path {
+			return true
+		}
+	}
+	return false
+}
+
+// enterStep records that the value about to be decoded is reached by taking
+// the given step. It has no effect unless fields are being recorded.
+func (s *session) enterStep(step PathStep) {
+	if s == nil || s.fieldSet == nil {
+		return
+	}
+	s.path = append(s.path, step)
+}
+
+// leaveStep undoes the last call to enterStep.
+func (s *session) leaveStep() {
+	if s == nil || s.fieldSet == nil {
+		return
+	}
+	s.path = s.path[:len(s.path)-1]
+}
+
+// suspendFields stops struct fields from being recorded until the returned
+// function is called.
+func (s *session) suspendFields() (resume func()) {
+	if s == nil || s.fieldSet == nil {
+		return func() {}
+	}
+	fs := s.fieldSet
+	s.fieldSet = nil
+	return func() { s.fieldSet = fs }
+}
+
+// recordFields records every field of a decoded struct as either present or
+// absent, based on whether it is in decodedFields. It has no effect unless
+// fields are being recorded.
+func (s *session) recordFields(fs *fields, decodedFields []fieldInfo) {
+	if s == nil || s.fieldSet == nil {
+		return
+	}
+
+	fieldPath := func(name string) FieldPath {
+		p := make(FieldPath, len(s.path), len(s.path)+1)
+		copy(p, s.path)
+		return append(p, PathStep{Kind: reflect.Struct, Field: name})
+	}
+
+	present := map[string]bool{}
+	for _, fi := range decodedFields {
+		if _, ok := fs.ByName[fi.Name]; !ok || present[fi.Name] {
+			// embedded structs decoded as a whole are not themselves fields
+			continue
+		}
+		present[fi.Name] = true
+		s.fieldSet.Present = append(s.fieldSet.Present, fieldPath(fi.Name))
+	}
+
+	for _, fi := range fs.ByOrder {
+		if !present[fi.Name] {
+			s.fieldSet.Absent = append(s.fieldSet.Absent, fieldPath(fi.Name))
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package rezi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testFieldSetItem struct {
+	Name  string
+	Count int
+}
+
+type testFieldSetOuter struct {
+	Items []testFieldSetItem
+	ByKey map[string]testFieldSetItem
+	Item  *testFieldSetItem
+	Title string
+}
+
+func Test_FieldPath_String(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  FieldPath
+		expect string
+	}{
+		{
+			name:   "top-level field",
+			input:  FieldPath{{Kind: reflect.Struct, Field: "Name"}},
+			expect: ".Name",
+		},
+		{
+			name: "field in slice item",
+			input: FieldPath{
+				{Kind: reflect.Struct, Field: "Items"},
+				{Kind: reflect.Slice, Index: 3},
+				{Kind: reflect.Struct, Field: "Name"},
+			},
+			expect: ".Items[3].Name",
+		},
+		{
+			name: "field in map value",
+			input: FieldPath{
+				{Kind: reflect.Map, Key: "Karkat"},
+				{Kind: reflect.Struct, Field: "Age"},
+			},
+			expect: "[Karkat].Age",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			assert.Equal(tc.expect, tc.input.String())
+		})
+	}
+}
+
+func Test_FieldSet_Has(t *testing.T) {
+	fs := FieldSet{
+		Present: []FieldPath{
+			{{Kind: reflect.Struct, Field: "B"}, {Kind: reflect.Struct, Field: "C"}},
+			{{Kind: reflect.Struct, Field: "Items"}, {Kind: reflect.Slice, Index: 3}, {Kind: reflect.Struct, Field: "Name"}},
+			{{Kind: reflect.Map, Key: "Karkat"}, {Kind: reflect.Struct, Field: "Age"}},
+		},
+		Absent: []FieldPath{
+			{{Kind: reflect.Struct, Field: "D"}},
+		},
+	}
+
+	testCases := []struct {
+		name   string
+		input  string
+		expect bool
+	}{
+		{name: "nested field", input: ".B.C", expect: true},
+		{name: "nested field without leading dot", input: "B.C", expect: true},
+		{name: "field in slice item", input: "Items[3].Name", expect: true},
+		{name: "field in map value", input: "[Karkat].Age", expect: true},
+		{name: "field in map value with leading dot", input: ".[Karkat].Age", expect: true},
+		{name: "struct holding present field", input: "B", expect: false},
+		{name: "absent field", input: "D", expect: false},
+		{name: "invalid path", input: "B..C", expect: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual := fs.Has(tc.input)
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_DecWithFields(t *testing.T) {
+	pathStrings := func(paths []FieldPath) []string {
+		var strs []string
+		for _, p := range paths {
+			strs = append(strs, p.String())
+		}
+		return strs
+	}
+
+	t.Run("zero value is present", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testFieldSetItem{Name: "", Count: 0})
+
+		var actual testFieldSetItem
+		fs, n, err := DecWithFields(input, &actual, nil)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(input), n)
+		assert.Equal([]string{".Count", ".Name"}, pathStrings(fs.Present))
+		assert.Empty(fs.Absent)
+		assert.True(fs.Has(".Count"))
+	})
+
+	t.Run("omitted field is absent", func(t *testing.T) {
+		assert := assert.New(t)
+		input, err := EncWithFormat(testFieldSetItem{Name: "Sollux"}, &Format{OmitEmpty: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		actual := testFieldSetItem{Count: 2}
+		fs, _, err := DecWithFields(input, &actual, nil)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(testFieldSetItem{Name: "Sollux", Count: 2}, actual)
+		assert.Equal([]string{".Name"}, pathStrings(fs.Present))
+		assert.Equal([]string{".Count"}, pathStrings(fs.Absent))
+		assert.False(fs.Has(".Count"))
+	})
+
+	t.Run("nested structs", func(t *testing.T) {
+		assert := assert.New(t)
+		value := testFieldSetOuter{
+			Items: []testFieldSetItem{{Name: "a"}},
+			ByKey: map[string]testFieldSetItem{"k": {Count: 1}},
+			Item:  &testFieldSetItem{Name: "b", Count: 2},
+		}
+		input, err := EncWithFormat(value, &Format{OmitEmpty: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testFieldSetOuter
+		fs, _, err := DecWithFields(input, &actual, nil)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(value, actual)
+		assert.Equal([]string{
+			".ByKey[k].Count",
+			".Item.Count",
+			".Item.Name",
+			".Items[0].Name",
+			".ByKey",
+			".Item",
+			".Items",
+		}, pathStrings(fs.Present))
+		assert.Equal([]string{
+			".ByKey[k].Name",
+			".Items[0].Count",
+			".Title",
+		}, pathStrings(fs.Absent))
+	})
+
+	t.Run("map keys are not included", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(map[testFieldSetItem]int{{Name: "a", Count: 1}: 1})
+
+		var actual map[testFieldSetItem]int
+		fs, _, err := DecWithFields(input, &actual, nil)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Empty(fs.Present)
+		assert.Empty(fs.Absent)
+	})
+}
@@ -0,0 +1,312 @@
+package rezi
+
+// keys.go contains functions for encoding and decoding values as keys whose
+// bytes sort in the same order as the values.
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+const (
+	// keyNumberSize is the number of bytes in an encoded integer or float key,
+	// regardless of the size of its type.
+	keyNumberSize = 8
+
+	// keySignBit is the bit that is flipped in integers and floats so that
+	// negative numbers sort before positive ones.
+	keySignBit = 1 << 63
+
+	// keyEscape starts a two-byte sequence in an encoded string key. It is
+	// followed by keyEscapedZero for a 0x00 byte in the string, or by
+	// keyStringEnd at the end of the string.
+	keyEscape      = 0x00
+	keyEscapedZero = 0xff
+	keyStringEnd   = 0x01
+)
+
+// EncKey encodes v as a key for use in a sorted store, such as the keys of a
+// sorted key-value database. Unlike the data returned by [Enc], the bytes of
+// encoded keys sort in the same order as the values they were encoded from
+// when compared with bytes.Compare.
+//
+// Only bools, integers, floats, strings, and structs and arrays made up only of
+// those types can be encoded as keys; for anything else, the returned error
+// will match [ErrInvalidType]. Integers are sorted by their value regardless of
+// their size, false sorts before true, and strings are sorted by their bytes.
+// Floats are sorted with negative zero before positive zero and with NaN
+// values after positive infinity. Structs and arrays are sorted by their first
+// field or item, then their second, and so on, with the fields of a struct in
+// the order they are declared in. An embedded struct behind a nil
+// pointer is encoded as the zero value. v may be a pointer to such a value,
+// but the value itself cannot contain pointers.
+//
+// Keys are decoded with [DecKey], and must be decoded to the same type that
+// they were encoded from, with the exception of numbers: signed integers can be
+// decoded to any signed integer type that can hold them, unsigned integers to
+// any unsigned integer type that can hold them, and floats to either float
+// type.
+func EncKey(v interface{}) (key []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	info, err := canEncode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	refVal := reflect.ValueOf(v)
+	for i := 0; i < info.Indir; i++ {
+		if refVal.IsNil() {
+			return nil, errorf("nil pointer cannot be encoded as a key").wrap(ErrInvalidType)
+		}
+		refVal = refVal.Elem()
+	}
+	info.Indir = 0
+
+	if err := checkKeyType(refVal.Type(), info); err != nil {
+		return nil, err
+	}
+	return encKey(nil, refVal, info), nil
+}
+
+// DecKey decodes a key that was encoded with [EncKey] from the start of data
+// to v, which must be a pointer to a value of the type it was encoded from. It
+// returns the number of bytes of data that the key took up.
+//
+// A decoded number that does not fit in the type it is decoded to results in an
+// error matching [ErrRange].
+func DecKey(data []byte, v interface{}) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	info, err := canDecode(v)
+	if err != nil {
+		return 0, err
+	}
+	refVal := reflect.ValueOf(v)
+	if refVal.Kind() != reflect.Pointer {
+		return 0, errorf("receiver is not a pointer").wrap(ErrInvalidType)
+	}
+	refVal = refVal.Elem()
+
+	if info.Indir > 0 {
+		return 0, errorf("key cannot be decoded to pointer type %s", refVal.Type()).wrap(ErrInvalidType)
+	}
+	if err := checkKeyType(refVal.Type(), info); err != nil {
+		return 0, err
+	}
+	return decKey(data, refVal, info)
+}
+
+// checkKeyType returns an error if values of type t, which has type info ti,
+// cannot be encoded as keys.
+func checkKeyType(t reflect.Type, ti typeInfo) error {
+	if ti.Indir > 0 {
+		return errorf("%s cannot be encoded as a key as it is a pointer", t).wrap(ErrInvalidType)
+	}
+
+	switch ti.Main {
+	case mtBool, mtIntegral, mtFloat, mtString:
+		return nil
+	case mtStruct:
+		for _, fi := range ti.Fields.ByOrder {
+			ft := t.FieldByIndex(fi.Index).Type
+			if err := checkKeyType(ft, fi.Type); err != nil {
+				return errorf("field .%s: %v", fi.Name, err)
+			}
+		}
+		return nil
+	case mtArray:
+		return checkKeyType(t.Elem(), *ti.ValType)
+	default:
+		return errorf("%s cannot be encoded as a key", t).wrap(ErrInvalidType)
+	}
+}
+
+// keyFields returns the fields of a struct in the order they are declared in,
+// which is the order they are encoded in as a key.
+func keyFields(fs *fields) []fieldInfo {
+	ordered := make([]fieldInfo, len(fs.ByOrder))
+	copy(ordered, fs.ByOrder)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i].Index, ordered[j].Index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return ordered
+}
+
+// encKey appends the key encoding of v, which has type info ti, to dst. The
+// type of v must have been checked with checkKeyType.
+func encKey(dst []byte, v reflect.Value, ti typeInfo) []byte {
+	switch ti.Main {
+	case mtBool:
+		if v.Bool() {
+			return append(dst, 0x01)
+		}
+		return append(dst, 0x00)
+	case mtIntegral:
+		var u uint64
+		if ti.Signed {
+			u = uint64(v.Int()) ^ keySignBit
+		} else {
+			u = v.Uint()
+		}
+		return appendKeyUint64(dst, u)
+	case mtFloat:
+		// negative floats have all bits flipped so that larger magnitudes
+		// sort first; positive ones only need to sort after them.
+		bits := math.Float64bits(v.Float())
+		if bits&keySignBit != 0 {
+			bits = ^bits
+		} else {
+			bits |= keySignBit
+		}
+		return appendKeyUint64(dst, bits)
+	case mtString:
+		s := v.String()
+		for i := 0; i < len(s); i++ {
+			if s[i] == keyEscape {
+				dst = append(dst, keyEscape, keyEscapedZero)
+			} else {
+				dst = append(dst, s[i])
+			}
+		}
+		return append(dst, keyEscape, keyStringEnd)
+	case mtStruct:
+		for _, fi := range keyFields(ti.Fields) {
+			fv, ok := fieldByIndex(v, fi.Index)
+			if !ok {
+				fv = reflect.Zero(v.Type().FieldByIndex(fi.Index).Type)
+			}
+			dst = encKey(dst, fv, fi.Type)
+		}
+		return dst
+	case mtArray:
+		for i := 0; i < v.Len(); i++ {
+			dst = encKey(dst, v.Index(i), *ti.ValType)
+		}
+		return dst
+	default:
+		panic("not a key type")
+	}
+}
+
+// appendKeyUint64 appends u to dst as a big-endian integer.
+func appendKeyUint64(dst []byte, u uint64) []byte {
+	var buf [keyNumberSize]byte
+	binary.BigEndian.PutUint64(buf[:], u)
+	return append(dst, buf[:]...)
+}
+
+// decKey decodes the key at the start of data to v, which has type info ti and
+// must be settable. The type of v must have been checked with checkKeyType.
+func decKey(data []byte, v reflect.Value, ti typeInfo) (int, error) {
+	switch ti.Main {
+	case mtBool:
+		if len(data) < 1 {
+			return 0, errorDecf(0, "%s", io.ErrUnexpectedEOF).wrap(ErrMalformedData)
+		}
+		if data[0] > 0x01 {
+			return 0, errorDecf(0, "not a bool value 0x00 or 0x01: %#02x", data[0]).wrap(ErrMalformedData)
+		}
+		v.SetBool(data[0] == 0x01)
+		return 1, nil
+	case mtIntegral:
+		if len(data) < keyNumberSize {
+			return 0, errorDecf(0, "%s", io.ErrUnexpectedEOF).wrap(ErrMalformedData)
+		}
+		u := binary.BigEndian.Uint64(data)
+		if ti.Signed {
+			i := int64(u ^ keySignBit)
+			if v.OverflowInt(i) {
+				return 0, errorDecf(0, "%d overflows %s", i, v.Type()).wrap(ErrRange)
+			}
+			v.SetInt(i)
+		} else {
+			if v.OverflowUint(u) {
+				return 0, errorDecf(0, "%d overflows %s", u, v.Type()).wrap(ErrRange)
+			}
+			v.SetUint(u)
+		}
+		return keyNumberSize, nil
+	case mtFloat:
+		if len(data) < keyNumberSize {
+			return 0, errorDecf(0, "%s", io.ErrUnexpectedEOF).wrap(ErrMalformedData)
+		}
+		bits := binary.BigEndian.Uint64(data)
+		if bits&keySignBit != 0 {
+			bits &^= keySignBit
+		} else {
+			bits = ^bits
+		}
+		f := math.Float64frombits(bits)
+		if v.OverflowFloat(f) {
+			return 0, errorDecf(0, "%v overflows %s", f, v.Type()).wrap(ErrRange)
+		}
+		v.SetFloat(f)
+		return keyNumberSize, nil
+	case mtString:
+		var s []byte
+		for i := 0; i < len(data); i++ {
+			if data[i] != keyEscape {
+				s = append(s, data[i])
+				continue
+			}
+			if i+1 >= len(data) {
+				break
+			}
+			i++
+			switch data[i] {
+			case keyEscapedZero:
+				s = append(s, 0x00)
+			case keyStringEnd:
+				v.SetString(string(s))
+				return i + 1, nil
+			default:
+				return 0, errorDecf(i, "invalid escape in string key: %#02x", data[i]).wrap(ErrMalformedData)
+			}
+		}
+		return 0, errorDecf(len(data), "%s", io.ErrUnexpectedEOF).wrap(ErrMalformedData)
+	case mtStruct:
+		var n int
+		for _, fi := range keyFields(ti.Fields) {
+			fv, err := settableFieldByIndex(v, fi.Index)
+			if err != nil {
+				return 0, err
+			}
+			fn, err := decKey(data[n:], fv, fi.Type)
+			if err != nil {
+				return 0, errorDecf(n, "%s: %v", PathStep{Kind: reflect.Struct, Field: fi.Name}, err)
+			}
+			n += fn
+		}
+		return n, nil
+	case mtArray:
+		var n int
+		for i := 0; i < v.Len(); i++ {
+			itemN, err := decKey(data[n:], v.Index(i), *ti.ValType)
+			if err != nil {
+				return 0, errorDecf(n, "%s: %v", PathStep{Kind: reflect.Array, Index: i}, err)
+			}
+			n += itemN
+		}
+		return n, nil
+	default:
+		panic("not a key type")
+	}
+}
@@ -0,0 +1,253 @@
+package rezi
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testKeyPair struct {
+	Group int
+	Name  string
+}
+
+type testKeyNested struct {
+	Pair   testKeyPair
+	Active bool
+	Scores [2]float64
+}
+
+func Test_EncKey(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  interface{}
+		expect []byte
+	}{
+		{name: "false", input: false, expect: []byte{0x00}},
+		{name: "true", input: true, expect: []byte{0x01}},
+		{name: "int 0", input: 0, expect: []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{name: "int -1", input: -1, expect: []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "int8 1", input: int8(1), expect: []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}},
+		{name: "uint 256", input: uint(256), expect: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}},
+		{name: "float 1", input: 1.0, expect: []byte{0xbf, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{name: "float -1", input: -1.0, expect: []byte{0x40, 0x0f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{name: "empty string", input: "", expect: []byte{0x00, 0x01}},
+		{name: "string", input: "ab", expect: []byte{0x61, 0x62, 0x00, 0x01}},
+		{name: "string with zero byte", input: "a\x00b", expect: []byte{0x61, 0x00, 0xff, 0x62, 0x00, 0x01}},
+		{
+			name:  "struct",
+			input: testKeyPair{Group: 2, Name: "a"},
+			expect: []byte{
+				0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // Group
+				0x61, 0x00, 0x01, // Name
+			},
+		},
+		{
+			name:  "pointer to struct",
+			input: &testKeyPair{Group: 2, Name: "a"},
+			expect: []byte{
+				0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, // Group
+				0x61, 0x00, 0x01, // Name
+			},
+		},
+		{name: "array", input: [2]bool{true, false}, expect: []byte{0x01, 0x00}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := EncKey(tc.input)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_EncKey_Order(t *testing.T) {
+	testCases := []struct {
+		name   string
+		sorted []interface{}
+	}{
+		{name: "bools", sorted: []interface{}{false, true}},
+		{
+			name:   "ints",
+			sorted: []interface{}{math.MinInt64, -65536, -256, -255, -1, 0, 1, 255, 256, 65536, math.MaxInt64},
+		},
+		{
+			name:   "uints",
+			sorted: []interface{}{uint64(0), uint64(1), uint64(255), uint64(256), uint64(math.MaxUint64)},
+		},
+		{
+			name: "floats",
+			sorted: []interface{}{
+				math.Inf(-1), -math.MaxFloat64, -1e10, -1.5, -1.0, -math.SmallestNonzeroFloat64,
+				math.Copysign(0, -1), 0.0, math.SmallestNonzeroFloat64, 0.5, 1.0, 1e10, math.MaxFloat64, math.Inf(1),
+			},
+		},
+		{
+			name:   "strings",
+			sorted: []interface{}{"", "\x00", "\x00\x00", "\x00\x01", "\x01", "a", "a\x00", "a\x00b", "aa", "ab", "b", "\xff"},
+		},
+		{
+			name: "structs",
+			sorted: []interface{}{
+				testKeyPair{Group: -1, Name: "z"},
+				testKeyPair{Group: 1, Name: ""},
+				testKeyPair{Group: 1, Name: "a"},
+				testKeyPair{Group: 1, Name: "a\x00"},
+				testKeyPair{Group: 1, Name: "b"},
+				testKeyPair{Group: 2, Name: ""},
+			},
+		},
+		{
+			name: "nested structs",
+			sorted: []interface{}{
+				testKeyNested{Pair: testKeyPair{Group: 1}, Active: true},
+				testKeyNested{Pair: testKeyPair{Group: 1, Name: "a"}, Scores: [2]float64{-1, 0}},
+				testKeyNested{Pair: testKeyPair{Group: 1, Name: "a"}, Scores: [2]float64{0, -1}},
+				testKeyNested{Pair: testKeyPair{Group: 1, Name: "a"}, Active: true},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			for i := 1; i < len(tc.sorted); i++ {
+				lesser, err := EncKey(tc.sorted[i-1])
+				if !assert.NoError(err) {
+					return
+				}
+				greater, err := EncKey(tc.sorted[i])
+				if !assert.NoError(err) {
+					return
+				}
+
+				assert.Equal(-1, bytes.Compare(lesser, greater), "%v should sort before %v", tc.sorted[i-1], tc.sorted[i])
+			}
+		})
+	}
+}
+
+func Test_DecKey(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		testCases := []struct {
+			name  string
+			input interface{}
+			recv  func() interface{}
+		}{
+			{name: "bool", input: true, recv: func() interface{} { return new(bool) }},
+			{name: "int", input: -413, recv: func() interface{} { return new(int) }},
+			{name: "int8", input: int8(-128), recv: func() interface{} { return new(int8) }},
+			{name: "uint16", input: uint16(612), recv: func() interface{} { return new(uint16) }},
+			{name: "float64", input: -0.25, recv: func() interface{} { return new(float64) }},
+			{name: "float32", input: float32(1.5), recv: func() interface{} { return new(float32) }},
+			{name: "string", input: "a\x00\xff\x01b", recv: func() interface{} { return new(string) }},
+			{name: "struct", input: testKeyPair{Group: -8, Name: "Nepeta"}, recv: func() interface{} { return new(testKeyPair) }},
+			{
+				name:  "nested struct",
+				input: testKeyNested{Pair: testKeyPair{Group: 3, Name: "x"}, Active: true, Scores: [2]float64{1, math.Inf(-1)}},
+				recv:  func() interface{} { return new(testKeyNested) },
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				assert := assert.New(t)
+				key, err := EncKey(tc.input)
+				if !assert.NoError(err) {
+					return
+				}
+				// a second key after it must not be read
+				input := append(key, 0xaa, 0xbb)
+
+				actual := tc.recv()
+				n, err := DecKey(input, actual)
+				if !assert.NoError(err) {
+					return
+				}
+
+				assert.Equal(len(key), n)
+				assert.Equal(tc.input, reflect.ValueOf(actual).Elem().Interface())
+			})
+		}
+	})
+
+	t.Run("to a wider type", func(t *testing.T) {
+		assert := assert.New(t)
+		key, err := EncKey(int8(-5))
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual int64
+		_, err = DecKey(key, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(int64(-5), actual)
+	})
+}
+
+func Test_KeyErrors(t *testing.T) {
+	t.Run("EncKey", func(t *testing.T) {
+		testCases := []struct {
+			name  string
+			input interface{}
+		}{
+			{name: "slice", input: []int{1}},
+			{name: "map", input: map[string]int{}},
+			{name: "pointer field", input: struct{ P *int }{}},
+			{name: "slice field", input: struct{ S []string }{}},
+			{name: "nil pointer", input: (*testKeyPair)(nil)},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				assert := assert.New(t)
+
+				_, err := EncKey(tc.input)
+
+				assert.ErrorIs(err, ErrInvalidType)
+			})
+		}
+	})
+
+	t.Run("DecKey", func(t *testing.T) {
+		testCases := []struct {
+			name        string
+			input       []byte
+			recv        interface{}
+			expectErrIs error
+		}{
+			{name: "truncated int", input: []byte{0x80, 0x00}, recv: new(int), expectErrIs: ErrMalformedData},
+			{name: "int out of range", input: []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}, recv: new(int8), expectErrIs: ErrRange},
+			{name: "bad bool", input: []byte{0x02}, recv: new(bool), expectErrIs: ErrMalformedData},
+			{name: "unterminated string", input: []byte{0x61, 0x62}, recv: new(string), expectErrIs: ErrMalformedData},
+			{name: "bad string escape", input: []byte{0x61, 0x00, 0x05}, recv: new(string), expectErrIs: ErrMalformedData},
+			{name: "truncated struct", input: []byte{0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x61}, recv: new(testKeyPair), expectErrIs: ErrMalformedData},
+			{name: "unsupported receiver", input: []byte{0x00}, recv: new([]int), expectErrIs: ErrInvalidType},
+			{name: "pointer receiver", input: []byte{0x00}, recv: new(*bool), expectErrIs: ErrInvalidType},
+			{name: "non-pointer receiver", input: []byte{0x00}, recv: false, expectErrIs: ErrInvalidType},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				assert := assert.New(t)
+
+				_, err := DecKey(tc.input, tc.recv)
+
+				assert.ErrorIs(err, tc.expectErrIs)
+			})
+		}
+	})
+}
@@ -1,16 +1,21 @@
 package rezi
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"reflect"
 	"sort"
 )
 
-// ti must containt a main type of mtIntegral, mtBool, mtFloat, or mtString
+// sortableMapKeys sorts the keys of a map into a consistent order. If ti
+// describes a naturally-ordered type (see typeInfo.OrderedMapKey), keys are
+// sorted by value; otherwise, encoded must be set to the encoded bytes of each
+// key and keys are sorted by those bytes.
 type sortableMapKeys struct {
-	keys []reflect.Value
-	ti   typeInfo
+	keys    []reflect.Value
+	encoded [][]byte
+	ti      typeInfo
 }
 
 func (smk sortableMapKeys) Len() int {
@@ -19,10 +24,15 @@ func (smk sortableMapKeys) Len() int {
 
 func (smk sortableMapKeys) Swap(i, j int) {
 	smk.keys[i], smk.keys[j] = smk.keys[j], smk.keys[i]
+	if smk.encoded != nil {
+		smk.encoded[i], smk.encoded[j] = smk.encoded[j], smk.encoded[i]
+	}
 }
 
 func (smk sortableMapKeys) Less(i, j int) bool {
-	if smk.ti.Main == mtBool {
+	if smk.encoded != nil {
+		return bytes.Compare(smk.encoded[i], smk.encoded[j]) < 0
+	} else if smk.ti.Main == mtBool {
 		b1 := smk.keys[i].Bool()
 		b2 := smk.keys[j].Bool()
 		return !b1 && b2
@@ -92,6 +102,34 @@ func encMap(value analyzed[any]) ([]byte, error) {
 		keys: mapKeys,
 		ti:   *value.info.KeyType,
 	}
+
+	// keys that could be written as back-references or that could refer to
+	// the field-name table must be encoded in the order they are written, so
+	// they are sorted by their stateless encoding.
+	statefulKeys := (value.sess.trackingRefs() && value.info.KeyType.Trackable()) || value.sess.tablingNames()
+
+	// keys without a natural ordering are sorted by their encoded bytes, so
+	// encode them all up front.
+	if !keysToSort.ti.OrderedMapKey() {
+		sortSess := value.sess
+		if statefulKeys {
+			sortSess = value.sess.stateless()
+		}
+
+		keysToSort.encoded = make([][]byte, len(mapKeys))
+		for i := range mapKeys {
+			k := mapKeys[i]
+			// the fields of structs used as keys are always all encoded.
+			resumeMask := value.sess.suspendMask()
+			keyData, err := encWithTypeInfo(k.Interface(), *value.info.KeyType, sortSess)
+			resumeMask()
+			if err != nil {
+				return nil, errorf("map key %v: %v", k.Interface(), err)
+			}
+			keysToSort.encoded[i] = keyData
+		}
+	}
+
 	sort.Sort(keysToSort)
 	mapKeys = keysToSort.keys
 
@@ -101,11 +139,19 @@ func encMap(value analyzed[any]) ([]byte, error) {
 		k := mapKeys[i]
 		v := value.reflect.MapIndex(k)
 
-		keyData, err := encWithTypeInfo(k.Interface(), *value.info.KeyType)
-		if err != nil {
-			return nil, errorf("map key %v: %v", k.Interface(), err)
+		var keyData []byte
+		if keysToSort.encoded != nil && !statefulKeys {
+			keyData = keysToSort.encoded[i]
+		} else {
+			var err error
+			resumeMask := value.sess.suspendMask()
+			keyData, err = encWithTypeInfo(k.Interface(), *value.info.KeyType, value.sess)
+			resumeMask()
+			if err != nil {
+				return nil, errorf("map key %v: %v", k.Interface(), err)
+			}
 		}
-		valData, err := encWithTypeInfo(v.Interface(), *value.info.ValType)
+		valData, err := encWithTypeInfo(v.Interface(), *value.info.ValType, value.sess)
 		if err != nil {
 			return nil, errorf("map value[%v]: %v", k.Interface(), err)
 		}
@@ -143,6 +189,19 @@ func decCheckedMap(data []byte, recv analyzed[any]) (decoded[any], error) {
 func decMap(data []byte, recv analyzed[any]) (decoded[any], error) {
 	var dec decoded[any]
 
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return dec, errorDecf(0, "decode byte count: %s", err)
+	}
+
+	// a struct is encoded the same way as a map with string keys, except when
+	// its field names are not encoded with it.
+	tabled := hdr.v.Version == fieldNameTableVersion
+	stringKeys := recv.info.KeyType.Main == mtString && recv.info.KeyType.Indir == 0
+	if (tabled && !stringKeys) || hdr.v.Version == numberedFieldsVersion || hdr.v.Version == tupleStructVersion {
+		return dec, errorDecf(0, "struct data without field names cannot be decoded to a map with %s keys", recv.reflect.Type().Elem().Key()).wrap(ErrMalformedData, ErrInvalidType)
+	}
+
 	toConsume, err := decInt[tLen](data)
 	if err != nil {
 		return dec, errorDecf(0, "decode byte count: %s", err)
@@ -153,6 +212,19 @@ func decMap(data []byte, recv analyzed[any]) (decoded[any], error) {
 	refVal := recv.reflect
 	refMapType := refVal.Type().Elem()
 
+	// when merging, entries are inserted into the existing map, and a nil or
+	// empty map in the data leaves it as it is.
+	existing := refVal.Elem()
+	if recv.sess.merging() != MergeNone && !existing.IsNil() {
+		if toConsume.v == 0 || toConsume.v == -1 {
+			dec.v = existing.Interface()
+			dec.reflect = existing
+			return dec, nil
+		}
+	} else {
+		existing = reflect.Value{}
+	}
+
 	if toConsume.v == 0 {
 		// initialize to the empty map
 		emptyMap := reflect.MakeMap(refMapType)
@@ -189,26 +261,60 @@ func decMap(data []byte, recv analyzed[any]) (decoded[any], error) {
 	data = data[:toConsume.v]
 
 	// create the map we will be populating
-	m := reflect.MakeMap(refMapType)
+	m := existing
+	if !m.IsValid() {
+		m = reflect.MakeMap(refMapType)
+	}
+
+	// make it available to any back-references within its own entries
+	recv.sess.claimPendingMap(m)
 
 	var i int
 	refKType := refMapType.Key()
 	refVType := refMapType.Elem()
-	for i < toConsume.v {
+
+	// the keys of a struct with a field-name table are its field names, given
+	// before any of the values.
+	var tableKeys []string
+	if tabled {
+		names, err := decNameTableRef(data, recv.sess.nameTable())
+		if err != nil {
+			return dec, errorDecf(dec.n, "decode field names: %s", err)
+		}
+		dec.n += names.n
+		i += names.n
+		data = data[names.n:]
+		tableKeys = names.v
+	}
+
+	var keyIdx int
+	for ; i < toConsume.v; keyIdx++ {
 		// dynamically create the map key type
 		refKey := reflect.New(refKType)
-		n, err := decWithTypeInfo(data, refKey.Interface(), *recv.info.KeyType)
-		if err != nil {
-			return dec, errorDecf(dec.n, "map key: %v", err)
+		if tabled {
+			if keyIdx >= len(tableKeys) {
+				return dec, errorDecf(dec.n, "%d bytes remain after the last field", toConsume.v-i).wrap(ErrMalformedData)
+			}
+			refKey.Elem().Set(reflect.ValueOf(tableKeys[keyIdx]).Convert(refKType))
+		} else {
+			resumeFields := recv.sess.suspendFields()
+			n, err := decWithTypeInfo(data, refKey.Interface(), *recv.info.KeyType, recv.sess)
+			resumeFields()
+			if err != nil {
+				return dec, errorDecf(dec.n, "map key: %v", err)
+			}
+			dec.n += n
+			i += n
+			data = data[n:]
 		}
-		dec.n += n
-		i += n
-		data = data[n:]
 
 		refValue := reflect.New(refVType)
-		n, err = decWithTypeInfo(data, refValue.Interface(), *recv.info.ValType)
+		step := PathStep{Kind: reflect.Map, Key: refKey.Elem().Interface()}
+		recv.sess.enterStep(step)
+		n, err := decWithTypeInfo(data, refValue.Interface(), *recv.info.ValType, recv.sess)
+		recv.sess.leaveStep()
 		if err != nil {
-			return dec, errorDecf(dec.n, "map value[%v]: %v", refKey.Elem().Interface(), err)
+			return dec, errorDecf(dec.n, "map value[%v]: %v", refKey.Elem().Interface(), err).withStep(step)
 		}
 		dec.n += n
 		i += n
@@ -216,6 +322,9 @@ func decMap(data []byte, recv analyzed[any]) (decoded[any], error) {
 
 		m.SetMapIndex(refKey.Elem(), refValue.Elem())
 	}
+	if keyIdx < len(tableKeys) {
+		return dec, errorDecf(dec.n, "field .%s has no value", tableKeys[keyIdx]).wrap(io.ErrUnexpectedEOF, ErrMalformedData)
+	}
 
 	refVal.Elem().Set(m)
 	dec.v = m.Interface()
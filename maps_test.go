@@ -3294,3 +3294,300 @@ func Test_Dec_Map_ValueIndirection(t *testing.T) {
 		assert.Equal(expect, actual)
 	})
 }
+
+type testMapKeyCoord struct {
+	X, Y int
+}
+
+func Test_Enc_Map_CompositeKeys(t *testing.T) {
+	t.Run("map[struct]string", func(t *testing.T) {
+		// setup
+		assert := assert.New(t)
+		var (
+			input = map[testMapKeyCoord]string{
+				{X: 1, Y: 2}:  "a",
+				{X: 1, Y: -1}: "b",
+			}
+			expect = []byte{
+				0x01, 0x23, // len=35
+
+				0x01, 0x0b, // {X: 1, Y: -1} (len=11):
+				0x41, 0x82, 0x01, 0x58, // "X"
+				0x01, 0x01, // 1
+				0x41, 0x82, 0x01, 0x59, // "Y"
+				0x80,                   // -1
+				0x41, 0x82, 0x01, 0x62, // "b"
+
+				0x01, 0x0c, // {X: 1, Y: 2} (len=12):
+				0x41, 0x82, 0x01, 0x58, // "X"
+				0x01, 0x01, // 1
+				0x41, 0x82, 0x01, 0x59, // "Y"
+				0x01, 0x02, // 2
+				0x41, 0x82, 0x01, 0x61, // "a"
+			}
+		)
+
+		// execute
+		actual, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		// assert
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("map[array]int", func(t *testing.T) {
+		// setup
+		assert := assert.New(t)
+		var (
+			input = map[[2]int16]int{
+				{256, 0}: 2,
+				{1, 1}:   1,
+			}
+			expect = []byte{
+				0x01, 0x10, // len=16
+
+				0x01, 0x04, // [1, 1] (len=4):
+				0x01, 0x01, // 1
+				0x01, 0x01, // 1
+				0x01, 0x01, // 1
+
+				0x01, 0x04, // [256, 0] (len=4):
+				0x02, 0x01, 0x00, // 256
+				0x00,       // 0
+				0x01, 0x02, // 2
+			}
+		)
+
+		// execute
+		actual, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		// assert
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("map[TextMarshaler]int", func(t *testing.T) {
+		// setup
+		assert := assert.New(t)
+		var (
+			input = map[testText]int{
+				{value: 2, enabled: true, name: "b"}: 2,
+				{value: 1, enabled: true, name: "a"}: 1,
+			}
+			expect = []byte{
+				0x01, 0x1a, // len=26
+
+				0x41, 0x82, 0x08, 0x31, 0x2c, 0x74, 0x72, 0x75, 0x65, 0x2c, 0x61, // "1,true,a"
+				0x01, 0x01, // 1
+
+				0x41, 0x82, 0x08, 0x32, 0x2c, 0x74, 0x72, 0x75, 0x65, 0x2c, 0x62, // "2,true,b"
+				0x01, 0x02, // 2
+			}
+		)
+
+		// execute
+		actual, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		// assert
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("map[array of pointers] is not supported", func(t *testing.T) {
+		// setup
+		assert := assert.New(t)
+		var input = map[[1]*int]int{}
+
+		// execute
+		_, err := Enc(input)
+
+		// assert
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+}
+
+func Test_Dec_Map_CompositeKeys(t *testing.T) {
+	t.Run("map[struct]string", func(t *testing.T) {
+		// setup
+		assert := assert.New(t)
+		var (
+			input = []byte{
+				0x01, 0x23, // len=35
+
+				0x01, 0x0b, // {X: 1, Y: -1} (len=11):
+				0x41, 0x82, 0x01, 0x58, // "X"
+				0x01, 0x01, // 1
+				0x41, 0x82, 0x01, 0x59, // "Y"
+				0x80,                   // -1
+				0x41, 0x82, 0x01, 0x62, // "b"
+
+				0x01, 0x0c, // {X: 1, Y: 2} (len=12):
+				0x41, 0x82, 0x01, 0x58, // "X"
+				0x01, 0x01, // 1
+				0x41, 0x82, 0x01, 0x59, // "Y"
+				0x01, 0x02, // 2
+				0x41, 0x82, 0x01, 0x61, // "a"
+			}
+			expect = map[testMapKeyCoord]string{
+				{X: 1, Y: 2}:  "a",
+				{X: 1, Y: -1}: "b",
+			}
+			expectConsumed = 37
+		)
+
+		// execute
+		var actual map[testMapKeyCoord]string
+		consumed, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		// assert
+		assert.Equal(expectConsumed, consumed)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("map[array]int", func(t *testing.T) {
+		// setup
+		assert := assert.New(t)
+		var (
+			input = []byte{
+				0x01, 0x10, // len=16
+
+				0x01, 0x04, // [1, 1] (len=4):
+				0x01, 0x01, // 1
+				0x01, 0x01, // 1
+				0x01, 0x01, // 1
+
+				0x01, 0x04, // [256, 0] (len=4):
+				0x02, 0x01, 0x00, // 256
+				0x00,       // 0
+				0x01, 0x02, // 2
+			}
+			expect = map[[2]int16]int{
+				{256, 0}: 2,
+				{1, 1}:   1,
+			}
+			expectConsumed = 18
+		)
+
+		// execute
+		var actual map[[2]int16]int
+		consumed, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		// assert
+		assert.Equal(expectConsumed, consumed)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("map[TextMarshaler]int", func(t *testing.T) {
+		// setup
+		assert := assert.New(t)
+		var (
+			input = []byte{
+				0x01, 0x1a, // len=26
+
+				0x41, 0x82, 0x08, 0x31, 0x2c, 0x74, 0x72, 0x75, 0x65, 0x2c, 0x61, // "1,true,a"
+				0x01, 0x01, // 1
+
+				0x41, 0x82, 0x08, 0x32, 0x2c, 0x74, 0x72, 0x75, 0x65, 0x2c, 0x62, // "2,true,b"
+				0x01, 0x02, // 2
+			}
+			expect = map[testText]int{
+				{value: 2, enabled: true, name: "b"}: 2,
+				{value: 1, enabled: true, name: "a"}: 1,
+			}
+			expectConsumed = 28
+		)
+
+		// execute
+		var actual map[testText]int
+		consumed, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		// assert
+		assert.Equal(expectConsumed, consumed)
+		assert.Equal(expect, actual)
+	})
+}
+
+func Test_Dec_Map_FromStruct(t *testing.T) {
+	type testStruct struct {
+		Name  string
+		Title string
+	}
+
+	testCases := []struct {
+		name   string
+		format Format
+	}{
+		{name: "plain"},
+		{name: "field-name table", format: Format{FieldNameTable: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			input, err := EncWithFormat(testStruct{Name: "Terezi", Title: "Seer"}, &tc.format)
+			if !assert.NoError(err) {
+				return
+			}
+
+			var actual map[string]string
+			n, err := Dec(input, &actual)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(len(input), n)
+			assert.Equal(map[string]string{"Name": "Terezi", "Title": "Seer"}, actual)
+		})
+	}
+
+	t.Run("incompatible field type", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(struct {
+			Name  string
+			Count int
+		}{Name: "Terezi", Count: 8})
+
+		var actual map[string]string
+		_, err := Dec(input, &actual)
+		assert.Error(err)
+	})
+
+	t.Run("field-name table to non-string keys", func(t *testing.T) {
+		assert := assert.New(t)
+		input, err := EncWithFormat(testStruct{Name: "Terezi"}, &Format{FieldNameTable: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual map[int]string
+		_, err = Dec(input, &actual)
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("tuple struct", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(struct {
+			_    struct{} `rezi:",tuple"`
+			Name string
+		}{Name: "Terezi"})
+
+		var actual map[string]string
+		_, err := Dec(input, &actual)
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+}
@@ -0,0 +1,267 @@
+package rezi
+
+// marshaler.go contains functions for encoding and decoding types that
+// implement the REZI marshaling interfaces, as well as reflection-free
+// functions for encoding and decoding single values.
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+)
+
+// Marshaler is implemented by types that can encode themselves by writing
+// their contents to a REZI [Writer]. The MarshalREZI methods created by the
+// rezigen command implement it. If a type implements Marshaler, REZI calls
+// MarshalREZI to encode it in preference to any other method it may have.
+//
+// MarshalREZI should write each part of the value to w in order, typically by
+// calling w.Enc or one of the typed Enc methods of w. A struct that writes its
+// fields by name should begin each with [Writer.EncField] or write it with
+// [Writer.EncFieldValue], which apply the Format options that change how
+// struct fields are written so that the bytes are the same as those of the
+// struct encoded without MarshalREZI. It must not call Close on w. The written
+// bytes are preceded by a count of them in the encoded value, so that the value
+// can later be given in its entirety to UnmarshalREZI. The Writer passed to
+// MarshalREZI uses the same Format as the encode that called it, with the
+// exception of compression, which is only applied to the complete encoded data.
+type Marshaler interface {
+	MarshalREZI(w *Writer) error
+}
+
+// Unmarshaler is implemented by types that can decode themselves by reading
+// their contents from a REZI [Reader]. The UnmarshalREZI methods created by the
+// rezigen command implement it. If a type implements Unmarshaler, REZI calls
+// UnmarshalREZI to decode it in preference to any other method it may have.
+//
+// The Reader passed to UnmarshalREZI reads only the bytes that were written by
+// MarshalREZI; [Reader.More] can be used to check whether any remain. Errors
+// returned from the methods of the Reader include the offset of the problem
+// within the complete data, so they can be returned from UnmarshalREZI as-is.
+// There is no need to call [Wrapf] on them. A struct that reads its fields by
+// name should read each name with [Reader.DecField] and report a problem with a
+// decoded value with [Reader.FieldError], so that its errors give the same
+// offset and path as they do for the struct decoded without UnmarshalREZI.
+//
+// If the Unmarshaler is a struct type and the data holds a struct encoded in a
+// form that MarshalREZI never produces, such as with a field-name table from
+// [Format.FieldNameTable], UnmarshalREZI is not called and the struct is
+// instead decoded by its fields as if it did not implement Unmarshaler.
+type Unmarshaler interface {
+	UnmarshalREZI(r *Reader) error
+}
+
+// encCheckedRezi encodes an implementor of Marshaler.
+func encCheckedRezi(value analyzed[any]) ([]byte, error) {
+	if value.info.Main != mtRezi {
+		panic("not a rezi marshaler type")
+	}
+
+	return encWithNilCheck(value, encRezi, func(r reflect.Value) Marshaler {
+		return r.Interface().(Marshaler)
+	})
+}
+
+func encRezi(value analyzed[Marshaler]) ([]byte, error) {
+	m := value.v
+
+	if m == nil {
+		return encNilHeader(0), nil
+	}
+
+	var buf bytes.Buffer
+	w := newSubWriter(&buf, value.sess)
+	if err := m.MarshalREZI(w); err != nil {
+		return nil, errorf("MarshalREZI: %s", err)
+	}
+
+	// fields given with a field-name table are written after the rest, as
+	// they are only complete once MarshalREZI has returned.
+	contents := buf.Bytes()
+	var hdr *countHeader
+	tabled, err := w.encTabledFields()
+	if err != nil {
+		return nil, errorf("MarshalREZI: %s", err)
+	}
+	if tabled != nil {
+		contents = append(contents, tabled...)
+		hdr = &countHeader{Version: fieldNameTableVersion}
+	}
+
+	enc := append(encCount(len(contents), hdr), contents...)
+	return enc, nil
+}
+
+// decCheckedRezi decodes to an implementor of Unmarshaler.
+func decCheckedRezi(data []byte, recv analyzed[any]) (decoded[any], error) {
+	if recv.info.Main != mtRezi {
+		panic("not a rezi unmarshaler type")
+	}
+
+	u, err := decWithNilCheck(data, recv, fn_DecToWrappedReceiver(recv,
+		func(t reflect.Type) bool {
+			return t.Implements(refReziUnmarshalerType)
+		},
+		decRezi,
+	))
+	if err != nil {
+		return u, err
+	}
+	if recv.info.Indir == 0 {
+		refReceiver := recv.reflect
+		refReceiver.Elem().Set(u.reflect)
+	}
+	return u, nil
+}
+
+func decRezi(data []byte, recv analyzed[any]) (decoded[any], error) {
+	u := recv.v.(Unmarshaler)
+
+	var dec decoded[any]
+
+	// MarshalREZI output never has a version, so a versioned header means
+	// the data was encoded from the fields of a struct without MarshalREZI,
+	// such as with a field-name table. UnmarshalREZI cannot read that, so it
+	// is decoded from the fields instead.
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return dec, errorDecf(0, "decode byte count: %s", err)
+	}
+	if structType := recv.reflect.Type().Elem(); hdr.v.Version != 0 && structType.Kind() == reflect.Struct {
+		info, err := decStructTypeInfo(structType)
+		if err != nil {
+			return dec, err
+		}
+		return decStruct(data, analyzed[any]{v: recv.v, reflect: recv.reflect, info: info, sess: recv.sess})
+	}
+
+	byteLen, err := decInt[tLen](data)
+	if err != nil {
+		return decoded[any]{n: byteLen.n}, errorDecf(0, "decode byte count: %s", err)
+	}
+	dec.n = byteLen.n
+	data = data[dec.n:]
+
+	if len(data) < byteLen.v {
+		s := "s"
+		verbS := ""
+		if len(data) == 1 {
+			s = ""
+			verbS = "s"
+		}
+		const errFmt = "decoded byte count is %d but only %d byte%s remain%s at offset"
+		err := errorDecf(dec.n, errFmt, byteLen.v, len(data), s, verbS).wrap(io.ErrUnexpectedEOF, ErrMalformedData)
+		return dec, err
+	}
+
+	var contents []byte
+	if byteLen.v > 0 {
+		contents = data[:byteLen.v]
+	}
+
+	r := newSubReader(contents, recv.sess)
+	err = u.UnmarshalREZI(r)
+	if err != nil {
+		return dec, errorDecf(dec.n, "UnmarshalREZI: %s", err)
+	}
+	dec.v = u
+	dec.n += byteLen.v
+
+	return dec, nil
+}
+
+// EncBool encodes a bool value without the use of reflection. It produces the
+// same bytes as calling [Enc] on b.
+func EncBool(b bool) []byte {
+	return encBool(analyzed[bool]{v: b})
+}
+
+// DecBool decodes a bool value without the use of reflection. It returns the
+// decoded value and the number of bytes consumed.
+func DecBool(data []byte) (bool, int, error) {
+	dec, err := decBool(data)
+	return dec.v, dec.n, err
+}
+
+// EncInt encodes a signed integer value without the use of reflection. It
+// produces the same bytes as calling [Enc] on i with any signed integer type
+// that i can be converted to from int64.
+func EncInt(i int64) []byte {
+	return encInt(analyzed[int64]{v: i})
+}
+
+// DecInt decodes a signed integer value without the use of reflection. It
+// returns the decoded value and the number of bytes consumed.
+func DecInt(data []byte) (int64, int, error) {
+	dec, err := decInt[int64](data)
+	return dec.v, dec.n, err
+}
+
+// EncUint encodes an unsigned integer value without the use of reflection. It
+// produces the same bytes as calling [Enc] on u with any unsigned integer type
+// that u can be converted to from uint64.
+func EncUint(u uint64) []byte {
+	return encInt(analyzed[uint64]{v: u})
+}
+
+// DecUint decodes an unsigned integer value without the use of reflection. It
+// returns the decoded value and the number of bytes consumed.
+func DecUint(data []byte) (uint64, int, error) {
+	dec, err := decInt[uint64](data)
+	return dec.v, dec.n, err
+}
+
+// EncFloat encodes a floating-point value without the use of reflection. It
+// produces the same bytes as calling [Enc] on f with either float type that f
+// can be converted to from float64.
+func EncFloat(f float64) []byte {
+	return encFloat(analyzed[float64]{v: f})
+}
+
+// DecFloat decodes a floating-point value without the use of reflection. It
+// returns the decoded value and the number of bytes consumed.
+func DecFloat(data []byte) (float64, int, error) {
+	dec, err := decFloat[float64](data)
+	return dec.v, dec.n, err
+}
+
+// EncString encodes a string value without the use of reflection. It produces
+// the same bytes as calling [Enc] on s.
+func EncString(s string) []byte {
+	return encString(analyzed[string]{v: s})
+}
+
+// DecString decodes a string value without the use of reflection. It returns
+// the decoded value and the number of bytes consumed.
+func DecString(data []byte) (string, int, error) {
+	dec, err := decString(data)
+	return dec.v, dec.n, err
+}
+
+// DecCount decodes the count of bytes at the start of an encoded struct, slice,
+// or map. It returns the count and the number of bytes that were consumed to
+// decode it. A non-nil error is returned if there are fewer than count bytes
+// remaining in data after the count itself.
+func DecCount(data []byte) (count int, n int, err error) {
+	dec, err := decInt[tLen](data)
+	if err != nil {
+		return 0, dec.n, errorDecf(0, "decode byte count: %s", err)
+	}
+	if dec.v < 0 {
+		return 0, dec.n, errorDecf(0, "decoded byte count is negative: %d", dec.v).wrap(ErrMalformedData)
+	}
+
+	remaining := len(data) - dec.n
+	if remaining < dec.v {
+		s := "s"
+		verbS := ""
+		if remaining == 1 {
+			s = ""
+			verbS = "s"
+		}
+		const errFmt = "decoded byte count is %d but only %d byte%s remain%s in data at offset"
+		return 0, dec.n, errorDecf(dec.n, errFmt, dec.v, remaining, s, verbS).wrap(io.ErrUnexpectedEOF, ErrMalformedData)
+	}
+
+	return dec.v, dec.n, nil
+}
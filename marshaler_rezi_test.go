@@ -0,0 +1,910 @@
+// Code generated by rezigen; DO NOT EDIT.
+
+package rezi
+
+import (
+	"fmt"
+	"math"
+)
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructEmpty) MarshalREZI(w *Writer) error {
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructEmpty) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		default:
+			return r.UnknownFieldError("testGenStructEmpty")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructOneMember) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("Value", v.Value == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructOneMember) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Value":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.Value = int(fv)
+		default:
+			return r.UnknownFieldError("testGenStructOneMember")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructMultiMember) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("Name", v.Name == ""); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncString(v.Name); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("Value", v.Value == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructMultiMember) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Name":
+			fv, err := r.DecString()
+			if err != nil {
+				return err
+			}
+			v.Name = fv
+		case "Value":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.Value = int(fv)
+		default:
+			return r.UnknownFieldError("testGenStructMultiMember")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructWithUnexported) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("Value", v.Value == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructWithUnexported) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Value":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.Value = int(fv)
+		default:
+			return r.UnknownFieldError("testGenStructWithUnexported")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructWithUnexportedCaseDistinguished) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("Value", v.Value == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructWithUnexportedCaseDistinguished) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Value":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.Value = int(fv)
+		default:
+			return r.UnknownFieldError("testGenStructWithUnexportedCaseDistinguished")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructOnlyUnexported) MarshalREZI(w *Writer) error {
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructOnlyUnexported) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		default:
+			return r.UnknownFieldError("testGenStructOnlyUnexported")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructManyFields) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("Enabled", !v.Enabled); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncBool(v.Enabled); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("Factor", v.Factor == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncFloat(v.Factor); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("Name", v.Name == ""); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncString(v.Name); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("Value", v.Value == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.Value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructManyFields) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Enabled":
+			fv, err := r.DecBool()
+			if err != nil {
+				return err
+			}
+			v.Enabled = fv
+		case "Factor":
+			fv, err := r.DecFloat()
+			if err != nil {
+				return err
+			}
+			v.Factor = fv
+		case "Name":
+			fv, err := r.DecString()
+			if err != nil {
+				return err
+			}
+			v.Name = fv
+		case "Value":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.Value = int(fv)
+		default:
+			return r.UnknownFieldError("testGenStructManyFields")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructWithAnonymousTypedMember) MarshalREZI(w *Writer) error {
+	if err := w.EncFieldValue("Name", v.Name); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructWithAnonymousTypedMember) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Name":
+			if err := r.Dec(&v.Name); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownFieldError("testGenStructWithAnonymousTypedMember")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructEmbedUnexported) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("Name", v.Name == ""); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncString(v.Name); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("Secret", v.testEmbedSecret.Secret == ""); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncString(v.testEmbedSecret.Secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructEmbedUnexported) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Name":
+			fv, err := r.DecString()
+			if err != nil {
+				return err
+			}
+			v.Name = fv
+		case "Secret":
+			fv, err := r.DecString()
+			if err != nil {
+				return err
+			}
+			v.testEmbedSecret.Secret = fv
+		default:
+			return r.UnknownFieldError("testGenStructEmbedUnexported")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructEmbedPtr) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("Name", v.Name == ""); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncString(v.Name); err != nil {
+			return err
+		}
+	}
+	if v.TestEmbedValue != nil {
+		if ok, err := w.EncField("Value", v.TestEmbedValue.Value == 0); err != nil {
+			return err
+		} else if ok {
+			if err := w.EncInt(int64(v.TestEmbedValue.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructEmbedPtr) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Name":
+			fv, err := r.DecString()
+			if err != nil {
+				return err
+			}
+			v.Name = fv
+		case "Value":
+			if v.TestEmbedValue == nil {
+				v.TestEmbedValue = new(TestEmbedValue)
+			}
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.TestEmbedValue.Value = int(fv)
+		default:
+			return r.UnknownFieldError("testGenStructEmbedPtr")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructEmbedUnexportedPtr) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("Name", v.Name == ""); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncString(v.Name); err != nil {
+			return err
+		}
+	}
+	if v.testEmbedSecret != nil {
+		if ok, err := w.EncField("Secret", v.testEmbedSecret.Secret == ""); err != nil {
+			return err
+		} else if ok {
+			if err := w.EncString(v.testEmbedSecret.Secret); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructEmbedUnexportedPtr) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "Name":
+			fv, err := r.DecString()
+			if err != nil {
+				return err
+			}
+			v.Name = fv
+		case "Secret":
+			if v.testEmbedSecret == nil {
+				return r.FieldError(fmt.Errorf("cannot set embedded pointer to unexported struct type rezi.testEmbedSecret: %w", ErrInvalidType))
+			}
+			fv, err := r.DecString()
+			if err != nil {
+				return err
+			}
+			v.testEmbedSecret.Secret = fv
+		default:
+			return r.UnknownFieldError("testGenStructEmbedUnexportedPtr")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructEmbedAmbiguous) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("A", v.testEmbedA.A == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.testEmbedA.A)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("B", v.testEmbedB.B == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.testEmbedB.B)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructEmbedAmbiguous) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "A":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.testEmbedA.A = int(fv)
+		case "B":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.testEmbedB.B = int(fv)
+		default:
+			return r.UnknownFieldError("testGenStructEmbedAmbiguous")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructAllKinds) MarshalREZI(w *Writer) error {
+	if ok, err := w.EncField("B", !v.B); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncBool(v.B); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("By", v.By == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncUint(uint64(v.By)); err != nil {
+			return err
+		}
+	}
+	if err := w.EncFieldValue("Counts", v.Counts); err != nil {
+		return err
+	}
+	if ok, err := w.EncField("F32", v.F32 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncFloat(float64(v.F32)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("F64", v.F64 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncFloat(v.F64); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("I", v.I == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.I)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("I16", v.I16 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.I16)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("I32", v.I32 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.I32)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("I64", v.I64 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(v.I64); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("I8", v.I8 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.I8)); err != nil {
+			return err
+		}
+	}
+	if err := w.EncFieldValue("List", v.List); err != nil {
+		return err
+	}
+	if err := w.EncFieldValue("Nested", v.Nested); err != nil {
+		return err
+	}
+	if err := w.EncFieldValue("NestedPtr", v.NestedPtr); err != nil {
+		return err
+	}
+	if err := w.EncFieldValue("Nums", v.Nums); err != nil {
+		return err
+	}
+	if err := w.EncFieldValue("Ptr", v.Ptr); err != nil {
+		return err
+	}
+	if ok, err := w.EncField("R", v.R == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncInt(int64(v.R)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("S", v.S == ""); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncString(v.S); err != nil {
+			return err
+		}
+	}
+	if err := w.EncFieldValue("Text", v.Text); err != nil {
+		return err
+	}
+	if ok, err := w.EncField("U", v.U == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncUint(uint64(v.U)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("U16", v.U16 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncUint(uint64(v.U16)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("U32", v.U32 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncUint(uint64(v.U32)); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("U64", v.U64 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncUint(v.U64); err != nil {
+			return err
+		}
+	}
+	if ok, err := w.EncField("U8", v.U8 == 0); err != nil {
+		return err
+	} else if ok {
+		if err := w.EncUint(uint64(v.U8)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructAllKinds) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "B":
+			fv, err := r.DecBool()
+			if err != nil {
+				return err
+			}
+			v.B = fv
+		case "By":
+			fv, err := r.DecUint()
+			if err != nil {
+				return err
+			}
+			if uint64(byte(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows byte: %w", fv, ErrRange))
+			}
+			v.By = byte(fv)
+		case "Counts":
+			if err := r.Dec(&v.Counts); err != nil {
+				return err
+			}
+		case "F32":
+			fv, err := r.DecFloat()
+			if err != nil {
+				return err
+			}
+			if !math.IsInf(fv, 0) && math.IsInf(float64(float32(fv)), 0) {
+				return r.FieldError(fmt.Errorf("decoded value %v overflows float32: %w", fv, ErrRange))
+			}
+			v.F32 = float32(fv)
+		case "F64":
+			fv, err := r.DecFloat()
+			if err != nil {
+				return err
+			}
+			v.F64 = fv
+		case "I":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int: %w", fv, ErrRange))
+			}
+			v.I = int(fv)
+		case "I16":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int16(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int16: %w", fv, ErrRange))
+			}
+			v.I16 = int16(fv)
+		case "I32":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int32(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int32: %w", fv, ErrRange))
+			}
+			v.I32 = int32(fv)
+		case "I64":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			v.I64 = fv
+		case "I8":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(int8(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows int8: %w", fv, ErrRange))
+			}
+			v.I8 = int8(fv)
+		case "List":
+			if err := r.Dec(&v.List); err != nil {
+				return err
+			}
+		case "Nested":
+			if err := r.Dec(&v.Nested); err != nil {
+				return err
+			}
+		case "NestedPtr":
+			if err := r.Dec(&v.NestedPtr); err != nil {
+				return err
+			}
+		case "Nums":
+			if err := r.Dec(&v.Nums); err != nil {
+				return err
+			}
+		case "Ptr":
+			if err := r.Dec(&v.Ptr); err != nil {
+				return err
+			}
+		case "R":
+			fv, err := r.DecInt()
+			if err != nil {
+				return err
+			}
+			if int64(rune(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows rune: %w", fv, ErrRange))
+			}
+			v.R = rune(fv)
+		case "S":
+			fv, err := r.DecString()
+			if err != nil {
+				return err
+			}
+			v.S = fv
+		case "Text":
+			if err := r.Dec(&v.Text); err != nil {
+				return err
+			}
+		case "U":
+			fv, err := r.DecUint()
+			if err != nil {
+				return err
+			}
+			if uint64(uint(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows uint: %w", fv, ErrRange))
+			}
+			v.U = uint(fv)
+		case "U16":
+			fv, err := r.DecUint()
+			if err != nil {
+				return err
+			}
+			if uint64(uint16(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows uint16: %w", fv, ErrRange))
+			}
+			v.U16 = uint16(fv)
+		case "U32":
+			fv, err := r.DecUint()
+			if err != nil {
+				return err
+			}
+			if uint64(uint32(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows uint32: %w", fv, ErrRange))
+			}
+			v.U32 = uint32(fv)
+		case "U64":
+			fv, err := r.DecUint()
+			if err != nil {
+				return err
+			}
+			v.U64 = fv
+		case "U8":
+			fv, err := r.DecUint()
+			if err != nil {
+				return err
+			}
+			if uint64(uint8(fv)) != fv {
+				return r.FieldError(fmt.Errorf("decoded value %d overflows uint8: %w", fv, ErrRange))
+			}
+			v.U8 = uint8(fv)
+		default:
+			return r.UnknownFieldError("testGenStructAllKinds")
+		}
+	}
+
+	return nil
+}
+
+// MarshalREZI writes the REZI encoding of v to w. It implements
+// Marshaler.
+func (v testGenStructSharedPtrs) MarshalREZI(w *Writer) error {
+	if err := w.EncFieldValue("A", v.A); err != nil {
+		return err
+	}
+	if err := w.EncFieldValue("B", v.B); err != nil {
+		return err
+	}
+	return nil
+}
+
+// UnmarshalREZI reads the REZI encoding of v from r. It implements
+// Unmarshaler.
+func (v *testGenStructSharedPtrs) UnmarshalREZI(r *Reader) error {
+	for r.More() {
+		name, err := r.DecField()
+		if err != nil {
+			return err
+		}
+
+		switch name {
+		case "A":
+			if err := r.Dec(&v.A); err != nil {
+				return err
+			}
+		case "B":
+			if err := r.Dec(&v.B); err != nil {
+				return err
+			}
+		default:
+			return r.UnknownFieldError("testGenStructSharedPtrs")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,723 @@
+package rezi
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//go:generate go run ./cmd/rezigen -type testGenStructEmpty,testGenStructOneMember,testGenStructMultiMember,testGenStructWithUnexported,testGenStructWithUnexportedCaseDistinguished,testGenStructOnlyUnexported,testGenStructManyFields,testGenStructWithAnonymousTypedMember,testGenStructEmbedUnexported,testGenStructEmbedPtr,testGenStructEmbedUnexportedPtr,testGenStructEmbedAmbiguous,testGenStructAllKinds,testGenStructSharedPtrs marshaler_test.go structs_test.go
+
+// the testGen types are the test fixture types of the same name without the
+// "Gen", but have generated MarshalREZI and UnmarshalREZI methods. A fixture
+// value is converted to its testGen type to check that the generated methods
+// encode it exactly as reflection does.
+
+type testGenStructEmpty testStructEmpty
+
+type testGenStructOneMember testStructOneMember
+
+type testGenStructMultiMember testStructMultiMember
+
+type testGenStructWithUnexported testStructWithUnexported
+
+type testGenStructWithUnexportedCaseDistinguished testStructWithUnexportedCaseDistinguished
+
+type testGenStructOnlyUnexported testStructOnlyUnexported
+
+type testGenStructManyFields testStructManyFields
+
+type testGenStructWithAnonymousTypedMember testStructWithAnonymousTypedMember
+
+type testGenStructEmbedUnexported testStructEmbedUnexported
+
+type testGenStructEmbedPtr testStructEmbedPtr
+
+type testGenStructEmbedUnexportedPtr testStructEmbedUnexportedPtr
+
+type testGenStructEmbedAmbiguous testStructEmbedAmbiguous
+
+type testGenStructSharedPtrs testStructSharedPtrs
+
+// testGenStructAllKinds is testStructAllKinds but with generated methods, and
+// with testGen types for its nested struct fields so that generated methods
+// are also called for them.
+type testGenStructAllKinds struct {
+	S         string
+	B         bool
+	I         int
+	I8        int8
+	I16       int16
+	I32       int32
+	I64       int64
+	R         rune
+	U         uint
+	U8        uint8
+	U16       uint16
+	U32       uint32
+	U64       uint64
+	By        byte
+	F32       float32
+	F64       float64
+	Ptr       *int
+	List      []string
+	Nums      []int
+	Counts    map[string]int
+	Text      testText
+	Nested    testGenStructMultiMember
+	NestedPtr *testGenStructOneMember
+}
+
+// testStructSharedPtrs has two pointer fields that can point to the same
+// value.
+type testStructSharedPtrs struct {
+	A *int
+	B *int
+}
+
+// testStructAllKinds has a field of every kind that generated code encodes
+// differently.
+type testStructAllKinds struct {
+	S         string
+	B         bool
+	I         int
+	I8        int8
+	I16       int16
+	I32       int32
+	I64       int64
+	R         rune
+	U         uint
+	U8        uint8
+	U16       uint16
+	U32       uint32
+	U64       uint64
+	By        byte
+	F32       float32
+	F64       float64
+	Ptr       *int
+	List      []string
+	Nums      []int
+	Counts    map[string]int
+	Text      testText
+	Nested    testStructMultiMember
+	NestedPtr *testStructOneMember
+}
+
+func Test_Enc_Marshaler_MatchesReflection(t *testing.T) {
+	ptrVal := -413
+
+	oneMember := testStructOneMember{Value: 1}
+	multiMember := testStructMultiMember{Value: 1, Name: "Rose Lalonde"}
+	withUnexported := testStructWithUnexported{Value: 8, unexported: 2.5}
+	caseDistinguished := testStructWithUnexportedCaseDistinguished{Value: 8, value: 2.5}
+	onlyUnexported := testStructOnlyUnexported{value: 4, name: "Kanaya"}
+	manyFields := testStructManyFields{Name: "Jade", Factor: 8.25, Value: 612, Enabled: true, inc: 4}
+	anonymousTyped := testStructWithAnonymousTypedMember{Name: struct {
+		First string
+		Last  string
+	}{First: "Dave", Last: "Strider"}}
+	pointed := testStructMultiMember{Value: 413, Name: "John"}
+	embedUnexported := testStructEmbedUnexported{testEmbedSecret{Secret: "x"}, "Sollux"}
+	embedPtr := testStructEmbedPtr{&TestEmbedValue{Value: 8}, "Eridan"}
+	embedNilPtr := testStructEmbedPtr{Name: "Feferi"}
+	embedUnexportedPtr := testStructEmbedUnexportedPtr{&testEmbedSecret{Secret: "y"}, "Aradia"}
+	embedAmbiguous := testStructEmbedAmbiguous{testEmbedA{A: 1, X: 2}, testEmbedB{B: 3, X: 4}}
+
+	testCases := []struct {
+		name      string
+		generated interface{}
+		reflected interface{}
+	}{
+		{name: "empty struct", generated: testGenStructEmpty{}, reflected: testStructEmpty{}},
+		{name: "one member", generated: testGenStructOneMember(oneMember), reflected: oneMember},
+		{name: "multi member", generated: testGenStructMultiMember(multiMember), reflected: multiMember},
+		{name: "with unexported", generated: testGenStructWithUnexported(withUnexported), reflected: withUnexported},
+		{
+			name:      "with unexported case distinguished",
+			generated: testGenStructWithUnexportedCaseDistinguished(caseDistinguished),
+			reflected: caseDistinguished,
+		},
+		{name: "only unexported", generated: testGenStructOnlyUnexported(onlyUnexported), reflected: onlyUnexported},
+		{name: "many fields", generated: testGenStructManyFields(manyFields), reflected: manyFields},
+		{
+			name:      "anonymous typed member",
+			generated: testGenStructWithAnonymousTypedMember(anonymousTyped),
+			reflected: anonymousTyped,
+		},
+		{
+			name:      "unexported embedded struct",
+			generated: testGenStructEmbedUnexported(embedUnexported),
+			reflected: embedUnexported,
+		},
+		{name: "pointer embedded struct", generated: testGenStructEmbedPtr(embedPtr), reflected: embedPtr},
+		{name: "nil pointer embedded struct", generated: testGenStructEmbedPtr(embedNilPtr), reflected: embedNilPtr},
+		{
+			name:      "unexported pointer embedded struct",
+			generated: testGenStructEmbedUnexportedPtr(embedUnexportedPtr),
+			reflected: embedUnexportedPtr,
+		},
+		{name: "ambiguous embedded fields", generated: testGenStructEmbedAmbiguous(embedAmbiguous), reflected: embedAmbiguous},
+		{
+			name:      "pointer to generated",
+			generated: (*testGenStructMultiMember)(&pointed),
+			reflected: &pointed,
+		},
+		{
+			name:      "nil pointer to generated",
+			generated: (*testGenStructMultiMember)(nil),
+			reflected: (*testStructMultiMember)(nil),
+		},
+		{
+			name: "all kinds",
+			generated: testGenStructAllKinds{
+				S: "Terezi", B: true, I: -1, I8: -8, I16: 1600, I32: -320000, I64: 1 << 40, R: 'T',
+				U: 1, U8: 255, U16: 65535, U32: 1 << 31, U64: 1 << 63, By: 0x41, F32: 8.5, F64: -0.125,
+				Ptr: &ptrVal, List: []string{"a", "b"}, Counts: map[string]int{"x": 1, "y": 2},
+				Text:      testText{value: 8, enabled: true, name: "pyrope"},
+				Nested:    testGenStructMultiMember{Value: 2, Name: "Vriska"},
+				NestedPtr: &testGenStructOneMember{Value: 3},
+			},
+			reflected: testStructAllKinds{
+				S: "Terezi", B: true, I: -1, I8: -8, I16: 1600, I32: -320000, I64: 1 << 40, R: 'T',
+				U: 1, U8: 255, U16: 65535, U32: 1 << 31, U64: 1 << 63, By: 0x41, F32: 8.5, F64: -0.125,
+				Ptr: &ptrVal, List: []string{"a", "b"}, Counts: map[string]int{"x": 1, "y": 2},
+				Text:      testText{value: 8, enabled: true, name: "pyrope"},
+				Nested:    testStructMultiMember{Value: 2, Name: "Vriska"},
+				NestedPtr: &testStructOneMember{Value: 3},
+			},
+		},
+		{
+			name:      "all kinds zero",
+			generated: testGenStructAllKinds{},
+			reflected: testStructAllKinds{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			info, err := canEncode(tc.generated)
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(mtRezi, info.Main, "generated type not detected as a Marshaler")
+
+			expect, err := Enc(tc.reflected)
+			if !assert.NoError(err) {
+				return
+			}
+
+			actual, err := Enc(tc.generated)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(expect, actual)
+		})
+	}
+}
+
+func Test_EncWithFormat_Marshaler_MatchesReflection(t *testing.T) {
+	ptrVal := 612
+	negZero := math.Copysign(0, -1)
+
+	testCases := []struct {
+		name      string
+		generated interface{}
+		reflected interface{}
+
+		// fields is the fields selected when the format masks them.
+		fields []string
+	}{
+		{
+			name: "all kinds",
+			generated: testGenStructAllKinds{
+				B: true, I8: -8, I16: 1600, R: 'T', U: 1, U64: 1 << 63, F32: 8.5, F64: negZero,
+				Ptr: &ptrVal, List: []string{"a"}, Nums: []int{3, 1, 4}, Counts: map[string]int{"x": 1},
+				Nested:    testGenStructMultiMember{Name: "Vriska"},
+				NestedPtr: &testGenStructOneMember{Value: 3},
+			},
+			reflected: testStructAllKinds{
+				B: true, I8: -8, I16: 1600, R: 'T', U: 1, U64: 1 << 63, F32: 8.5, F64: negZero,
+				Ptr: &ptrVal, List: []string{"a"}, Nums: []int{3, 1, 4}, Counts: map[string]int{"x": 1},
+				Nested:    testStructMultiMember{Name: "Vriska"},
+				NestedPtr: &testStructOneMember{Value: 3},
+			},
+			fields: []string{"B", "F64", "Nested.Name", "NestedPtr", "Nums"},
+		},
+		{
+			name:      "all kinds zero",
+			generated: testGenStructAllKinds{},
+			reflected: testStructAllKinds{},
+			fields:    []string{"I", "Nested"},
+		},
+		{
+			name:      "nil pointer embedded struct",
+			generated: testGenStructEmbedPtr{Name: "Feferi"},
+			reflected: testStructEmbedPtr{Name: "Feferi"},
+			fields:    []string{"Name"},
+		},
+		{
+			name: "slice of generated",
+			generated: []testGenStructMultiMember{
+				{Value: 1, Name: "Jane"},
+				{Name: "Jake"},
+				{Value: 3, Name: "Roxy"},
+			},
+			reflected: []testStructMultiMember{
+				{Value: 1, Name: "Jane"},
+				{Name: "Jake"},
+				{Value: 3, Name: "Roxy"},
+			},
+			fields: []string{"Value"},
+		},
+	}
+
+	formats := []struct {
+		name   string
+		f      Format
+		masked bool
+	}{
+		{name: "default"},
+		{name: "OmitEmpty", f: Format{OmitEmpty: true}},
+		{name: "ConvertNumbers", f: Format{ConvertNumbers: true}},
+		{name: "FieldNameTable", f: Format{FieldNameTable: true}},
+		{name: "TrackReferences", f: Format{TrackReferences: true}},
+		{name: "Packing", f: Format{Packing: PackVarint}},
+		{name: "EncFields", masked: true},
+		{
+			name:   "all options",
+			f:      Format{OmitEmpty: true, ConvertNumbers: true, FieldNameTable: true, TrackReferences: true, Packing: PackDelta},
+			masked: true,
+		},
+	}
+
+	for _, format := range formats {
+		for _, tc := range testCases {
+			t.Run(format.name+"/"+tc.name, func(t *testing.T) {
+				assert := assert.New(t)
+
+				f := format.f
+				enc := func(v interface{}) ([]byte, error) {
+					if format.masked {
+						return EncFieldsWithFormat(v, &f, tc.fields...)
+					}
+					return EncWithFormat(v, &f)
+				}
+
+				expect, err := enc(tc.reflected)
+				if !assert.NoError(err) {
+					return
+				}
+
+				actual, err := enc(tc.generated)
+				if !assert.NoError(err) {
+					return
+				}
+
+				assert.Equal(expect, actual)
+			})
+		}
+	}
+}
+
+func Test_Dec_Unmarshaler(t *testing.T) {
+	t.Run("all kinds", func(t *testing.T) {
+		assert := assert.New(t)
+
+		ptrVal := 612
+		input := testStructAllKinds{
+			S: "Karkat", B: true, I: 8, I8: 127, I16: -1600, I32: 320000, I64: -(1 << 40), R: 'K',
+			U: 2, U8: 1, U16: 256, U32: 1 << 30, U64: 1<<64 - 1, By: 0x42, F32: -2.25, F64: 413.612,
+			Ptr: &ptrVal, List: []string{"c"}, Counts: map[string]int{"z": 3},
+			Text:      testText{value: 9, enabled: false, name: "cancer"},
+			Nested:    testStructMultiMember{Value: 4, Name: "Gamzee"},
+			NestedPtr: &testStructOneMember{Value: 5},
+		}
+		expect := testGenStructAllKinds{
+			S: "Karkat", B: true, I: 8, I8: 127, I16: -1600, I32: 320000, I64: -(1 << 40), R: 'K',
+			U: 2, U8: 1, U16: 256, U32: 1 << 30, U64: 1<<64 - 1, By: 0x42, F32: -2.25, F64: 413.612,
+			Ptr: &ptrVal, List: []string{"c"}, Counts: map[string]int{"z": 3},
+			Text:      testText{value: 9, enabled: false, name: "cancer"},
+			Nested:    testGenStructMultiMember{Value: 4, Name: "Gamzee"},
+			NestedPtr: &testGenStructOneMember{Value: 5},
+		}
+		data := MustEnc(input)
+
+		info, err := canDecode(&testGenStructAllKinds{})
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(mtRezi, info.Main, "generated type not detected as an Unmarshaler")
+
+		var actual testGenStructAllKinds
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("field-name table", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []testStructAllKinds{
+			{S: "Karkat", I8: 8, Nested: testStructMultiMember{Value: 4, Name: "Gamzee"}},
+			{S: "Nepeta", NestedPtr: &testStructOneMember{Value: 5}},
+		}
+		expect := []testGenStructAllKinds{
+			{S: "Karkat", I8: 8, Nested: testGenStructMultiMember{Value: 4, Name: "Gamzee"}},
+			{S: "Nepeta", NestedPtr: &testGenStructOneMember{Value: 5}},
+		}
+		f := &Format{FieldNameTable: true}
+		data, err := EncWithFormat(input, f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual []testGenStructAllKinds
+		n, err := DecWithFormat(data, &actual, f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("embedded structs", func(t *testing.T) {
+		testCases := []struct {
+			name   string
+			input  interface{}
+			recv   interface{}
+			expect interface{}
+		}{
+			{
+				name:   "unexported embedded struct",
+				input:  testStructEmbedUnexported{testEmbedSecret{Secret: "x"}, "Sollux"},
+				recv:   &testGenStructEmbedUnexported{},
+				expect: &testGenStructEmbedUnexported{testEmbedSecret{Secret: "x"}, "Sollux"},
+			},
+			{
+				name:   "nil pointer embedded struct",
+				input:  testStructEmbedPtr{&TestEmbedValue{Value: 8}, "Eridan"},
+				recv:   &testGenStructEmbedPtr{},
+				expect: &testGenStructEmbedPtr{&TestEmbedValue{Value: 8}, "Eridan"},
+			},
+			{
+				name:   "non-nil unexported pointer embedded struct",
+				input:  testStructEmbedUnexportedPtr{&testEmbedSecret{Secret: "y"}, "Aradia"},
+				recv:   &testGenStructEmbedUnexportedPtr{&testEmbedSecret{}, ""},
+				expect: &testGenStructEmbedUnexportedPtr{&testEmbedSecret{Secret: "y"}, "Aradia"},
+			},
+			{
+				name:   "ambiguous embedded fields",
+				input:  testStructEmbedAmbiguous{testEmbedA{A: 1, X: 2}, testEmbedB{B: 3, X: 4}},
+				recv:   &testGenStructEmbedAmbiguous{},
+				expect: &testGenStructEmbedAmbiguous{testEmbedA{A: 1}, testEmbedB{B: 3}},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				assert := assert.New(t)
+				data := MustEnc(tc.input)
+
+				n, err := Dec(data, tc.recv)
+				if !assert.NoError(err) {
+					return
+				}
+
+				assert.Equal(len(data), n)
+				assert.Equal(tc.expect, tc.recv)
+			})
+		}
+	})
+
+	t.Run("nil unexported pointer embedded struct", func(t *testing.T) {
+		assert := assert.New(t)
+		data := MustEnc(testStructEmbedUnexportedPtr{&testEmbedSecret{Secret: "y"}, "Aradia"})
+
+		var reflected testStructEmbedUnexportedPtr
+		_, err := Dec(data, &reflected)
+		var expect *DecodeError
+		if !assert.ErrorAs(err, &expect) {
+			return
+		}
+
+		var generated testGenStructEmbedUnexportedPtr
+		_, err = Dec(data, &generated)
+		var actual *DecodeError
+		if !assert.ErrorAs(err, &actual) {
+			return
+		}
+
+		assert.ErrorIs(err, ErrInvalidType)
+		assert.Equal(expect.Offset, actual.Offset)
+		assert.Equal(expect.Path, actual.Path)
+	})
+
+	t.Run("unexported fields are kept", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := MustEnc(testStructWithUnexported{Value: 8})
+		expect := testGenStructWithUnexported{Value: 8, unexported: 2.5}
+
+		actual := testGenStructWithUnexported{Value: 1, unexported: 2.5}
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("pointer to generated", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := MustEnc(&testStructMultiMember{Value: 413, Name: "John"})
+		expect := &testGenStructMultiMember{Value: 413, Name: "John"}
+
+		var actual *testGenStructMultiMember
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := MustEnc(testStructMultiMember{Value: 413, Name: "John"})
+
+		var actual testGenStructOneMember
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("error offset is within complete data", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := MustEnc(testStructMultiMember{Value: 413, Name: "John"})
+
+		// make the int header of Value claim more bytes than there are
+		valueOffset := len(data) - 3
+		data[valueOffset] = 0x08
+
+		var actual testGenStructMultiMember
+		_, err := Dec(data, &actual)
+
+		// the bytes run out right after the header
+		assert.ErrorIs(err, io.ErrUnexpectedEOF)
+		assert.Regexp(fmt.Sprintf("^at offset 0x%02x: ", valueOffset+1), err.Error())
+	})
+
+	t.Run("out of range", func(t *testing.T) {
+		testCases := []struct {
+			name  string
+			input interface{}
+		}{
+			{name: "int8", input: struct{ I8 int }{I8: 300}},
+			{name: "uint8", input: struct{ U8 int }{U8: -1}},
+			{name: "uint64", input: struct{ U64 int }{U64: -1}},
+			{name: "int64", input: struct{ I64 uint64 }{I64: 1<<63 + 5}},
+			{name: "float32", input: struct{ F32 float64 }{F32: 1e300}},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				assert := assert.New(t)
+				data := MustEnc(tc.input)
+
+				var reflected testStructAllKinds
+				_, err := Dec(data, &reflected)
+				assert.ErrorIs(err, ErrRange, "reflective decoding")
+
+				var generated testGenStructAllKinds
+				_, err = Dec(data, &generated)
+				assert.ErrorIs(err, ErrRange, "generated decoding")
+			})
+		}
+	})
+
+	t.Run("not enough bytes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := MustEnc(testStructMultiMember{Value: 413, Name: "John"})
+
+		var actual testGenStructMultiMember
+		_, err := Dec(data[:len(data)-2], &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+}
+
+func Test_Dec_Unmarshaler_ErrorsMatchReflection(t *testing.T) {
+	type numsSource struct{ Nums []uint64 }
+	type narrowSource struct{ I8 int }
+	type unknownSource struct{ Nope int }
+
+	testCases := []struct {
+		name      string
+		input     interface{}
+		expectErr []error
+	}{
+		{
+			name:      "number out of range of field",
+			input:     struct{ R narrowSource }{narrowSource{I8: 300}},
+			expectErr: []error{ErrRange},
+		},
+		{
+			name:      "error within field",
+			input:     struct{ R numsSource }{numsSource{Nums: []uint64{1, 1<<64 - 1}}},
+			expectErr: []error{ErrRange},
+		},
+		{
+			name:      "unknown field",
+			input:     struct{ R unknownSource }{unknownSource{Nope: 1}},
+			expectErr: []error{ErrMalformedData, ErrInvalidType},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			data := MustEnc(tc.input)
+
+			var reflected struct{ R testStructAllKinds }
+			_, err := Dec(data, &reflected)
+			var expect *DecodeError
+			if !assert.ErrorAs(err, &expect) {
+				return
+			}
+
+			var generated struct{ R testGenStructAllKinds }
+			_, err = Dec(data, &generated)
+			var actual *DecodeError
+			if !assert.ErrorAs(err, &actual) {
+				return
+			}
+
+			assert.Equal(expect.Offset, actual.Offset)
+			assert.Equal(expect.Path, actual.Path)
+			for _, expectErr := range tc.expectErr {
+				assert.ErrorIs(err, expectErr)
+			}
+		})
+	}
+}
+
+func Test_DecWithFormat_Unmarshaler_References(t *testing.T) {
+	t.Run("shared pointer fields", func(t *testing.T) {
+		assert := assert.New(t)
+
+		val := 413
+		f := Format{TrackReferences: true}
+
+		data, err := EncWithFormat(testGenStructSharedPtrs{A: &val, B: &val}, &f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		expect, err := EncWithFormat(testStructSharedPtrs{A: &val, B: &val}, &f)
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(expect, data)
+
+		var actual testGenStructSharedPtrs
+		n, err := DecWithFormat(data, &actual, &f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(413, *actual.A)
+		assert.Same(actual.A, actual.B)
+	})
+}
+
+func Test_ReflectionFreeHelpers(t *testing.T) {
+	testCases := []struct {
+		name   string
+		enc    []byte
+		expect []byte
+		dec    func([]byte) (interface{}, int, error)
+		value  interface{}
+	}{
+		{
+			name:   "bool",
+			enc:    EncBool(true),
+			expect: MustEnc(true),
+			dec:    func(b []byte) (interface{}, int, error) { return DecBool(b) },
+			value:  true,
+		},
+		{
+			name:   "int",
+			enc:    EncInt(-413),
+			expect: MustEnc(int16(-413)),
+			dec:    func(b []byte) (interface{}, int, error) { return DecInt(b) },
+			value:  int64(-413),
+		},
+		{
+			name:   "uint",
+			enc:    EncUint(612),
+			expect: MustEnc(uint32(612)),
+			dec:    func(b []byte) (interface{}, int, error) { return DecUint(b) },
+			value:  uint64(612),
+		},
+		{
+			name:   "float",
+			enc:    EncFloat(8.25),
+			expect: MustEnc(float32(8.25)),
+			dec:    func(b []byte) (interface{}, int, error) { return DecFloat(b) },
+			value:  8.25,
+		},
+		{
+			name:   "string",
+			enc:    EncString("Terezi"),
+			expect: MustEnc("Terezi"),
+			dec:    func(b []byte) (interface{}, int, error) { return DecString(b) },
+			value:  "Terezi",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			assert.Equal(tc.expect, tc.enc)
+
+			actual, n, err := tc.dec(tc.enc)
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(len(tc.enc), n)
+			assert.Equal(tc.value, actual)
+		})
+	}
+
+	t.Run("count", func(t *testing.T) {
+		assert := assert.New(t)
+		data := MustEnc([]string{"a", "b"})
+
+		count, n, err := DecCount(data)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data)-n, count)
+	})
+}
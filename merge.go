@@ -0,0 +1,87 @@
+package rezi
+
+// merge.go contains functions for combining decoded maps and slices with the
+// values already held by the receiver.
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MergeMode is how decoded maps and slices are combined with the maps and
+// slices that the receiver already holds. It is selected with [Format.Merge].
+//
+// Regardless of MergeMode, a struct is always decoded by setting only the
+// fields that are present in the data. When any MergeMode other than
+// MergeNone is used, this also applies to a struct field whose type is itself
+// a struct, and to the fields of that struct in turn. It does not apply to
+// structs that are items of a slice or values of a map; each of those is
+// decoded as a new value that replaces any existing item or entry.
+type MergeMode int
+
+const (
+	// MergeNone replaces every map and slice with a new one holding only the
+	// decoded values. It is the default.
+	MergeNone MergeMode = iota
+
+	// MergeAppend inserts decoded map entries into the existing map, replacing
+	// any existing entries with the same keys, and appends decoded slice items
+	// to the existing slice.
+	MergeAppend
+
+	// MergeOverwrite inserts decoded map entries into the existing map as for
+	// MergeAppend, and writes decoded slice items over the items of the
+	// existing slice starting at index 0. Items are decoded directly into the
+	// existing slice's backing array; if there are more items than it has
+	// capacity for, the slice grows as it would with append. The resulting
+	// slice has exactly as many items as were decoded.
+	MergeOverwrite
+)
+
+// String returns the name of the MergeMode.
+func (m MergeMode) String() string {
+	switch m {
+	case MergeNone:
+		return "none"
+	case MergeAppend:
+		return "append"
+	case MergeOverwrite:
+		return "overwrite"
+	default:
+		return fmt.Sprintf("MergeMode(%d)", int(m))
+	}
+}
+
+// merging returns the MergeMode to use for decoding.
+func (s *session) merging() MergeMode {
+	if s == nil {
+		return MergeNone
+	}
+	return s.f.Merge
+}
+
+// mergeSlice combines the decoded slice with the existing slice as given by
+// mode.
+func mergeSlice(existing, decoded reflect.Value, mode MergeMode) reflect.Value {
+	switch mode {
+	case MergeAppend:
+		if existing.IsNil() {
+			return decoded
+		}
+		if decoded.Len() == 0 {
+			return existing
+		}
+		return reflect.AppendSlice(existing, decoded)
+	case MergeOverwrite:
+		// decoded may have already been written into existing's backing
+		// array.
+		if decoded.IsNil() || existing.Cap() < decoded.Len() || decoded.Pointer() == existing.Pointer() {
+			return decoded
+		}
+		sl := existing.Slice(0, decoded.Len())
+		reflect.Copy(sl, decoded)
+		return sl
+	default:
+		return decoded
+	}
+}
@@ -0,0 +1,363 @@
+package rezi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testMergeInner struct {
+	A int
+	B int
+}
+
+type testMergeConfig struct {
+	Name  string
+	Attrs map[string]string
+	Ports []int
+	Inner testMergeInner
+}
+
+func Test_MergeMode_String(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("none", MergeNone.String())
+	assert.Equal("append", MergeAppend.String())
+	assert.Equal("overwrite", MergeOverwrite.String())
+	assert.Equal("MergeMode(8)", MergeMode(8).String())
+}
+
+func Test_DecWithFormat_Merge_Map(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mode     MergeMode
+		existing map[string]int
+		input    map[string]int
+		expect   map[string]int
+	}{
+		{
+			name:     "no merge replaces map",
+			mode:     MergeNone,
+			existing: map[string]int{"a": 1, "b": 2},
+			input:    map[string]int{"b": 3, "c": 4},
+			expect:   map[string]int{"b": 3, "c": 4},
+		},
+		{
+			name:     "append inserts entries",
+			mode:     MergeAppend,
+			existing: map[string]int{"a": 1, "b": 2},
+			input:    map[string]int{"b": 3, "c": 4},
+			expect:   map[string]int{"a": 1, "b": 3, "c": 4},
+		},
+		{
+			name:     "overwrite inserts entries",
+			mode:     MergeOverwrite,
+			existing: map[string]int{"a": 1, "b": 2},
+			input:    map[string]int{"b": 3, "c": 4},
+			expect:   map[string]int{"a": 1, "b": 3, "c": 4},
+		},
+		{
+			name:     "empty map keeps existing",
+			mode:     MergeAppend,
+			existing: map[string]int{"a": 1},
+			input:    map[string]int{},
+			expect:   map[string]int{"a": 1},
+		},
+		{
+			name:     "nil map keeps existing",
+			mode:     MergeAppend,
+			existing: map[string]int{"a": 1},
+			input:    nil,
+			expect:   map[string]int{"a": 1},
+		},
+		{
+			name:     "nil existing map",
+			mode:     MergeAppend,
+			existing: nil,
+			input:    map[string]int{"c": 4},
+			expect:   map[string]int{"c": 4},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			data := MustEnc(tc.input)
+
+			actual := tc.existing
+			n, err := DecWithFormat(data, &actual, &Format{Merge: tc.mode})
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(len(data), n)
+			assert.Equal(tc.expect, actual)
+		})
+	}
+
+	t.Run("entries are inserted into the same map", func(t *testing.T) {
+		assert := assert.New(t)
+		existing := map[string]int{"a": 1}
+
+		actual := existing
+		_, err := DecWithFormat(MustEnc(map[string]int{"b": 2}), &actual, &Format{Merge: MergeAppend})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(map[string]int{"a": 1, "b": 2}, existing)
+	})
+}
+
+func Test_DecWithFormat_Merge_Slice(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mode     MergeMode
+		packing  Packing
+		existing []int
+		input    []int
+		expect   []int
+	}{
+		{
+			name:     "no merge replaces slice",
+			mode:     MergeNone,
+			existing: []int{1, 2},
+			input:    []int{3},
+			expect:   []int{3},
+		},
+		{
+			name:     "append",
+			mode:     MergeAppend,
+			existing: []int{1, 2},
+			input:    []int{3},
+			expect:   []int{1, 2, 3},
+		},
+		{
+			name:     "append packed",
+			mode:     MergeAppend,
+			packing:  PackVarint,
+			existing: []int{1, 2},
+			input:    []int{3},
+			expect:   []int{1, 2, 3},
+		},
+		{
+			name:     "append empty",
+			mode:     MergeAppend,
+			existing: []int{1, 2},
+			input:    []int{},
+			expect:   []int{1, 2},
+		},
+		{
+			name:     "overwrite shorter",
+			mode:     MergeOverwrite,
+			existing: []int{1, 2, 3},
+			input:    []int{8, 9},
+			expect:   []int{8, 9},
+		},
+		{
+			name:     "overwrite longer",
+			mode:     MergeOverwrite,
+			existing: []int{1},
+			input:    []int{7, 8, 9},
+			expect:   []int{7, 8, 9},
+		},
+		{
+			name:     "overwrite with nil",
+			mode:     MergeOverwrite,
+			existing: []int{1},
+			input:    nil,
+			expect:   nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			data, err := EncWithFormat(tc.input, &Format{Packing: tc.packing})
+			if !assert.NoError(err) {
+				return
+			}
+
+			actual := tc.existing
+			n, err := DecWithFormat(data, &actual, &Format{Merge: tc.mode})
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(len(data), n)
+			assert.Equal(tc.expect, actual)
+		})
+	}
+
+	t.Run("overwrite reuses capacity", func(t *testing.T) {
+		assert := assert.New(t)
+		buf := make([]int, 1, 8)
+
+		actual := buf
+		_, err := DecWithFormat(MustEnc([]int{4, 5, 6}), &actual, &Format{Merge: MergeOverwrite})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal([]int{4, 5, 6}, actual)
+		assert.Equal(8, cap(actual))
+		assert.Equal([]int{4, 5, 6}, buf[:3])
+	})
+
+	t.Run("overwrite grows past capacity", func(t *testing.T) {
+		assert := assert.New(t)
+		buf := make([]int, 1, 2)
+
+		actual := buf
+		_, err := DecWithFormat(MustEnc([]int{4, 5, 6}), &actual, &Format{Merge: MergeOverwrite})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal([]int{4, 5, 6}, actual)
+	})
+
+	t.Run("overwrite with empty keeps backing array", func(t *testing.T) {
+		assert := assert.New(t)
+		buf := make([]int, 2, 4)
+
+		actual := buf
+		_, err := DecWithFormat(MustEnc([]int{}), &actual, &Format{Merge: MergeOverwrite})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal([]int{}, actual)
+		assert.Equal(4, cap(actual))
+	})
+
+	t.Run("arrays are not merged", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual := [3]int{1, 2, 3}
+		_, err := DecWithFormat(MustEnc([3]int{4, 0, 0}), &actual, &Format{Merge: MergeAppend})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal([3]int{4, 0, 0}, actual)
+	})
+}
+
+func Test_DecWithFormat_Merge_Struct(t *testing.T) {
+	t.Run("layered structs", func(t *testing.T) {
+		assert := assert.New(t)
+		base := testMergeConfig{
+			Name:  "base",
+			Attrs: map[string]string{"a": "1", "b": "2"},
+			Ports: []int{80},
+			Inner: testMergeInner{A: 1, B: 2},
+		}
+		layer := testMergeConfig{
+			Attrs: map[string]string{"b": "3"},
+			Ports: []int{443},
+			Inner: testMergeInner{B: 5},
+		}
+		expect := testMergeConfig{
+			Name:  "base",
+			Attrs: map[string]string{"a": "1", "b": "3"},
+			Ports: []int{80, 443},
+			Inner: testMergeInner{A: 1, B: 5},
+		}
+		data, err := EncWithFormat(layer, &Format{OmitEmpty: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		actual := base
+		_, err = DecWithFormat(data, &actual, &Format{Merge: MergeAppend})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("nested struct is replaced without merge", func(t *testing.T) {
+		assert := assert.New(t)
+		data, err := EncWithFormat(testMergeConfig{Inner: testMergeInner{B: 5}}, &Format{OmitEmpty: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		actual := testMergeConfig{Name: "base", Inner: testMergeInner{A: 1, B: 2}}
+		_, err = Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(testMergeConfig{Name: "base", Inner: testMergeInner{B: 5}}, actual)
+	})
+
+	t.Run("struct items of slices and maps are replaced", func(t *testing.T) {
+		assert := assert.New(t)
+		type holder struct {
+			List []testMergeInner
+			ByID map[string]testMergeInner
+		}
+		data, err := EncWithFormat(holder{
+			List: []testMergeInner{{B: 5}},
+			ByID: map[string]testMergeInner{"a": {B: 6}},
+		}, &Format{OmitEmpty: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		actual := holder{
+			List: []testMergeInner{{A: 1, B: 2}},
+			ByID: map[string]testMergeInner{"a": {A: 3, B: 4}},
+		}
+		_, err = DecWithFormat(data, &actual, &Format{Merge: MergeOverwrite})
+		if !assert.NoError(err) {
+			return
+		}
+
+		expect := holder{
+			List: []testMergeInner{{B: 5}},
+			ByID: map[string]testMergeInner{"a": {B: 6}},
+		}
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("pointer to struct", func(t *testing.T) {
+		assert := assert.New(t)
+		data := MustEnc(&testMergeConfig{Attrs: map[string]string{"b": "3"}})
+
+		existing := &testMergeConfig{Attrs: map[string]string{"a": "1"}}
+		actual := existing
+		_, err := DecWithFormat(data, &actual, &Format{Merge: MergeAppend, TrackReferences: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(map[string]string{"a": "1", "b": "3"}, actual.Attrs)
+	})
+}
+
+func Test_Reader_Merge(t *testing.T) {
+	assert := assert.New(t)
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, nil)
+	if !assert.NoError(err) {
+		return
+	}
+	assert.NoError(w.Enc(map[string]int{"a": 1}))
+	assert.NoError(w.Enc(map[string]int{"b": 2}))
+	assert.NoError(w.Close())
+
+	r, err := NewReader(&buf, &Format{Merge: MergeAppend})
+	if !assert.NoError(err) {
+		return
+	}
+	var actual map[string]int
+	assert.NoError(r.Dec(&actual))
+	assert.NoError(r.Dec(&actual))
+
+	assert.Equal(map[string]int{"a": 1, "b": 2}, actual)
+}
@@ -0,0 +1,124 @@
+package rezi
+
+// names.go contains functions for encoding and decoding the sets of struct
+// field names that are shared between structs with a field-name table.
+
+import (
+	"strings"
+)
+
+// fieldNameTableVersion is the version given in the EXT byte of a struct whose
+// field names are given by an entry in a field-name table.
+const fieldNameTableVersion = 2
+
+// nameTable holds every set of struct field names that has been written or
+// read so far. Each set is given the next index when it is first written, and
+// later structs with the same set of field names refer to it by that index.
+type nameTable struct {
+	// indexes maps each set of names that has been written to its index. The
+	// key is the names of the set joined by NUL characters.
+	indexes map[string]int
+
+	// keys holds each key in indexes in the order it was added.
+	keys []string
+
+	// sets holds each set of names that has been read, at its index.
+	sets [][]string
+}
+
+func newNameTable() *nameTable {
+	return &nameTable{indexes: map[string]int{}}
+}
+
+// truncate removes every set of names that was added for encoding after the
+// first n sets.
+func (nt *nameTable) truncate(n int) {
+	for _, k := range nt.keys[n:] {
+		delete(nt.indexes, k)
+	}
+	nt.keys = nt.keys[:n]
+}
+
+// encNameTableRef encodes a reference to the given set of names in nt. If the
+// set is not yet in nt, it is added and the encoded reference also defines its
+// names.
+//
+// A reference to a set already in nt is encoded as the index of the set. A
+// newly-added set is given the next index, and is encoded as the bitwise
+// complement of that index followed by the number of names in the set and then
+// each of the names.
+func encNameTableRef(nt *nameTable, names []string) []byte {
+	key := strings.Join(names, "\x00")
+	if idx, ok := nt.indexes[key]; ok {
+		return encInt(analyzed[tLen]{v: idx})
+	}
+
+	idx := len(nt.keys)
+	nt.indexes[key] = idx
+	nt.keys = append(nt.keys, key)
+
+	enc := encInt(analyzed[tLen]{v: ^idx})
+	enc = append(enc, encInt(analyzed[tLen]{v: len(names)})...)
+	for _, n := range names {
+		enc = append(enc, encString(analyzed[string]{v: n})...)
+	}
+	return enc
+}
+
+// decNameTableRef decodes a reference to a set of names in nt. If the
+// reference defines a new set, it is added to nt, replacing any sets already at
+// or after its index.
+func decNameTableRef(data []byte, nt *nameTable) (decoded[[]string], error) {
+	var dec decoded[[]string]
+
+	if nt == nil {
+		return dec, errorDecf(0, "struct refers to a field-name table but none is available").wrap(ErrMalformedData)
+	}
+
+	idx, err := decInt[tLen](data)
+	if err != nil {
+		return dec, errorDecf(0, "decode field-name table index: %s", err)
+	}
+	dec.n += idx.n
+	data = data[idx.n:]
+
+	if idx.v >= 0 {
+		if idx.v >= len(nt.sets) {
+			return dec, errorDecf(0, "field-name table index %d is not defined", idx.v).wrap(ErrMalformedData)
+		}
+		dec.v = nt.sets[idx.v]
+		return dec, nil
+	}
+
+	// it's a new entry, read in the names. it replaces any entries at or
+	// after its index, which is the case when a table is started over.
+	defIdx := ^idx.v
+	if defIdx > len(nt.sets) {
+		return dec, errorDecf(0, "field-name table index %d is defined before index %d", defIdx, len(nt.sets)).wrap(ErrMalformedData)
+	}
+
+	count, err := decInt[tLen](data)
+	if err != nil {
+		return dec, errorDecf(dec.n, "decode field-name table entry count: %s", err)
+	}
+	if count.v < 0 {
+		return dec, errorDecf(dec.n, "field-name table entry count < 0").wrap(ErrMalformedData)
+	}
+	dec.n += count.n
+	data = data[count.n:]
+
+	var names []string
+	for i := 0; i < count.v; i++ {
+		name, err := decString(data)
+		if err != nil {
+			return dec, errorDecf(dec.n, "decode field-name table entry name[%d]: %s", i, err)
+		}
+		dec.n += name.n
+		data = data[name.n:]
+		names = append(names, name.v)
+	}
+
+	nt.sets = append(nt.sets[:defIdx], names)
+	dec.v = names
+	return dec, nil
+}
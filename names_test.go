@@ -0,0 +1,311 @@
+package rezi
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testNameTableItem struct {
+	A int
+	B string
+}
+
+type testNameTableOuter struct {
+	Items []testNameTableItem
+	Name  string
+}
+
+func Test_EncWithFormat_FieldNameTable(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  interface{}
+		expect []byte
+	}{
+		{
+			name:  "empty struct",
+			input: struct{}{},
+			expect: []byte{
+				0x00,
+			},
+		},
+		{
+			name:  "single struct",
+			input: testNameTableItem{A: 1, B: "x"},
+			expect: []byte{
+				0x41, 0x02, 0x11, // len=17, field-name table
+				0x80,       // define entry 0
+				0x01, 0x02, // 2 names
+				0x41, 0x82, 0x01, 0x41, // "A"
+				0x41, 0x82, 0x01, 0x42, // "B"
+				0x01, 0x01, // 1
+				0x41, 0x82, 0x01, 0x78, // "x"
+			},
+		},
+		{
+			name:  "slice of structs",
+			input: []testNameTableItem{{A: 1, B: "x"}, {A: 2, B: "y"}},
+			expect: []byte{
+				0x01, 0x1e, // len=30
+
+				0x41, 0x02, 0x11, // len=17, field-name table
+				0x80,       // define entry 0
+				0x01, 0x02, // 2 names
+				0x41, 0x82, 0x01, 0x41, // "A"
+				0x41, 0x82, 0x01, 0x42, // "B"
+				0x01, 0x01, // 1
+				0x41, 0x82, 0x01, 0x78, // "x"
+
+				0x41, 0x02, 0x07, // len=7, field-name table
+				0x00,       // entry 0
+				0x01, 0x02, // 2
+				0x41, 0x82, 0x01, 0x79, // "y"
+			},
+		},
+		{
+			name: "nested structs",
+			input: testNameTableOuter{
+				Items: []testNameTableItem{{A: 1, B: "x"}},
+				Name:  "z",
+			},
+			expect: []byte{
+				0x41, 0x02, 0x2d, // len=45, field-name table
+				0x80,       // define entry 0
+				0x01, 0x02, // 2 names
+				0x41, 0x82, 0x05, 0x49, 0x74, 0x65, 0x6d, 0x73, // "Items"
+				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
+
+				0x01, 0x15, // len=21
+				0x41, 0x02, 0x12, // len=18, field-name table
+				0x81, 0xfe, // define entry 1
+				0x01, 0x02, // 2 names
+				0x41, 0x82, 0x01, 0x41, // "A"
+				0x41, 0x82, 0x01, 0x42, // "B"
+				0x01, 0x01, // 1
+				0x41, 0x82, 0x01, 0x78, // "x"
+
+				0x41, 0x82, 0x01, 0x7a, // "z"
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := EncWithFormat(tc.input, &Format{FieldNameTable: true})
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_Dec_FieldNameTable(t *testing.T) {
+	t.Run("slice of structs", func(t *testing.T) {
+		assert := assert.New(t)
+		input := []testNameTableItem{{A: 1, B: "x"}, {A: 2, B: "y"}, {A: 3, B: "z"}}
+
+		data, err := EncWithFormat(input, &Format{FieldNameTable: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual []testNameTableItem
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(input, actual)
+	})
+
+	t.Run("map of structs", func(t *testing.T) {
+		assert := assert.New(t)
+		input := map[string]testNameTableItem{
+			"j": {A: 2, B: "y"},
+			"k": {A: 1, B: "x"},
+		}
+
+		data, err := EncWithFormat(input, &Format{FieldNameTable: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual map[string]testNameTableItem
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(input, actual)
+	})
+
+	t.Run("map with struct keys", func(t *testing.T) {
+		assert := assert.New(t)
+		input := map[testNameTableItem]int{
+			{A: 2, B: "y"}: 2,
+			{A: 1, B: "x"}: 1,
+		}
+
+		data, err := EncWithFormat(input, &Format{FieldNameTable: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual map[testNameTableItem]int
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(input, actual)
+	})
+
+	t.Run("nested structs", func(t *testing.T) {
+		assert := assert.New(t)
+		input := testNameTableOuter{
+			Items: []testNameTableItem{{A: 1, B: "x"}, {A: 2, B: "y"}},
+			Name:  "z",
+		}
+
+		data, err := EncWithFormat(input, &Format{FieldNameTable: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testNameTableOuter
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(input, actual)
+	})
+
+	t.Run("undefined entry", func(t *testing.T) {
+		assert := assert.New(t)
+		data := []byte{
+			0x41, 0x02, 0x07, // len=7, field-name table
+			0x00,       // entry 0
+			0x01, 0x02, // 2
+			0x41, 0x82, 0x01, 0x79, // "y"
+		}
+
+		var actual testNameTableItem
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("unknown field name", func(t *testing.T) {
+		assert := assert.New(t)
+		data := []byte{
+			0x41, 0x02, 0x0b, // len=11, field-name table
+			0x80,       // define entry 0
+			0x01, 0x01, // 1 name
+			0x41, 0x82, 0x01, 0x43, // "C"
+			0x41, 0x82, 0x01, 0x78, // "x"
+		}
+
+		var actual testNameTableItem
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+}
+
+func Test_WriterReader_FieldNameTable(t *testing.T) {
+	t.Run("entries are kept across Enc calls", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := []byte{
+			0x41, 0x02, 0x11, // len=17, field-name table
+			0x80,       // define entry 0
+			0x01, 0x02, // 2 names
+			0x41, 0x82, 0x01, 0x41, // "A"
+			0x41, 0x82, 0x01, 0x42, // "B"
+			0x01, 0x01, // 1
+			0x41, 0x82, 0x01, 0x78, // "x"
+
+			0x41, 0x02, 0x07, // len=7, field-name table
+			0x00,       // entry 0
+			0x01, 0x02, // 2
+			0x41, 0x82, 0x01, 0x79, // "y"
+		}
+
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, &Format{FieldNameTable: true})
+		if !assert.NoError(err) {
+			return
+		}
+		assert.NoError(w.Enc(testNameTableItem{A: 1, B: "x"}))
+		assert.NoError(w.Enc(testNameTableItem{A: 2, B: "y"}))
+		assert.NoError(w.Close())
+		assert.Equal(expect, buf.Bytes())
+
+		r, err := NewReader(&buf, nil)
+		if !assert.NoError(err) {
+			return
+		}
+		var first, second testNameTableItem
+		assert.NoError(r.Dec(&first))
+		assert.NoError(r.Dec(&second))
+		assert.Equal(testNameTableItem{A: 1, B: "x"}, first)
+		assert.Equal(testNameTableItem{A: 2, B: "y"}, second)
+	})
+
+	t.Run("entries are not kept after failed Enc", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, &Format{FieldNameTable: true})
+		if !assert.NoError(err) {
+			return
+		}
+		badInput := struct {
+			A testNameTableItem
+			B testBinary
+		}{B: testBinary{encErr: errors.New("fake marshal error")}}
+		assert.Error(w.Enc(badInput))
+		assert.NoError(w.Enc(testNameTableItem{A: 1, B: "x"}))
+		assert.NoError(w.Close())
+
+		var actual testNameTableItem
+		_, err = Dec(buf.Bytes(), &actual)
+		assert.NoError(err)
+		assert.Equal(testNameTableItem{A: 1, B: "x"}, actual)
+	})
+
+	t.Run("separately-encoded values in one stream", func(t *testing.T) {
+		assert := assert.New(t)
+		f := &Format{FieldNameTable: true}
+
+		first, err := EncWithFormat(testNameTableOuter{Name: "a"}, f)
+		if !assert.NoError(err) {
+			return
+		}
+		second, err := EncWithFormat(testNameTableItem{A: 1, B: "x"}, f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		r, err := NewReader(bytes.NewReader(append(first, second...)), nil)
+		if !assert.NoError(err) {
+			return
+		}
+		var outer testNameTableOuter
+		var item testNameTableItem
+		assert.NoError(r.Dec(&outer))
+		assert.NoError(r.Dec(&item))
+		assert.Equal(testNameTableOuter{Name: "a"}, outer)
+		assert.Equal(testNameTableItem{A: 1, B: "x"}, item)
+	})
+}
@@ -0,0 +1,257 @@
+package rezi
+
+// numbers.go contains functions for decoding integers and floats to receivers
+// of a different numeric kind than the one they were encoded from.
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// numKind is the kind of number that an integer or float was encoded from. A
+// number that has its kind is encoded with an EXT byte whose version is the
+// numKind.
+type numKind int
+
+const (
+	// numKindNone is used for numbers whose kind is not given.
+	numKindNone numKind = iota
+	numKindInt
+	numKindUint
+	numKindFloat
+)
+
+// String returns the name of the numKind as it would be given in the was
+// option of a rezi tag.
+func (k numKind) String() string {
+	switch k {
+	case numKindInt:
+		return "int"
+	case numKindUint:
+		return "uint"
+	case numKindFloat:
+		return "float"
+	default:
+		return fmt.Sprintf("numKind(%d)", int(k))
+	}
+}
+
+// numKindOf returns the numKind of values with the given type info, or
+// numKindNone if they are not integers or floats.
+func numKindOf(ti typeInfo) numKind {
+	switch ti.Main {
+	case mtIntegral:
+		if ti.Signed {
+			return numKindInt
+		}
+		return numKindUint
+	case mtFloat:
+		return numKindFloat
+	default:
+		return numKindNone
+	}
+}
+
+// convertingNumbers returns whether every integer and float is encoded with
+// its numKind.
+func (s *session) convertingNumbers() bool {
+	return s != nil && s.f.ConvertNumbers
+}
+
+// markedNumKind returns the numKind that the given encoded number must be
+// marked with, or numKindNone if it is not to be marked. Numbers are marked
+// when the session is converting numbers or when the kind they would be read as
+// without a mark, given by the was option of the rezi tag of their field, is
+// not their actual kind.
+func markedNumKind(enc []byte, ti typeInfo, sess *session) numKind {
+	kind := numKindOf(ti)
+	if kind == numKindNone || len(enc) < 1 || enc[0]&(infoBitsNil|infoBitsExt) != 0 {
+		return numKindNone
+	}
+	if sess.convertingNumbers() || (ti.Was != numKindNone && ti.Was != kind) {
+		return kind
+	}
+	return numKindNone
+}
+
+// markNumKind adds an EXT byte giving kind to the encoded number enc.
+func markNumKind(enc []byte, kind numKind) []byte {
+	marked := []byte{enc[0] | infoBitsExt, byte(kind)}
+	return append(marked, enc[1:]...)
+}
+
+// storedNumKind returns the numKind of the encoded number at the start of
+// data, which is to be decoded to a receiver with the given type info. A number
+// that is not marked with its kind is taken to be of the kind given by the was
+// option of the rezi tag of its field, or of the receiver's kind if there is
+// none.
+func storedNumKind(data []byte, ti typeInfo) numKind {
+	if len(data) > 0 && data[0]&infoBitsNil == 0 && data[0]&infoBitsExt != 0 {
+		hdr, err := decCountHeader(data)
+		if err == nil && hdr.v.Version > int(numKindNone) && hdr.v.Version <= int(numKindFloat) {
+			return numKind(hdr.v.Version)
+		}
+	}
+	if ti.Was != numKindNone {
+		return ti.Was
+	}
+	return numKindOf(ti)
+}
+
+// decConvertedNum decodes an encoded number of the stored kind to a receiver
+// of a different kind. The number must be exactly representable by the
+// receiver.
+func decConvertedNum(data []byte, recv analyzed[any], stored numKind) (decoded[any], error) {
+	target := recv.reflect.Type().Elem()
+	for i := 0; i < recv.info.Indir; i++ {
+		target = target.Elem()
+	}
+
+	dec, err := decWithNilCheck(data, recv, func(data []byte) (decoded[any], error) {
+		return decNumAs(data, stored, target)
+	})
+	if err != nil {
+		return dec, err
+	}
+	if recv.info.Indir == 0 {
+		recv.reflect.Elem().Set(dec.reflect)
+	}
+	return dec, nil
+}
+
+// decNumAs decodes an encoded number of the stored kind as a value of type t.
+func decNumAs(data []byte, stored numKind, t reflect.Type) (decoded[any], error) {
+	var dec decoded[any]
+	v := reflect.New(t).Elem()
+
+	var ok bool
+	var orig interface{}
+	switch stored {
+	case numKindFloat:
+		f, err := decFloat[float64](data)
+		if err != nil {
+			return dec, err
+		}
+		dec.n = f.n
+		orig = f.v
+		ok = setFloatAs(v, f.v)
+	case numKindUint:
+		u, err := decInt[uint64](data)
+		if err != nil {
+			return dec, err
+		}
+		dec.n = u.n
+		orig = u.v
+		ok = setUintAs(v, u.v)
+	default:
+		i, err := decInt[int64](data)
+		if err != nil {
+			return dec, err
+		}
+		dec.n = i.n
+		orig = i.v
+		ok = setIntAs(v, i.v)
+	}
+
+	if !ok {
+		return decoded[any]{}, errorDecf(0, "decoded %s %v cannot be represented exactly by %s", stored, orig, t).wrap(ErrRange)
+	}
+
+	dec.v = v.Interface()
+	dec.reflect = v
+	return dec, nil
+}
+
+// setIntAs sets the integer or float v to i and returns whether i could be
+// represented exactly.
+func setIntAs(v reflect.Value, i int64) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(i) {
+			return false
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return false
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f := float64(i)
+		if f >= math.MaxInt64 || int64(f) != i || !floatFits(v, f) {
+			return false
+		}
+		v.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
+
+// setUintAs sets the integer or float v to u and returns whether u could be
+// represented exactly.
+func setUintAs(v reflect.Value, u uint64) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if u > math.MaxInt64 || v.OverflowInt(int64(u)) {
+			return false
+		}
+		v.SetInt(int64(u))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.OverflowUint(u) {
+			return false
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f := float64(u)
+		if f >= math.MaxUint64 || uint64(f) != u || !floatFits(v, f) {
+			return false
+		}
+		v.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
+
+// setFloatAs sets the integer or float v to f and returns whether f could be
+// represented exactly.
+func setFloatAs(v reflect.Value, f float64) bool {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if !floatFits(v, f) {
+			return false
+		}
+		v.SetFloat(f)
+		return true
+	}
+
+	if math.IsNaN(f) || math.IsInf(f, 0) || f != math.Trunc(f) {
+		return false
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if f < math.MinInt64 || f >= math.MaxInt64 || v.OverflowInt(int64(f)) {
+			return false
+		}
+		v.SetInt(int64(f))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if f < 0 || f >= math.MaxUint64 || v.OverflowUint(uint64(f)) {
+			return false
+		}
+		v.SetUint(uint64(f))
+	default:
+		return false
+	}
+	return true
+}
+
+// floatFits returns whether the float v can hold f exactly.
+func floatFits(v reflect.Value, f float64) bool {
+	if v.Kind() == reflect.Float32 {
+		return math.IsNaN(f) || float64(float32(f)) == f
+	}
+	return true
+}
@@ -0,0 +1,303 @@
+package rezi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testNumbersWasInt struct {
+	Value float64 `rezi:",was=int"`
+}
+
+type testNumbersOldInt struct {
+	Value int32
+}
+
+func Test_Dec_Range(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     []byte
+		recv      interface{}
+		expectErr bool
+	}{
+		{name: "300 to int8", input: MustEnc(300), recv: new(int8), expectErr: true},
+		{name: "127 to int8", input: MustEnc(127), recv: new(int8)},
+		{name: "-129 to int8", input: MustEnc(-129), recv: new(int8), expectErr: true},
+		{name: "-1 to uint32", input: MustEnc(-1), recv: new(uint32), expectErr: true},
+		{name: "max uint32 to uint32", input: MustEnc(uint32(0xffffffff)), recv: new(uint32)},
+		{name: "max uint32 to int32", input: MustEnc(uint32(0xffffffff)), recv: new(int32), expectErr: true},
+		{name: "max uint64 to uint64", input: MustEnc(uint64(0xffffffffffffffff)), recv: new(uint64)},
+		{name: "-1 to uint64", input: MustEnc(-1), recv: new(uint64), expectErr: true},
+		{name: "-1 to uint", input: MustEnc(-1), recv: new(uint), expectErr: true},
+		{name: "min int64 to uint64", input: MustEnc(int64(-1 << 63)), recv: new(uint64), expectErr: true},
+		{name: "above max int64 to int64", input: MustEnc(uint64(1<<63 + 5)), recv: new(int64), expectErr: true},
+		{name: "above max int64 to int", input: MustEnc(uint64(1<<63 + 5)), recv: new(int), expectErr: true},
+		{name: "max int64 to int64", input: MustEnc(uint64(1<<63 - 1)), recv: new(int64)},
+		{name: "min int64 to int64", input: MustEnc(int64(-1 << 63)), recv: new(int64)},
+		{name: "256 to uint8", input: MustEnc(256), recv: new(uint8), expectErr: true},
+		{name: "large float to float32", input: MustEnc(1e300), recv: new(float32), expectErr: true},
+		{name: "small float to float32", input: MustEnc(1.5), recv: new(float32)},
+		{name: "pointer receiver", input: MustEnc(300), recv: new(*int8), expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := Dec(tc.input, tc.recv)
+
+			if tc.expectErr {
+				assert.ErrorIs(err, ErrRange)
+			} else {
+				assert.NoError(err)
+			}
+		})
+	}
+}
+
+func Test_EncWithFormat_ConvertNumbers(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  interface{}
+		expect []byte
+	}{
+		{
+			name:   "int",
+			input:  12,
+			expect: []byte{0x41, 0x01, 0x0c},
+		},
+		{
+			name:   "negative int",
+			input:  -1,
+			expect: []byte{0xc0, 0x01},
+		},
+		{
+			name:   "zero",
+			input:  0,
+			expect: []byte{0x40, 0x01},
+		},
+		{
+			name:   "uint",
+			input:  uint16(12),
+			expect: []byte{0x41, 0x02, 0x0c},
+		},
+		{
+			name:   "float",
+			input:  8.25,
+			expect: []byte{0x43, 0x03, 0xc0, 0x20, 0x80},
+		},
+		{
+			name:   "nil pointer",
+			input:  (*int)(nil),
+			expect: []byte{0xa0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := EncWithFormat(tc.input, &Format{ConvertNumbers: true})
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_Dec_ConvertNumbers(t *testing.T) {
+	convert := &Format{ConvertNumbers: true}
+
+	testCases := []struct {
+		name      string
+		input     interface{}
+		recv      interface{}
+		expect    interface{}
+		expectErr bool
+	}{
+		{name: "int to float64", input: 12, recv: new(float64), expect: 12.0},
+		{name: "int to uint8", input: 12, recv: new(uint8), expect: uint8(12)},
+		{name: "negative int to uint8", input: -12, recv: new(uint8), expectErr: true},
+		{name: "uint to int64", input: uint64(12), recv: new(int64), expect: int64(12)},
+		{name: "max uint64 to int64", input: uint64(0xffffffffffffffff), recv: new(int64), expectErr: true},
+		{name: "max uint64 to float64", input: uint64(0xffffffffffffffff), recv: new(float64), expectErr: true},
+		{name: "float to int", input: 8.0, recv: new(int), expect: 8},
+		{name: "fractional float to int", input: 8.25, recv: new(int), expectErr: true},
+		{name: "int to float32 exactly", input: 1 << 24, recv: new(float32), expect: float32(1 << 24)},
+		{name: "int to float32 inexactly", input: 1<<24 + 1, recv: new(float32), expectErr: true},
+		{name: "int to pointer to float", input: 3, recv: new(*float64), expect: ref(3.0)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			data, err := EncWithFormat(tc.input, convert)
+			if !assert.NoError(err) {
+				return
+			}
+
+			n, err := Dec(data, tc.recv)
+
+			if tc.expectErr {
+				assert.ErrorIs(err, ErrRange)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+			assert.Equal(len(data), n)
+			assert.Equal(tc.expect, reflect.ValueOf(tc.recv).Elem().Interface())
+		})
+	}
+
+	t.Run("marked number to same kind without format", func(t *testing.T) {
+		assert := assert.New(t)
+		data, err := EncWithFormat(int32(-300), convert)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual int64
+		_, err = Dec(data, &actual)
+		assert.NoError(err)
+		assert.Equal(int64(-300), actual)
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		assert := assert.New(t)
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, convert)
+		if !assert.NoError(err) {
+			return
+		}
+		assert.NoError(w.Enc(500))
+		assert.NoError(w.Close())
+
+		r, err := NewReader(&buf, nil)
+		if !assert.NoError(err) {
+			return
+		}
+		var actual float32
+		assert.NoError(r.Dec(&actual))
+		assert.Equal(float32(500), actual)
+	})
+}
+
+func Test_Dec_WasOption(t *testing.T) {
+	t.Run("unmarked old data", func(t *testing.T) {
+		assert := assert.New(t)
+		data := MustEnc(testNumbersOldInt{Value: 7})
+
+		var actual testNumbersWasInt
+		_, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(testNumbersWasInt{Value: 7}, actual)
+	})
+
+	t.Run("new data is marked", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testNumbersWasInt{Value: 7.5}
+		data := MustEnc(expect)
+
+		var actual testNumbersWasInt
+		_, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("not a number", func(t *testing.T) {
+		assert := assert.New(t)
+		input := struct {
+			Value string `rezi:",was=int"`
+		}{}
+
+		_, err := Enc(input)
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+}
+
+func Test_Dec_Range_Packed(t *testing.T) {
+	t.Run("signed overflow", func(t *testing.T) {
+		assert := assert.New(t)
+		data, err := EncWithFormat([]int{1, 300}, &Format{Packing: PackVarint})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual []int8
+		_, err = Dec(data, &actual)
+		assert.ErrorIs(err, ErrRange)
+	})
+
+	t.Run("negative to unsigned", func(t *testing.T) {
+		assert := assert.New(t)
+		data, err := EncWithFormat([]int{1, -1}, &Format{Packing: PackFixed})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual []uint64
+		_, err = Dec(data, &actual)
+		assert.ErrorIs(err, ErrRange)
+	})
+
+	t.Run("negative to 64-bit unsigned", func(t *testing.T) {
+		assert := assert.New(t)
+		data, err := EncWithFormat([]int64{-1}, &Format{Packing: PackVarint})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual []uint64
+		_, err = Dec(data, &actual)
+		assert.ErrorIs(err, ErrRange)
+	})
+
+	t.Run("above max int64 to int64", func(t *testing.T) {
+		assert := assert.New(t)
+		data, err := EncWithFormat([]uint64{1, 1<<63 + 5}, &Format{Packing: PackFixed})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual []int64
+		_, err = Dec(data, &actual)
+		assert.ErrorIs(err, ErrRange)
+	})
+
+	t.Run("named item type", func(t *testing.T) {
+		assert := assert.New(t)
+		data, err := EncWithFormat([]int{1, -1}, &Format{Packing: PackDelta})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual []testNamedByte
+		_, err = Dec(data, &actual)
+		assert.ErrorIs(err, ErrRange)
+	})
+
+	t.Run("in range", func(t *testing.T) {
+		assert := assert.New(t)
+		data, err := EncWithFormat([]int{1, 127, -128}, &Format{Packing: PackDelta})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual []int8
+		_, err = Dec(data, &actual)
+		assert.NoError(err)
+		assert.Equal([]int8{1, 127, -128}, actual)
+	})
+}
@@ -0,0 +1,517 @@
+package rezi
+
+// packed.go contains functions for encoding and decoding slices and arrays of
+// numbers in packed form.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// Packing is a way of encoding a slice or array of integers or floats as a
+// single packed run of data instead of as a sequence of individually-encoded
+// items. Packing can be selected for all such slices and arrays with
+// [Format.Packing] or for a single struct field with a rezi tag that gives the
+// packed option, such as `rezi:",packed=delta"`.
+//
+// Decoding does not require knowing what packing was used; any packed data can
+// be decoded into a slice or array of integers or floats regardless of the
+// Packing it was encoded with.
+type Packing int
+
+const (
+	// PackNone encodes each item of a slice or array separately. It is the
+	// default.
+	PackNone Packing = iota
+
+	// PackFixed encodes each item with the full width of its type. Values of
+	// type int and uint are given 8 bytes each.
+	PackFixed
+
+	// PackVarint encodes each integer with as few bytes as it needs, using
+	// zig-zag encoding for signed integers so that small negative numbers are
+	// also short. Floats are encoded as they are for PackFixed.
+	PackVarint
+
+	// PackDelta encodes the first integer as with PackVarint, and each integer
+	// after it as the difference between it and the one before. This is very
+	// compact for sorted sequences of integers, such as timestamps or IDs.
+	// Floats are encoded as they are for PackFixed.
+	PackDelta
+)
+
+// String returns the name of the Packing as it would be given in a rezi tag.
+func (p Packing) String() string {
+	switch p {
+	case PackNone:
+		return "none"
+	case PackFixed:
+		return "fixed"
+	case PackVarint:
+		return "varint"
+	case PackDelta:
+		return "delta"
+	default:
+		return fmt.Sprintf("Packing(%d)", int(p))
+	}
+}
+
+// packedVersion is the version given in the EXT byte of a slice or array that
+// is encoded in packed form.
+const packedVersion = 3
+
+// bits of the packing byte that begins the contents of packed data. It has
+// the layout WWKKPPPP, where P is the Packing, K is the kind of number, and W
+// is the base-2 log of the byte width of each number if the Packing is
+// PackFixed.
+const (
+	packBitsPacking = 0b00001111
+	packBitsKind    = 0b00110000
+	packBitsWidth   = 0b11000000
+)
+
+// kinds of number in packed data, as given in the K bits of the packing byte.
+const (
+	packKindSigned   = 0
+	packKindUnsigned = 1
+	packKindFloat    = 2
+)
+
+// Packable returns whether ti describes a slice or array of integers or
+// floats, which can be encoded in packed form.
+func (ti typeInfo) Packable() bool {
+	if ti.Main != mtSlice && ti.Main != mtArray {
+		return false
+	}
+	vt := ti.ValType
+	return (vt.Main == mtIntegral || vt.Main == mtFloat) && vt.Indir == 0
+}
+
+// packKind returns the kind of number given in the packing byte for items of
+// the type described by vt.
+func packKind(vt typeInfo) byte {
+	if vt.Main == mtFloat {
+		return packKindFloat
+	} else if vt.Signed {
+		return packKindSigned
+	}
+	return packKindUnsigned
+}
+
+// encPacked encodes the slice or array of numbers in v in packed form with the
+// given packing. ti must describe v and must be Packable.
+func encPacked(v reflect.Value, ti typeInfo, packing Packing) []byte {
+	if v.Len() == 0 {
+		return []byte{0x00}
+	}
+
+	kind := packKind(*ti.ValType)
+	if kind == packKindFloat {
+		packing = PackFixed
+	}
+
+	width := ti.ValType.Bits / 8
+	if width == 0 {
+		width = 8
+	}
+
+	var logWidth byte
+	for 1<<logWidth < width {
+		logWidth++
+	}
+
+	packByte := byte(packing) | (kind << 4) | (logWidth << 6)
+	contents := []byte{packByte}
+
+	var ints []uint64
+	if kind == packKindFloat {
+		floats := packedFloats(v)
+		ints = make([]uint64, len(floats))
+		for i, f := range floats {
+			if width == 4 {
+				ints[i] = uint64(math.Float32bits(float32(f)))
+			} else {
+				ints[i] = math.Float64bits(f)
+			}
+		}
+	} else {
+		ints = packedInts(v)
+	}
+
+	var varintBuf [binary.MaxVarintLen64]byte
+
+	switch packing {
+	case PackFixed:
+		for _, i := range ints {
+			for b := width - 1; b >= 0; b-- {
+				contents = append(contents, byte(i>>(b*8)))
+			}
+		}
+	case PackVarint:
+		for _, i := range ints {
+			var n int
+			if kind == packKindSigned {
+				n = binary.PutVarint(varintBuf[:], int64(i))
+			} else {
+				n = binary.PutUvarint(varintBuf[:], i)
+			}
+			contents = append(contents, varintBuf[:n]...)
+		}
+	case PackDelta:
+		var prev uint64
+		for _, i := range ints {
+			n := binary.PutVarint(varintBuf[:], int64(i-prev))
+			contents = append(contents, varintBuf[:n]...)
+			prev = i
+		}
+	default:
+		panic(fmt.Sprintf("unknown packing: %v", packing))
+	}
+
+	enc := encCount(len(contents), &countHeader{Version: packedVersion})
+	return append(enc, contents...)
+}
+
+// decPacked decodes the contents of packed data into a new value of type t,
+// which must be a slice or array of the numbers described by valType. If t is
+// an array, policy gives how a different number of items is decoded to it.
+func decPacked(data []byte, t reflect.Type, valType typeInfo, policy ArrayLengthPolicy) (reflect.Value, error) {
+	if len(data) < 1 {
+		return reflect.Value{}, errorDecf(0, "packed data has no packing byte").wrap(ErrMalformedData)
+	}
+	packByte := data[0]
+	data = data[1:]
+
+	packing := Packing(packByte & packBitsPacking)
+	kind := (packByte & packBitsKind) >> 4
+	width := 1 << ((packByte & packBitsWidth) >> 6)
+
+	if (kind == packKindFloat) != (valType.Main == mtFloat) {
+		err := errorDecf(0, "packed %s data cannot be decoded to a sequence of %s", packKindName(kind), t.Elem())
+		return reflect.Value{}, err.wrap(ErrMalformedData, ErrInvalidType)
+	}
+
+	var ints []uint64
+	var floats []float64
+	var count int
+
+	switch packing {
+	case PackFixed:
+		if kind == packKindFloat && width != 4 && width != 8 {
+			return reflect.Value{}, errorDecf(0, "packed float width of %d is not valid", width).wrap(ErrMalformedData)
+		}
+		if len(data)%width != 0 {
+			const errFmt = "packed data length of %d is not a multiple of item width %d"
+			return reflect.Value{}, errorDecf(1, errFmt, len(data), width).wrap(ErrMalformedData)
+		}
+		count = len(data) / width
+		if kind == packKindFloat {
+			floats = make([]float64, count)
+			for i := range floats {
+				if width == 4 {
+					floats[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(data[i*4:])))
+				} else {
+					floats[i] = math.Float64frombits(binary.BigEndian.Uint64(data[i*8:]))
+				}
+			}
+		} else {
+			ints = make([]uint64, count)
+			for i := range ints {
+				var u uint64
+				for _, b := range data[i*width : (i+1)*width] {
+					u = (u << 8) | uint64(b)
+				}
+				if kind == packKindSigned && width < 8 {
+					// sign-extend
+					shift := 64 - width*8
+					u = uint64(int64(u<<shift) >> shift)
+				}
+				ints[i] = u
+			}
+		}
+	case PackVarint, PackDelta:
+		if kind == packKindFloat {
+			return reflect.Value{}, errorDecf(0, "packed float data must have fixed packing").wrap(ErrMalformedData)
+		}
+
+		// each varint ends with the only one of its bytes that has the high
+		// bit clear.
+		for _, b := range data {
+			if b&0x80 == 0 {
+				count++
+			}
+		}
+
+		ints = make([]uint64, count)
+		var prev uint64
+		offset := 1
+		for i := range ints {
+			var u uint64
+			var n int
+			if packing == PackVarint && kind == packKindUnsigned {
+				u, n = binary.Uvarint(data)
+			} else {
+				var s int64
+				s, n = binary.Varint(data)
+				u = uint64(s)
+			}
+			if n <= 0 {
+				return reflect.Value{}, errorDecf(offset, "packed item[%d] is not a valid varint", i).wrap(ErrMalformedData)
+			}
+			if packing == PackDelta {
+				u += prev
+				prev = u
+			}
+			ints[i] = u
+			data = data[n:]
+			offset += n
+		}
+		if len(data) > 0 {
+			return reflect.Value{}, errorDecf(offset, "packed data ends in the middle of an item").wrap(io.ErrUnexpectedEOF, ErrMalformedData)
+		}
+	default:
+		return reflect.Value{}, errorDecf(0, "unknown packing %d", int(packing)).wrap(ErrMalformedData)
+	}
+
+	// make sure every float fits in the item type. integers are checked as
+	// they are set.
+	if kind == packKindFloat {
+		item := reflect.New(t.Elem()).Elem()
+		for i := range floats {
+			if !math.IsInf(floats[i], 0) && item.OverflowFloat(floats[i]) {
+				return reflect.Value{}, errorDecf(0, "packed item[%d] overflows %s", i, t.Elem()).wrap(ErrRange)
+			}
+		}
+	}
+
+	var v reflect.Value
+	if t.Kind() == reflect.Array {
+		keep, err := arrayItemCount(count, t.Len(), policy)
+		if err != nil {
+			return reflect.Value{}, errorDecf(0, "%s", err)
+		}
+		if kind == packKindFloat {
+			floats = floats[:keep]
+		} else {
+			ints = ints[:keep]
+		}
+		v = reflect.New(t).Elem()
+	} else {
+		v = reflect.MakeSlice(t, count, count)
+	}
+
+	if kind == packKindFloat {
+		setPackedFloats(v, floats)
+	} else if i := setPackedInts(v, ints, kind == packKindSigned); i >= 0 {
+		return reflect.Value{}, errorDecf(0, "packed item[%d] overflows %s", i, t.Elem()).wrap(ErrRange)
+	}
+
+	return v, nil
+}
+
+func packKindName(kind byte) string {
+	switch kind {
+	case packKindSigned:
+		return "signed integer"
+	case packKindUnsigned:
+		return "unsigned integer"
+	case packKindFloat:
+		return "float"
+	default:
+		return fmt.Sprintf("kind(%d)", kind)
+	}
+}
+
+// builtinSliceView returns a value of the slice type whose elements are the
+// built-in type underlying the elements of v, which must be a slice or array,
+// that shares its data with v. If the elements of v are not themselves of a
+// built-in type, the returned bool will be false.
+//
+// If v is an array that is not addressable, the returned slice is of a copy of
+// it.
+func builtinSliceView(v reflect.Value) (reflect.Value, bool) {
+	elemType := v.Type().Elem()
+	if refPrimitiveKindTypes[elemType.Kind()] != elemType {
+		return reflect.Value{}, false
+	}
+
+	if v.Kind() == reflect.Array {
+		if !v.CanAddr() {
+			arr := reflect.New(v.Type()).Elem()
+			arr.Set(v)
+			v = arr
+		}
+		v = v.Slice(0, v.Len())
+	}
+
+	return v.Convert(reflect.SliceOf(elemType)), true
+}
+
+// packedInts returns the two's complement bits of each integer in the slice or
+// array v.
+func packedInts(v reflect.Value) []uint64 {
+	if view, ok := builtinSliceView(v); ok {
+		switch s := view.Interface().(type) {
+		case []int:
+			return intsToBits(s)
+		case []int8:
+			return intsToBits(s)
+		case []int16:
+			return intsToBits(s)
+		case []int32:
+			return intsToBits(s)
+		case []int64:
+			return intsToBits(s)
+		case []uint:
+			return intsToBits(s)
+		case []uint8:
+			return intsToBits(s)
+		case []uint16:
+			return intsToBits(s)
+		case []uint32:
+			return intsToBits(s)
+		case []uint64:
+			return intsToBits(s)
+		}
+	}
+
+	// items are of a named integer type; fall back to reflection.
+	bits := make([]uint64, v.Len())
+	signed := v.Type().Elem().Kind() <= reflect.Int64
+	for i := range bits {
+		if signed {
+			bits[i] = uint64(v.Index(i).Int())
+		} else {
+			bits[i] = v.Index(i).Uint()
+		}
+	}
+	return bits
+}
+
+// packedFloats returns each float in the slice or array v as a float64.
+func packedFloats(v reflect.Value) []float64 {
+	if view, ok := builtinSliceView(v); ok {
+		switch s := view.Interface().(type) {
+		case []float32:
+			return floatsToFloat64s(s)
+		case []float64:
+			return floatsToFloat64s(s)
+		}
+	}
+
+	// items are of a named float type; fall back to reflection.
+	floats := make([]float64, v.Len())
+	for i := range floats {
+		floats[i] = v.Index(i).Float()
+	}
+	return floats
+}
+
+// setPackedInts sets the first len(bits) items of the slice or array of
+// integers v to the integers whose two's complement bits are given, which are
+// of signed integers if signed is set and of unsigned integers otherwise. It
+// returns the index of the first integer that does not fit in the item type of
+// v, or -1 if they all fit.
+func setPackedInts(v reflect.Value, bits []uint64, signed bool) int {
+	if view, ok := builtinSliceView(v); ok {
+		switch s := view.Interface().(type) {
+		case []int:
+			return bitsToInts(bits, signed, s)
+		case []int8:
+			return bitsToInts(bits, signed, s)
+		case []int16:
+			return bitsToInts(bits, signed, s)
+		case []int32:
+			return bitsToInts(bits, signed, s)
+		case []int64:
+			return bitsToInts(bits, signed, s)
+		case []uint:
+			return bitsToInts(bits, signed, s)
+		case []uint8:
+			return bitsToInts(bits, signed, s)
+		case []uint16:
+			return bitsToInts(bits, signed, s)
+		case []uint32:
+			return bitsToInts(bits, signed, s)
+		case []uint64:
+			return bitsToInts(bits, signed, s)
+		}
+	}
+
+	// items are of a named integer type; fall back to reflection.
+	for i := range bits {
+		var fits bool
+		if signed {
+			fits = setIntAs(v.Index(i), int64(bits[i]))
+		} else {
+			fits = setUintAs(v.Index(i), bits[i])
+		}
+		if !fits {
+			return i
+		}
+	}
+	return -1
+}
+
+// setPackedFloats sets the first len(floats) items of the slice or array of
+// floats v to the given floats.
+func setPackedFloats(v reflect.Value, floats []float64) {
+	if view, ok := builtinSliceView(v); ok {
+		switch s := view.Interface().(type) {
+		case []float32:
+			float64sToFloats(floats, s)
+		case []float64:
+			float64sToFloats(floats, s)
+		}
+		return
+	}
+
+	// items are of a named float type; fall back to reflection.
+	for i := range floats {
+		v.Index(i).SetFloat(floats[i])
+	}
+}
+
+func intsToBits[E integral](s []E) []uint64 {
+	bits := make([]uint64, len(s))
+	for i := range s {
+		bits[i] = uint64(s[i])
+	}
+	return bits
+}
+
+func bitsToInts[E integral](bits []uint64, signed bool, s []E) int {
+	var zero E
+	signedItems := zero-1 < 0
+	for i := range bits {
+		e := E(bits[i])
+		var fits bool
+		if signed {
+			fits = int64(e) == int64(bits[i]) && (signedItems || int64(bits[i]) >= 0)
+		} else {
+			fits = uint64(e) == bits[i] && (!signedItems || e >= 0)
+		}
+		if !fits {
+			return i
+		}
+		s[i] = e
+	}
+	return -1
+}
+
+func floatsToFloat64s[E anyFloat](s []E) []float64 {
+	floats := make([]float64, len(s))
+	for i := range s {
+		floats[i] = float64(s[i])
+	}
+	return floats
+}
+
+func float64sToFloats[E anyFloat](floats []float64, s []E) {
+	for i := range floats {
+		s[i] = E(floats[i])
+	}
+}
@@ -0,0 +1,334 @@
+package rezi
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testNamedInt int16
+
+type testPackedStruct struct {
+	Fixed  []int16  `rezi:",packed=fixed"`
+	Varint []int    `rezi:",packed"`
+	Delta  []uint32 `rezi:",packed=delta"`
+	Plain  []int8
+}
+
+func Test_EncWithFormat_Packed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		input   interface{}
+		packing Packing
+		expect  []byte
+	}{
+		{
+			name:    "fixed []int64",
+			input:   []int64{1, -2},
+			packing: PackFixed,
+			expect: []byte{
+				0x41, 0x03, 0x11, // len=17, packed
+				0xc1, // fixed, signed, width=8
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01,
+				0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe,
+			},
+		},
+		{
+			name:    "fixed [2]uint16",
+			input:   [2]uint16{1, 0xabcd},
+			packing: PackFixed,
+			expect: []byte{
+				0x41, 0x03, 0x05, // len=5, packed
+				0x51, // fixed, unsigned, width=2
+				0x00, 0x01,
+				0xab, 0xcd,
+			},
+		},
+		{
+			name:    "varint []int",
+			input:   []int{1, -1, 300},
+			packing: PackVarint,
+			expect: []byte{
+				0x41, 0x03, 0x05, // len=5, packed
+				0xc2,       // varint, signed
+				0x02,       // 1
+				0x01,       // -1
+				0xd8, 0x04, // 300
+			},
+		},
+		{
+			name:    "varint []uint64",
+			input:   []uint64{1, 300},
+			packing: PackVarint,
+			expect: []byte{
+				0x41, 0x03, 0x04, // len=4, packed
+				0xd2,       // varint, unsigned
+				0x01,       // 1
+				0xac, 0x02, // 300
+			},
+		},
+		{
+			name:    "delta []uint32",
+			input:   []uint32{100, 101, 105},
+			packing: PackDelta,
+			expect: []byte{
+				0x41, 0x03, 0x05, // len=5, packed
+				0x93,       // delta, unsigned
+				0xc8, 0x01, // 100
+				0x02, // +1
+				0x08, // +4
+			},
+		},
+		{
+			name:    "delta []int decreasing",
+			input:   []int{10, 7},
+			packing: PackDelta,
+			expect: []byte{
+				0x41, 0x03, 0x03, // len=3, packed
+				0xc3, // delta, signed
+				0x14, // 10
+				0x05, // -3
+			},
+		},
+		{
+			name:    "varint []float32 is fixed",
+			input:   []float32{1.5},
+			packing: PackVarint,
+			expect: []byte{
+				0x41, 0x03, 0x05, // len=5, packed
+				0xa1, // fixed, float, width=4
+				0x3f, 0xc0, 0x00, 0x00,
+			},
+		},
+		{
+			name:    "fixed slice of named int type",
+			input:   []testNamedInt{-1},
+			packing: PackFixed,
+			expect: []byte{
+				0x41, 0x03, 0x03, // len=3, packed
+				0x41, // fixed, signed, width=2
+				0xff, 0xff,
+			},
+		},
+		{
+			name:    "empty slice",
+			input:   []int{},
+			packing: PackVarint,
+			expect:  []byte{0x00},
+		},
+		{
+			name:    "nil slice",
+			input:   []int(nil),
+			packing: PackVarint,
+			expect:  []byte{0xa0},
+		},
+		{
+			name:    "slice of pointers is not packed",
+			input:   []*int{ref(1)},
+			packing: PackVarint,
+			expect:  []byte{0x01, 0x02, 0x01, 0x01},
+		},
+		{
+			name:    "byte slice is raw",
+			input:   []byte{0x01},
+			packing: PackFixed,
+			expect:  []byte{0x41, 0x02, 0x01, 0x01},
+		},
+		{
+			name:    "tags override format",
+			input:   testPackedStruct{Fixed: []int16{1}, Varint: []int{1}, Delta: []uint32{1}, Plain: []int8{1}},
+			packing: PackNone,
+			expect: []byte{
+				0x01, 0x35, // len=53
+
+				0x41, 0x82, 0x05, 0x44, 0x65, 0x6c, 0x74, 0x61, // "Delta"
+				0x41, 0x03, 0x02, 0x93, 0x02, // packed delta [1]
+
+				0x41, 0x82, 0x05, 0x46, 0x69, 0x78, 0x65, 0x64, // "Fixed"
+				0x41, 0x03, 0x03, 0x41, 0x00, 0x01, // packed fixed [1]
+
+				0x41, 0x82, 0x05, 0x50, 0x6c, 0x61, 0x69, 0x6e, // "Plain"
+				0x01, 0x02, 0x01, 0x01, // [1]
+
+				0x41, 0x82, 0x06, 0x56, 0x61, 0x72, 0x69, 0x6e, 0x74, // "Varint"
+				0x41, 0x03, 0x02, 0xc2, 0x02, // packed varint [1]
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := EncWithFormat(tc.input, &Format{Packing: tc.packing})
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_Dec_Packed(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		largeInts := make([]int64, 1000)
+		for i := range largeInts {
+			largeInts[i] = int64(i*i) - 5000
+		}
+
+		testCases := []struct {
+			name   string
+			input  interface{}
+			output func() interface{}
+		}{
+			{name: "[]int", input: []int{1, -1, math.MaxInt64, math.MinInt64}, output: func() interface{} { return new([]int) }},
+			{name: "[]int8", input: []int8{1, -1, math.MaxInt8, math.MinInt8}, output: func() interface{} { return new([]int8) }},
+			{name: "[]uint16", input: []uint16{1, 0, math.MaxUint16}, output: func() interface{} { return new([]uint16) }},
+			{name: "[]uint64", input: []uint64{1, 0, math.MaxUint64}, output: func() interface{} { return new([]uint64) }},
+			{name: "[]float32", input: []float32{1.5, -0.25, math.MaxFloat32}, output: func() interface{} { return new([]float32) }},
+			{name: "[]float64", input: []float64{1.5, -0.25, math.SmallestNonzeroFloat64}, output: func() interface{} { return new([]float64) }},
+			{name: "[3]int32", input: [3]int32{1, -1, math.MinInt32}, output: func() interface{} { return new([3]int32) }},
+			{name: "[]testNamedInt", input: []testNamedInt{1, -1, math.MaxInt16}, output: func() interface{} { return new([]testNamedInt) }},
+			{name: "large []int64", input: largeInts, output: func() interface{} { return new([]int64) }},
+		}
+
+		for _, tc := range testCases {
+			for _, packing := range []Packing{PackFixed, PackVarint, PackDelta} {
+				t.Run(tc.name+" "+packing.String(), func(t *testing.T) {
+					assert := assert.New(t)
+
+					f := &Format{Packing: packing}
+					data, err := EncWithFormat(tc.input, f)
+					if !assert.NoError(err) {
+						return
+					}
+
+					actual := tc.output()
+					n, err := Dec(data, actual)
+					if !assert.NoError(err) {
+						return
+					}
+
+					assert.Equal(len(data), n)
+					assert.Equal(tc.input, reflect.ValueOf(actual).Elem().Interface())
+				})
+			}
+		}
+	})
+
+	t.Run("tagged struct", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := testPackedStruct{
+			Fixed:  []int16{1, -16000},
+			Varint: []int{413, 612, -1025},
+			Delta:  []uint32{8, 9, 10, 4000},
+			Plain:  []int8{4, 1, 3},
+		}
+
+		data := MustEnc(input)
+
+		var actual testPackedStruct
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(input, actual)
+	})
+
+	t.Run("into different integer type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := []byte{
+			0x41, 0x03, 0x04,
+			0xc2,
+			0x02,
+			0xd8, 0x04,
+		}
+		expect := []int32{1, 300}
+
+		var actual []int32
+		n, err := Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("into array with extra room", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := []byte{0x41, 0x03, 0x02, 0xc2, 0x02}
+		expect := [3]int{1, 0, 0}
+
+		var actual [3]int
+		_, err := DecWithFormat(data, &actual, &Format{ArrayLength: ArrayPad})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("into array that is too small", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := []byte{0x41, 0x03, 0x03, 0xc2, 0x02, 0x02}
+
+		var actual [1]int
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("floats into ints", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := []byte{0x41, 0x03, 0x05, 0xa1, 0x3f, 0xc0, 0x00, 0x00}
+
+		var actual []int
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("into slice of strings", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := []byte{0x41, 0x03, 0x02, 0xc2, 0x02}
+
+		var actual []string
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("truncated varint", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := []byte{0x41, 0x03, 0x02, 0xc2, 0xd8}
+
+		var actual []int
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("fixed data not a multiple of width", func(t *testing.T) {
+		assert := assert.New(t)
+
+		data := []byte{0x41, 0x03, 0x04, 0x41, 0x00, 0x01, 0x00}
+
+		var actual []int16
+		_, err := Dec(data, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+}
@@ -0,0 +1,684 @@
+package rezi
+
+// path.go contains functions for finding or replacing a single value within
+// encoded data by its path, without decoding the values around it.
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// errPathFallback is returned when the encoded value at a path cannot be found
+// by skipping over the values around it, and the data must be decoded in full
+// to get it.
+var errPathFallback = errors.New("value must be fully decoded to find path")
+
+// pathPart is one part of a path given to DecPath. It is either the name of a
+// struct field or the text between the brackets of an index or map key.
+type pathPart struct {
+	field   string
+	bracket string
+	indexed bool
+}
+
+// String returns the part as it appears in a path.
+func (p pathPart) String() string {
+	if p.indexed {
+		return "[" + p.bracket + "]"
+	}
+	return "." + p.field
+}
+
+// parsePath splits a path such as "Info.Taxonomy[2]" or "[Karkat].Age" into
+// its parts. A leading dot is optional.
+func parsePath(path string) ([]pathPart, error) {
+	var parts []pathPart
+
+	rest := strings.TrimPrefix(path, ".")
+	for rest != "" {
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, errorf("invalid path %q: missing ]", path)
+			}
+			parts = append(parts, pathPart{bracket: rest[1:end], indexed: true})
+			rest = rest[end+1:]
+			if strings.HasPrefix(rest, ".") {
+				rest = rest[1:]
+				if rest == "" || rest[0] == '.' || rest[0] == '[' {
+					return nil, errorf("invalid path %q: empty field name", path)
+				}
+			}
+			continue
+		}
+
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, errorf("invalid path %q: empty field name", path)
+		}
+		parts = append(parts, pathPart{field: rest[:end]})
+		rest = rest[end:]
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" || rest[0] == '.' || rest[0] == '[' {
+				return nil, errorf("invalid path %q: empty field name", path)
+			}
+		}
+	}
+
+	return parts, nil
+}
+
+// pathIndex returns the index given by p for a step into a slice or array.
+func pathIndex(p pathPart) (int, error) {
+	if !p.indexed {
+		return 0, errorf("cannot select field %s of a slice or array", p).wrap(ErrInvalidType)
+	}
+	idx, err := strconv.Atoi(p.bracket)
+	if err != nil || idx < 0 {
+		return 0, errorf("invalid index %s", p).wrap(ErrInvalidType)
+	}
+	return idx, nil
+}
+
+// pathField returns the field of a struct with the given fields that is named
+// by p.
+func pathField(p pathPart, fs *fields) (fieldInfo, error) {
+	if p.indexed {
+		return fieldInfo{}, errorf("cannot index a struct with %s", p).wrap(ErrInvalidType)
+	}
+	fi, ok := fs.ByName[p.field]
+	if !ok {
+		return fieldInfo{}, errorf("struct has no field %s", p).wrap(ErrInvalidType)
+	}
+	return fi, nil
+}
+
+// pathKey returns the map key of type t that is given by p. Only keys of bool,
+// string, integer, and float types can be given in a path.
+func pathKey(p pathPart, t reflect.Type) (reflect.Value, error) {
+	if !p.indexed {
+		return reflect.Value{}, errorf("cannot select field %s of a map", p).wrap(ErrInvalidType)
+	}
+
+	key := reflect.New(t).Elem()
+
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		key.SetString(p.bracket)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(p.bracket)
+		key.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(p.bracket, 0, t.Bits())
+		key.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(p.bracket, 0, t.Bits())
+		key.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(p.bracket, t.Bits())
+		key.SetFloat(f)
+	default:
+		return key, errorf("map key of type %s cannot be given in a path", t).wrap(ErrInvalidType)
+	}
+
+	if err != nil {
+		return key, errorf("invalid map key %s: %s", p, err).wrap(ErrInvalidType)
+	}
+	return key, nil
+}
+
+// pathContents returns the bytes counted by the header of the encoded value at
+// the start of data, along with the number of bytes before them.
+func pathContents(data []byte) (contents []byte, offset int, err error) {
+	count, err := decInt[tLen](data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if count.v < 0 {
+		return nil, 0, errorDecf(0, "count < 0").wrap(ErrMalformedData)
+	}
+	data = data[count.n:]
+	if len(data) < count.v {
+		return nil, 0, errorDecf(count.n, "%s", io.ErrUnexpectedEOF).wrap(ErrMalformedData)
+	}
+	return data[:count.v], count.n, nil
+}
+
+// pathMatch is where the encoded value at a path is within data.
+type pathMatch struct {
+	// start and end are the offsets of the bytes of the value within data.
+	start, end int
+
+	// headers holds the offset within data of the count header of every value
+	// that encloses the found value, from the outermost to the innermost.
+	headers []int
+
+	// rest holds the parts of the path that could not be followed without
+	// decoding the found value, which has type t with type info ti. If rest is
+	// empty, the found value is the one at the end of the path.
+	rest []pathPart
+	t    reflect.Type
+	ti   typeInfo
+}
+
+// within returns m as it is within the value enclosing the data it was found
+// in, whose count header is at the start of that value and which has the data
+// at the given offset.
+func (m pathMatch) within(offset int) pathMatch {
+	headers := make([]int, 0, len(m.headers)+1)
+	headers = append(headers, 0)
+	for _, h := range m.headers {
+		headers = append(headers, h+offset)
+	}
+
+	m.start += offset
+	m.end += offset
+	m.headers = headers
+	return m
+}
+
+// locatePath returns where the value at the end of parts is within data,
+// which holds an encoded value of type t with type info ti that takes up the
+// first n bytes of it. If the value cannot be found without decoding all of
+// data, the returned match is all n bytes with all of parts left to follow.
+func locatePath(data []byte, n int, t reflect.Type, ti typeInfo, parts []pathPart, sess *session) (pathMatch, error) {
+	whole := pathMatch{end: n, rest: parts, t: t, ti: ti}
+	if sess.trackingRefs() || sess.tablingNames() {
+		return whole, nil
+	}
+
+	m, err := findPath(data, t, ti, parts, sess)
+	if err == errPathFallback {
+		return whole, nil
+	}
+	return m, err
+}
+
+// findPath returns where the encoded value at the end of parts is within
+// data, which holds an encoded value of type t with type info ti. Only the
+// headers of the values around it are read.
+func findPath(data []byte, t reflect.Type, ti typeInfo, parts []pathPart, sess *session) (pathMatch, error) {
+	if len(parts) == 0 {
+		n, err := skipValue(data, shapeOf(ti))
+		if err != nil {
+			return pathMatch{}, err
+		}
+		return pathMatch{end: n, t: t, ti: ti}, nil
+	}
+
+	if len(data) < 1 {
+		return pathMatch{}, errorDecf(0, "%s", io.ErrUnexpectedEOF).wrap(ErrMalformedData)
+	}
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return pathMatch{}, err
+	}
+	if hdr.v.IsNil() {
+		return pathMatch{}, errorf("value before %s is nil", parts[0]).wrap(ErrNotFound)
+	}
+	if hdr.v.Reference {
+		return pathMatch{}, errPathFallback
+	}
+
+	for i := 0; i < ti.Indir; i++ {
+		t = t.Elem()
+	}
+	ti.Indir = 0
+
+	switch ti.Main {
+	case mtStruct:
+		return findField(data, t, ti, parts, sess)
+	case mtSlice, mtArray:
+		return findItem(data, t, ti, parts, sess)
+	case mtMap:
+		return findMapValue(data, t, ti, parts, sess)
+	default:
+		return pathMatch{}, errorf("cannot take step %s into %s", parts[0], t).wrap(ErrInvalidType)
+	}
+}
+
+// findField returns where the encoded value at the end of parts is within the
+// encoded struct at the start of data.
+func findField(data []byte, t reflect.Type, ti typeInfo, parts []pathPart, sess *session) (pathMatch, error) {
+	want, err := pathField(parts[0], ti.Fields)
+	if err != nil {
+		return pathMatch{}, err
+	}
+
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return pathMatch{}, err
+	}
+	if hdr.v.Version == fieldNameTableVersion {
+		return pathMatch{}, errPathFallback
+	}
+
+	data, offset, err := pathContents(data)
+	if err != nil {
+		return pathMatch{}, err
+	}
+
+	descend := func(fi fieldInfo, rest []pathPart) (pathMatch, error) {
+		found, err := findPath(data, t.FieldByIndex(fi.Index).Type, fi.Type, rest, sess)
+		if err != nil {
+			if err != errPathFallback {
+				err = errorDecf(offset, "%s: %v", PathStep{Kind: reflect.Struct, Field: fi.Name}, err)
+			}
+			return found, err
+		}
+		return found.within(offset), nil
+	}
+	skip := func(shape valueShape) error {
+		n, err := skipValue(data, shape)
+		if err != nil {
+			return errorDecf(offset, "%s", err)
+		}
+		offset += n
+		data = data[n:]
+		return nil
+	}
+
+	if hdr.v.Version == tupleStructVersion {
+		count, err := decInt[tLen](data)
+		if err != nil {
+			return pathMatch{}, errorDecf(offset, "decode field count: %s", err)
+		}
+		offset += count.n
+		data = data[count.n:]
+
+		fingerprint, err := decInt[uint32](data)
+		if err != nil {
+			return pathMatch{}, errorDecf(offset, "decode field fingerprint: %s", err)
+		}
+		if count.v != len(ti.Fields.ByOrder) || fingerprint.v != tupleFingerprint(ti.Fields) {
+			return pathMatch{}, errorDecf(offset, "data has different fields than tuple struct").wrap(ErrMalformedData, ErrInvalidType)
+		}
+		offset += fingerprint.n
+		data = data[fingerprint.n:]
+
+		for _, fi := range ti.Fields.ByOrder {
+			if fi.Name == want.Name {
+				return descend(fi, parts[1:])
+			}
+			if err := skip(shapeOf(fi.Type)); err != nil {
+				return pathMatch{}, err
+			}
+		}
+		return pathMatch{}, errorf("field %s is not in data", parts[0]).wrap(ErrNotFound)
+	}
+
+	for len(data) > 0 {
+		// fields with an ID are given by a key instead of a name; see
+		// decStruct.
+		if hdr.v.Version == numberedFieldsVersion && data[0]&infoBitsExt == 0 {
+			id, shape, n, err := decFieldKey(data)
+			if err != nil {
+				return pathMatch{}, errorDecf(offset, "decode field key: %s", err)
+			}
+			offset += n
+			data = data[n:]
+
+			if id == want.ID {
+				return descend(want, parts[1:])
+			}
+			if err := skip(shape); err != nil {
+				return pathMatch{}, err
+			}
+			continue
+		}
+
+		name, err := decString(data)
+		if err != nil {
+			return pathMatch{}, errorDecf(offset, "decode field name: %s", err)
+		}
+		offset += name.n
+		data = data[name.n:]
+
+		if name.v == want.Name {
+			return descend(want, parts[1:])
+		}
+
+		// an embedded struct may have been encoded as a nested struct; look
+		// in it if it holds the wanted field.
+		if emb, ok := ti.Fields.Embedded[name.v]; ok {
+			if len(want.Index) > len(emb.Index) && reflect.DeepEqual(want.Index[:len(emb.Index)], emb.Index) {
+				return descend(emb, parts)
+			}
+			if err := skip(shapeCounted); err != nil {
+				return pathMatch{}, err
+			}
+			continue
+		}
+
+		fi, ok := ti.Fields.ByName[name.v]
+		if !ok {
+			return pathMatch{}, errorDecf(offset, "field name .%s does not exist in %s", name.v, t).wrap(ErrMalformedData, ErrInvalidType)
+		}
+		if err := skip(shapeOf(fi.Type)); err != nil {
+			return pathMatch{}, err
+		}
+	}
+
+	return pathMatch{}, errorf("field %s is not in data", parts[0]).wrap(ErrNotFound)
+}
+
+// findItem returns where the encoded value at the end of parts is within the
+// encoded slice or array at the start of data.
+func findItem(data []byte, t reflect.Type, ti typeInfo, parts []pathPart, sess *session) (pathMatch, error) {
+	idx, err := pathIndex(parts[0])
+	if err != nil {
+		return pathMatch{}, err
+	}
+
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return pathMatch{}, err
+	}
+	if hdr.v.Version == rawBytesVersion || hdr.v.Version == packedVersion {
+		// items do not have their own headers, so the whole slice must be
+		// decoded to get one.
+		n, err := skipValue(data, shapeCounted)
+		if err != nil {
+			return pathMatch{}, err
+		}
+		return pathMatch{end: n, rest: parts, t: t, ti: ti}, nil
+	}
+
+	data, offset, err := pathContents(data)
+	if err != nil {
+		return pathMatch{}, err
+	}
+
+	if hdr.v.ArrayLength {
+		arrLen, err := decInt[tLen](data)
+		if err != nil {
+			return pathMatch{}, errorDecf(offset, "decode array length: %s", err)
+		}
+		offset += arrLen.n
+		data = data[arrLen.n:]
+	}
+
+	shape := shapeOf(*ti.ValType)
+	for i := 0; len(data) > 0; i++ {
+		if i == idx {
+			found, err := findPath(data, t.Elem(), *ti.ValType, parts[1:], sess)
+			if err != nil {
+				if err != errPathFallback {
+					err = errorDecf(offset, "%s: %v", PathStep{Kind: t.Kind(), Index: idx}, err)
+				}
+				return found, err
+			}
+			return found.within(offset), nil
+		}
+
+		n, err := skipValue(data, shape)
+		if err != nil {
+			return pathMatch{}, errorDecf(offset, "%s", err)
+		}
+		offset += n
+		data = data[n:]
+	}
+
+	return pathMatch{}, errorf("index %s is not in data", parts[0]).wrap(ErrNotFound)
+}
+
+// findMapValue returns where the encoded value at the end of parts is within
+// the encoded map at the start of data.
+func findMapValue(data []byte, t reflect.Type, ti typeInfo, parts []pathPart, sess *session) (pathMatch, error) {
+	want, err := pathKey(parts[0], t.Key())
+	if err != nil {
+		return pathMatch{}, err
+	}
+
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return pathMatch{}, err
+	}
+	if hdr.v.Version == fieldNameTableVersion {
+		return pathMatch{}, errPathFallback
+	}
+	if hdr.v.Version == numberedFieldsVersion || hdr.v.Version == tupleStructVersion {
+		return pathMatch{}, errorf("struct data without field names cannot be decoded to a map").wrap(ErrMalformedData, ErrInvalidType)
+	}
+
+	data, offset, err := pathContents(data)
+	if err != nil {
+		return pathMatch{}, err
+	}
+
+	shape := shapeOf(*ti.ValType)
+	for len(data) > 0 {
+		key := reflect.New(t.Key())
+		n, err := decWithTypeInfo(data, key.Interface(), *ti.KeyType, sess)
+		if err != nil {
+			return pathMatch{}, errorDecf(offset, "map key: %v", err)
+		}
+		offset += n
+		data = data[n:]
+
+		if key.Elem().Interface() == want.Interface() {
+			found, err := findPath(data, t.Elem(), *ti.ValType, parts[1:], sess)
+			if err != nil {
+				if err != errPathFallback {
+					err = errorDecf(offset, "%s: %v", PathStep{Kind: reflect.Map, Key: want.Interface()}, err)
+				}
+				return found, err
+			}
+			return found.within(offset), nil
+		}
+
+		n, err = skipValue(data, shape)
+		if err != nil {
+			return pathMatch{}, errorDecf(offset, "%s", err)
+		}
+		offset += n
+		data = data[n:]
+	}
+
+	return pathMatch{}, errorf("key %s is not in data", parts[0]).wrap(ErrNotFound)
+}
+
+// findDecoded returns the bytes of the encoded value at the end of parts
+// within the encoded value at the start of data by decoding all of it, then
+// encoding the value that is found.
+func findDecoded(data []byte, t reflect.Type, ti typeInfo, parts []pathPart, sess *session) ([]byte, error) {
+	v := reflect.New(t)
+	if _, err := decWithTypeInfo(data, v.Interface(), ti, sess); err != nil {
+		return nil, err
+	}
+
+	found, foundInfo, err := walkPath(v.Elem(), ti, parts)
+	if err != nil {
+		return nil, err
+	}
+	return encWithTypeInfo(found.Interface(), foundInfo, sess.stateless())
+}
+
+// replaceDecoded returns the encoded value at the start of data with the value
+// at the end of parts within it replaced by x. All of the value is decoded,
+// then encoded again once x is in it.
+func replaceDecoded(data []byte, t reflect.Type, ti typeInfo, parts []pathPart, x interface{}, f *Format) ([]byte, error) {
+	v := reflect.New(t)
+	if _, err := decWithTypeInfo(data, v.Interface(), ti, newSession(f)); err != nil {
+		return nil, err
+	}
+
+	if err := setPath(v.Elem(), ti, parts, x); err != nil {
+		return nil, err
+	}
+	return encWithTypeInfo(v.Elem().Interface(), ti, newSession(f))
+}
+
+// patchHeaders returns data with the count of each header at the given
+// offsets changed by delta, which is the number of bytes that were added to
+// the innermost value. The headers must be given from the outermost to the
+// innermost. A header that takes up a different number of bytes once changed
+// also changes the count of the headers before it.
+func patchHeaders(data []byte, headers []int, delta int) ([]byte, error) {
+	for i := len(headers) - 1; i >= 0; i-- {
+		h := headers[i]
+
+		hdr, err := decCountHeader(data[h:])
+		if err != nil {
+			return nil, err
+		}
+		count, err := decInt[tLen](data[h:])
+		if err != nil {
+			return nil, err
+		}
+
+		newHdr := encCount(tLen(count.v+delta), &hdr.v)
+
+		patched := make([]byte, 0, len(data)+len(newHdr)-count.n)
+		patched = append(patched, data[:h]...)
+		patched = append(patched, newHdr...)
+		patched = append(patched, data[h+count.n:]...)
+
+		data = patched
+		delta += len(newHdr) - count.n
+	}
+	return data, nil
+}
+
+// pathValue returns x as a value of type t, which it must either be or be a
+// pointer to.
+func pathValue(x interface{}, t reflect.Type) (reflect.Value, error) {
+	v := reflect.ValueOf(x)
+	if !v.IsValid() {
+		return v, errorf("cannot put nil at path with type %s", t).wrap(ErrInvalidType)
+	}
+	if v.Type() == t {
+		return v, nil
+	}
+	if v.Kind() == reflect.Pointer && v.Type().Elem() == t {
+		if v.IsNil() {
+			return v, errorf("cannot put nil %s at path with type %s", v.Type(), t).wrap(ErrInvalidType)
+		}
+		return v.Elem(), nil
+	}
+	return v, errorf("cannot put %s at path with type %s", v.Type(), t).wrap(ErrInvalidType)
+}
+
+// setPath sets the value at the end of parts within v, which has type info ti,
+// to x. Values in maps along the way are copied, changed, and set back in the
+// map.
+func setPath(v reflect.Value, ti typeInfo, parts []pathPart, x interface{}) error {
+	if len(parts) == 0 {
+		newVal, err := pathValue(x, v.Type())
+		if err != nil {
+			return err
+		}
+		v.Set(newVal)
+		return nil
+	}
+
+	p := parts[0]
+	for i := 0; i < ti.Indir; i++ {
+		if v.IsNil() {
+			return errorf("value before %s is nil", p).wrap(ErrNotFound)
+		}
+		v = v.Elem()
+	}
+	ti.Indir = 0
+
+	switch ti.Main {
+	case mtStruct:
+		fi, err := pathField(p, ti.Fields)
+		if err != nil {
+			return err
+		}
+		field, ok := fieldByIndex(v, fi.Index)
+		if !ok {
+			return errorf("field %s is in a nil embedded struct", p).wrap(ErrNotFound)
+		}
+		return setPath(field, fi.Type, parts[1:], x)
+	case mtSlice, mtArray:
+		idx, err := pathIndex(p)
+		if err != nil {
+			return err
+		}
+		if idx >= v.Len() {
+			return errorf("index %s is not in data", p).wrap(ErrNotFound)
+		}
+		return setPath(v.Index(idx), *ti.ValType, parts[1:], x)
+	case mtMap:
+		key, err := pathKey(p, v.Type().Key())
+		if err != nil {
+			return err
+		}
+		val := v.MapIndex(key)
+		if !val.IsValid() {
+			return errorf("key %s is not in data", p).wrap(ErrNotFound)
+		}
+		elem := reflect.New(val.Type()).Elem()
+		elem.Set(val)
+		if err := setPath(elem, *ti.ValType, parts[1:], x); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	default:
+		return errorf("cannot take step %s into %s", p, v.Type()).wrap(ErrInvalidType)
+	}
+}
+
+// walkPath returns the value at the end of parts within v, which has type info
+// ti, along with the type info of that value.
+func walkPath(v reflect.Value, ti typeInfo, parts []pathPart) (reflect.Value, typeInfo, error) {
+	for _, p := range parts {
+		for i := 0; i < ti.Indir; i++ {
+			if v.IsNil() {
+				return v, ti, errorf("value before %s is nil", p).wrap(ErrNotFound)
+			}
+			v = v.Elem()
+		}
+		ti.Indir = 0
+
+		switch ti.Main {
+		case mtStruct:
+			fi, err := pathField(p, ti.Fields)
+			if err != nil {
+				return v, ti, err
+			}
+			field, ok := fieldByIndex(v, fi.Index)
+			if !ok {
+				return v, ti, errorf("field %s is in a nil embedded struct", p).wrap(ErrNotFound)
+			}
+			v, ti = field, fi.Type
+		case mtSlice, mtArray:
+			idx, err := pathIndex(p)
+			if err != nil {
+				return v, ti, err
+			}
+			if idx >= v.Len() {
+				return v, ti, errorf("index %s is not in data", p).wrap(ErrNotFound)
+			}
+			v, ti = v.Index(idx), *ti.ValType
+		case mtMap:
+			key, err := pathKey(p, v.Type().Key())
+			if err != nil {
+				return v, ti, err
+			}
+			val := v.MapIndex(key)
+			if !val.IsValid() {
+				return v, ti, errorf("key %s is not in data", p).wrap(ErrNotFound)
+			}
+			v, ti = val, *ti.ValType
+		default:
+			return v, ti, errorf("cannot take step %s into %s", p, v.Type()).wrap(ErrInvalidType)
+		}
+	}
+	return v, ti, nil
+}
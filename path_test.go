@@ -0,0 +1,580 @@
+package rezi
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testRecord is a struct with a field of most kinds, for tests of functions
+// that reach into encoded data by path or field.
+type testRecord struct {
+	ID      int
+	Name    string
+	Note    string `rezi:",omitempty"`
+	Info    testRecordInfo
+	Items   []testRecordItem
+	Latest  *testRecordItem
+	ByKey   map[string]testRecordItem
+	Tags    map[int]string
+	Scores  map[string]int
+	Weights []uint16 `rezi:",packed"`
+	Fixed   [3]string
+	Flag    bool
+	Ratio   float64
+}
+
+type testRecordInfo struct {
+	AverageAge int
+	Taxonomy   []string
+}
+
+type testRecordItem struct {
+	Name  string
+	Count int
+}
+
+func testRecordValue() testRecord {
+	return testRecord{
+		ID:   413,
+		Name: "Sburb",
+		Info: testRecordInfo{AverageAge: 6, Taxonomy: []string{"Animalia", "Chordata", "Mammalia"}},
+		Items: []testRecordItem{
+			{Name: "lusus", Count: 1},
+			{Name: "husktop", Count: 2},
+			{Name: "sylladex", Count: 3},
+		},
+		Latest:  &testRecordItem{Name: "strife", Count: 4},
+		ByKey:   map[string]testRecordItem{"k": {Name: "specibus", Count: 5}},
+		Tags:    map[int]string{1: "one", 20: "twenty"},
+		Scores:  map[string]int{"Karkat": 8, "Terezi": 12},
+		Weights: []uint16{100, 200, 300},
+		Fixed:   [3]string{"x", "y", "z"},
+		Flag:    true,
+		Ratio:   0.5,
+	}
+}
+
+func Test_parsePath(t *testing.T) {
+	testCases := []struct {
+		name      string
+		path      string
+		expect    []pathPart
+		expectErr bool
+	}{
+		{name: "empty", path: "", expect: nil},
+		{name: "field", path: "Info", expect: []pathPart{{field: "Info"}}},
+		{name: "leading dot", path: ".Info", expect: []pathPart{{field: "Info"}}},
+		{
+			name: "fields and index",
+			path: "Info.Taxonomy[2]",
+			expect: []pathPart{
+				{field: "Info"},
+				{field: "Taxonomy"},
+				{bracket: "2", indexed: true},
+			},
+		},
+		{
+			name: "key then field",
+			path: "[Karkat].Age",
+			expect: []pathPart{
+				{bracket: "Karkat", indexed: true},
+				{field: "Age"},
+			},
+		},
+		{
+			name: "consecutive brackets",
+			path: "[1][2]",
+			expect: []pathPart{
+				{bracket: "1", indexed: true},
+				{bracket: "2", indexed: true},
+			},
+		},
+		{name: "unclosed bracket", path: "Info[2", expectErr: true},
+		{name: "double dot", path: "Info..Taxonomy", expectErr: true},
+		{name: "trailing dot", path: "Info.", expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := parsePath(tc.path)
+			if tc.expectErr {
+				assert.Error(err)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_DecPath(t *testing.T) {
+	record := testRecordValue()
+	data := MustEnc(record)
+
+	t.Run("int field", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual int
+		n, err := DecPath[testRecord](data, "ID", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(413, actual)
+	})
+
+	t.Run("nested slice item", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual string
+		_, err := DecPath[testRecord](data, "Info.Taxonomy[2]", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("Mammalia", actual)
+	})
+
+	t.Run("whole nested struct", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual testRecordInfo
+		_, err := DecPath[testRecord](data, ".Info", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(record.Info, actual)
+	})
+
+	t.Run("string-keyed map value", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual int
+		_, err := DecPath[testRecord](data, "Scores[Terezi]", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(12, actual)
+	})
+
+	t.Run("int-keyed map value", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual string
+		_, err := DecPath[testRecord](data, "Tags[20]", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("twenty", actual)
+	})
+
+	t.Run("through pointer", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual string
+		_, err := DecPath[testRecord](data, "Latest.Name", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("strife", actual)
+	})
+
+	t.Run("packed slice item", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual uint16
+		_, err := DecPath[testRecord](data, "Weights[1]", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(uint16(200), actual)
+	})
+
+	t.Run("field after skipped bool", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual float64
+		_, err := DecPath[testRecord](data, "Ratio", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(0.5, actual)
+	})
+
+	t.Run("empty path", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual testRecord
+		n, err := DecPath[testRecord](data, "", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(record, actual)
+	})
+
+	t.Run("top-level slice", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc([]testRecordItem{{Name: "Fungi"}, {Name: "Protista"}})
+
+		var actual string
+		_, err := DecPath[[]testRecordItem](input, "[1].Name", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("Protista", actual)
+	})
+
+	t.Run("raw bytes item", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc([]byte{0x0a, 0x0b, 0x0c})
+
+		var actual byte
+		_, err := DecPath[[]byte](input, "[2]", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(byte(0x0c), actual)
+	})
+}
+
+func Test_DecPath_SkipsSiblings(t *testing.T) {
+	assert := assert.New(t)
+	type record struct {
+		Small []int8
+		Tail  string
+	}
+	data := MustEnc(record{Small: []int8{5}, Tail: "Aradia"})
+
+	// make the item of Small out of range of int8 without changing its length
+	itemAt := bytes.Index(data, []byte{0x01, 0x05})
+	if !assert.GreaterOrEqual(itemAt, 0) {
+		return
+	}
+	data[itemAt+1] = 0xff
+
+	var full record
+	_, err := Dec(data, &full)
+	if !assert.ErrorIs(err, ErrRange) {
+		return
+	}
+
+	var actual string
+	_, err = DecPath[record](data, "Tail", &actual)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal("Aradia", actual)
+}
+
+func Test_DecPath_StructVersions(t *testing.T) {
+	t.Run("numbered fields", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testNumberedStruct{Count: 3, Name: "Nepeta", Note: "Leijon"})
+
+		var count int
+		_, err := DecPath[testNumberedStruct](input, "Count", &count)
+		if !assert.NoError(err) {
+			return
+		}
+		var note string
+		_, err = DecPath[testNumberedStruct](input, "Note", &note)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(3, count)
+		assert.Equal("Leijon", note)
+	})
+
+	t.Run("tuple", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructTuple{Value: 1, Name: "two", Flag: true})
+
+		var actual bool
+		_, err := DecPath[testStructTuple](input, "Flag", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(true, actual)
+	})
+
+	t.Run("embedded", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructEmbedUnexported{testEmbedSecret: testEmbedSecret{Secret: "Animalia"}, Name: "x"})
+
+		var actual string
+		_, err := DecPath[testStructEmbedUnexported](input, "Secret", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("Animalia", actual)
+	})
+
+	t.Run("field-name table", func(t *testing.T) {
+		assert := assert.New(t)
+		input, err := EncWithFormat([]testRecordItem{{Name: "Fungi"}, {Name: "Protista"}}, &Format{FieldNameTable: true})
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual string
+		_, err = DecPath[[]testRecordItem](input, "[1].Name", &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("Protista", actual)
+	})
+
+	t.Run("references", func(t *testing.T) {
+		assert := assert.New(t)
+		shared := &testRecordItem{Name: "Fungi"}
+		f := &Format{TrackReferences: true}
+		input, err := EncWithFormat([]*testRecordItem{shared, shared}, f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual string
+		_, err = DecPathWithFormat[[]*testRecordItem](input, "[1].Name", &actual, f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("Fungi", actual)
+	})
+}
+
+func Test_DecPath_Errors(t *testing.T) {
+	data := MustEnc(testRecordValue())
+
+	testCases := []struct {
+		name        string
+		input       []byte
+		path        string
+		expectErrIs error
+	}{
+		{name: "unknown field", input: data, path: "Nope", expectErrIs: ErrInvalidType},
+		{name: "index past end", input: data, path: "Info.Taxonomy[3]", expectErrIs: ErrNotFound},
+		{name: "missing map key", input: data, path: "Scores[Vriska]", expectErrIs: ErrNotFound},
+		{name: "packed index past end", input: data, path: "Weights[5]", expectErrIs: ErrNotFound},
+		{name: "bad index", input: data, path: "Info.Taxonomy[x]", expectErrIs: ErrInvalidType},
+		{name: "bad map key", input: data, path: "Tags[x]", expectErrIs: ErrInvalidType},
+		{name: "step into int", input: data, path: "ID.X", expectErrIs: ErrInvalidType},
+		{name: "nil pointer", input: MustEnc(testRecord{}), path: "Latest.Name", expectErrIs: ErrNotFound},
+		{name: "omitted field", input: MustEnc(struct{ ID int }{ID: 1}), path: "Flag", expectErrIs: ErrNotFound},
+		{name: "truncated", input: data[:len(data)/2], path: "Ratio", expectErrIs: ErrMalformedData},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var actual string
+			_, err := DecPath[testRecord](tc.input, tc.path, &actual)
+
+			assert.ErrorIs(err, tc.expectErrIs)
+		})
+	}
+}
+
+func Test_EncPath(t *testing.T) {
+	longName := strings.Repeat("Alternia", 40)
+
+	testCases := []struct {
+		name   string
+		path   string
+		value  interface{}
+		modify func(r *testRecord)
+	}{
+		{
+			name:   "int field",
+			path:   "ID",
+			value:  612,
+			modify: func(r *testRecord) { r.ID = 612 },
+		},
+		{
+			name:   "nested slice item grows headers",
+			path:   "Info.Taxonomy[1]",
+			value:  longName,
+			modify: func(r *testRecord) { r.Info.Taxonomy[1] = longName },
+		},
+		{
+			name:   "nested slice item shrinks",
+			path:   "Info.Taxonomy[0]",
+			value:  "",
+			modify: func(r *testRecord) { r.Info.Taxonomy[0] = "" },
+		},
+		{
+			name:   "map value",
+			path:   "Scores[Karkat]",
+			value:  -1,
+			modify: func(r *testRecord) { r.Scores["Karkat"] = -1 },
+		},
+		{
+			name:   "through pointer",
+			path:   "Latest.Name",
+			value:  "Fungi",
+			modify: func(r *testRecord) { r.Latest.Name = "Fungi" },
+		},
+		{
+			name:   "pointer field set to nil",
+			path:   "Latest",
+			value:  (*testRecordItem)(nil),
+			modify: func(r *testRecord) { r.Latest = nil },
+		},
+		{
+			name:   "packed slice item",
+			path:   "Weights[2]",
+			value:  uint16(65000),
+			modify: func(r *testRecord) { r.Weights[2] = 65000 },
+		},
+		{
+			name:   "packed slice keeps tag options",
+			path:   "Weights",
+			value:  []uint16{1, 2},
+			modify: func(r *testRecord) { r.Weights = []uint16{1, 2} },
+		},
+		{
+			name:   "pointer to value",
+			path:   "Flag",
+			value:  new(bool),
+			modify: func(r *testRecord) { r.Flag = false },
+		},
+		{
+			name:  "empty path",
+			path:  "",
+			value: testRecord{ID: 8},
+			modify: func(r *testRecord) {
+				*r = testRecord{ID: 8}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+			input := MustEnc(testRecordValue())
+			original := append([]byte(nil), input...)
+
+			expect := testRecordValue()
+			tc.modify(&expect)
+
+			actual, err := EncPath[testRecord](input, tc.path, tc.value)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(MustEnc(expect), actual)
+			assert.Equal(original, input, "input was modified")
+		})
+	}
+
+	t.Run("trailing data is kept", func(t *testing.T) {
+		assert := assert.New(t)
+		input := append(MustEnc([]string{"a", "b"}), MustEnc("after")...)
+
+		actual, err := EncPath[[]string](input, "[0]", "Aranea")
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(append(MustEnc([]string{"Aranea", "b"}), MustEnc("after")...), actual)
+	})
+
+	t.Run("field-name table", func(t *testing.T) {
+		assert := assert.New(t)
+		f := &Format{FieldNameTable: true}
+		input, err := EncWithFormat([]testRecordItem{{Name: "Fungi"}, {Name: "Protista"}}, f)
+		if !assert.NoError(err) {
+			return
+		}
+		expect, err := EncWithFormat([]testRecordItem{{Name: "Fungi"}, {Name: "Animalia"}}, f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		actual, err := EncPathWithFormat[[]testRecordItem](input, "[1].Name", "Animalia", f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("references", func(t *testing.T) {
+		assert := assert.New(t)
+		f := &Format{TrackReferences: true}
+		shared := &testRecordItem{Name: "Fungi"}
+		input, err := EncWithFormat([]*testRecordItem{shared, shared}, f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		actual, err := EncPathWithFormat[[]*testRecordItem](input, "[0].Name", "Plantae", f)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var decoded []*testRecordItem
+		_, err = DecWithFormat(actual, &decoded, f)
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal("Plantae", decoded[1].Name)
+		assert.Same(decoded[0], decoded[1])
+	})
+}
+
+func Test_EncPath_Errors(t *testing.T) {
+	data := MustEnc(testRecordValue())
+
+	testCases := []struct {
+		name        string
+		input       []byte
+		path        string
+		value       interface{}
+		expectErrIs error
+	}{
+		{name: "wrong type", input: data, path: "ID", value: "413", expectErrIs: ErrInvalidType},
+		{name: "nil", input: data, path: "ID", value: nil, expectErrIs: ErrInvalidType},
+		{name: "unknown field", input: data, path: "Nope", value: 1, expectErrIs: ErrInvalidType},
+		{name: "missing map key", input: data, path: "Scores[Vriska]", value: 1, expectErrIs: ErrNotFound},
+		{name: "index past end", input: data, path: "Info.Taxonomy[3]", value: "x", expectErrIs: ErrNotFound},
+		{name: "packed index past end", input: data, path: "Weights[3]", value: uint16(1), expectErrIs: ErrNotFound},
+		{name: "nil pointer", input: MustEnc(testRecord{}), path: "Latest.Name", value: "x", expectErrIs: ErrNotFound},
+		{name: "truncated", input: data[:len(data)/2], path: "Ratio", value: 1.0, expectErrIs: ErrMalformedData},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := EncPath[testRecord](tc.input, tc.path, tc.value)
+
+			assert.ErrorIs(err, tc.expectErrIs)
+		})
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"io"
 	"math"
 	"reflect"
-	"strings"
 	"unicode/utf8"
 )
 
@@ -23,6 +22,8 @@ const (
 
 	// used only in extension byte 1:
 	infoBitsByteCount = 0b10000000
+	infoBitsRef       = 0b00100000
+	infoBitsArrayLen  = 0b00010000
 	infoBitsVersion   = 0b00001111
 	// extension bit not listed because it is the same
 )
@@ -74,7 +75,7 @@ type anyComplex interface {
 func encCheckedPrim(value analyzed[any]) ([]byte, error) {
 	switch value.info.Main {
 	case mtString:
-		return encWithNilCheck(value, nilErrEncoder(encString), reflect.Value.String)
+		return encWithNilCheck(value, encCheckedString, reflect.Value.String)
 	case mtBool:
 		return encWithNilCheck(value, nilErrEncoder(encBool), reflect.Value.Bool)
 	case mtIntegral:
@@ -179,9 +180,18 @@ func decCheckedPrim(data []byte, recv analyzed[any]) (decoded[any], error) {
 	// or an implementor of BinaryUnmarshaler.
 	var dec decoded[any]
 
+	// numbers of a different kind than the receiver must be converted to it.
+	if kind := numKindOf(recv.info); kind != numKindNone {
+		if stored := storedNumKind(data, recv.info); stored != kind {
+			return decConvertedNum(data, recv, stored)
+		}
+	}
+
 	switch recv.info.Main {
 	case mtString:
-		s, err := decWithNilCheck(data, recv, decString)
+		s, err := decWithNilCheck(data, recv, func(data []byte) (decoded[string], error) {
+			return decCheckedString(data, recv.sess.invalidUTF8())
+		})
 		dec.n += s.n
 		dec.v = s.v
 		if err != nil {
@@ -441,6 +451,8 @@ func encCount(count tLen, extra *countHeader) []byte {
 		ExtensionLevel: extra.ExtensionLevel,
 		Version:        extra.Version,
 		ByteLength:     extra.ByteLength,
+		Reference:      extra.Reference,
+		ArrayLength:    extra.ArrayLength,
 	}
 
 	hdrBytes, err := hdr.MarshalBinary()
@@ -870,6 +882,11 @@ func decFloat[E anyFloat](data []byte) (decoded[E], error) {
 
 	fVal := math.Float64frombits(iVal)
 
+	if !math.IsInf(fVal, 0) && math.IsInf(float64(E(fVal)), 0) {
+		err := errorDecf(0, "decoded value %v overflows %T", fVal, E(0)).wrap(ErrRange)
+		return d(E(0.0), 0), err
+	}
+
 	return d(E(fVal), int(byteCount)+numHeaderBytes), nil
 }
 
@@ -883,7 +900,9 @@ func encInt[E integral](value analyzed[E]) []byte {
 
 	negative := v < 0
 
-	i := int64(v)
+	// unsigned values are zero-extended and so are never encoded as negative,
+	// even when they are above the maximum int64.
+	i := uint64(v)
 
 	b1 := byte((i >> 56) & 0xff)
 	b2 := byte((i >> 48) & 0xff)
@@ -1001,7 +1020,28 @@ func decInt[E integral](data []byte) (decoded[E], error) {
 	iVal |= (uint64(intData[6]) << 8)
 	iVal |= (uint64(intData[7]))
 
-	return d(E(iVal), int(byteCount)+numHeaderBytes), nil
+	// make sure the value fits in E. a negative value never fits in an
+	// unsigned type, and a non-negative value that uses the top bit of all 8
+	// bytes is above the maximum int64 and so never fits in a signed type.
+	v := E(iVal)
+	var zero E
+	var fits bool
+	if signed := zero-1 < 0; signed {
+		fits = int64(v) == int64(iVal) && (negative || int64(iVal) >= 0)
+	} else {
+		fits = !negative && uint64(v) == iVal
+	}
+	if !fits {
+		var err reziError
+		if negative {
+			err = errorDecf(0, "decoded value %d overflows %T", int64(iVal), v)
+		} else {
+			err = errorDecf(0, "decoded value %d overflows %T", iVal, v)
+		}
+		return d(E(0), 0), err.wrap(ErrRange)
+	}
+
+	return d(v, int(byteCount)+numHeaderBytes), nil
 }
 
 // does not actually use analysis data, only native value. accepts
@@ -1013,13 +1053,9 @@ func encString(value analyzed[string]) []byte {
 		return []byte{0x00}
 	}
 
-	strBytes := make([]byte, 0)
-
-	for _, ch := range s {
-		chBuf := make([]byte, utf8.UTFMax)
-		byteLen := utf8.EncodeRune(chBuf, ch)
-		strBytes = append(strBytes, chBuf[:byteLen]...)
-	}
+	// the bytes are written exactly as they are, whether or not they are
+	// valid UTF-8. callers apply a UTF8Policy first if one is needed.
+	strBytes := []byte(s)
 
 	var enc []byte
 
@@ -1029,7 +1065,9 @@ func encString(value analyzed[string]) []byte {
 	return enc
 }
 
-// decString decodes a string of any version. Assumes header is not nil.
+// decString decodes a string of any version. Assumes header is not nil. The
+// bytes of the string are decoded exactly as they are, whether or not they are
+// valid UTF-8; use decCheckedString to apply a UTF8Policy to them.
 //
 // returned decValue does not set ref automatically.
 func decString(data []byte) (decoded[string], error) {
@@ -1083,24 +1121,8 @@ func decStringV1(data []byte) (decoded[string], error) {
 		err := errorDecf(countLen, errFmt, strLength, len(data), s, verbS).wrap(io.ErrUnexpectedEOF, ErrMalformedData)
 		return d("", 0), err
 	}
-	// clamp it
-	data = data[:strLength]
-
-	readBytes := countLen
 
-	var sb strings.Builder
-	for readBytes-countLen < strLength {
-		ch, charBytesRead, err := decUTF8Codepoint(data)
-		if err != nil {
-			return d("", 0), errorDecf(readBytes, "%s", err)
-		}
-
-		sb.WriteRune(ch)
-		readBytes += charBytesRead
-		data = data[charBytesRead:]
-	}
-
-	return d(sb.String(), readBytes), nil
+	return d(string(data[:strLength]), countLen+strLength), nil
 }
 
 // returned decValue does not set ref automatically.
@@ -1120,36 +1142,19 @@ func decStringV0(data []byte) (decoded[string], error) {
 		return d("", 0), errorDecf(0, "string rune count < 0").wrap(ErrMalformedData)
 	}
 
-	readBytes := n
-
-	var sb strings.Builder
-
+	// each byte that is not part of a valid UTF-8 sequence is counted as one
+	// rune so that it is kept as it is.
+	strLength := 0
 	for i := 0; i < runeCount; i++ {
-		ch, charBytesRead, err := decUTF8Codepoint(data)
-		if err != nil {
-			return d("", 0), errorDecf(readBytes, "%s", err)
-		}
-
-		sb.WriteRune(ch)
-		readBytes += charBytesRead
-		data = data[charBytesRead:]
-	}
-
-	return d(sb.String(), readBytes), nil
-}
-
-func decUTF8Codepoint(data []byte) (rune, int, error) {
-	ch, charBytesRead := utf8.DecodeRune(data)
-	if ch == utf8.RuneError {
+		_, charBytesRead := utf8.DecodeRune(data[strLength:])
 		if charBytesRead == 0 {
-			return ch, 0, errorDecf(0, "bytes could not be read as UTF-8 codepoint data").wrap(io.ErrUnexpectedEOF, ErrMalformedData)
-		} else if charBytesRead == 1 {
-			return ch, 0, errorDecf(0, "invalid UTF-8 encoding in string").wrap(ErrMalformedData)
-		} else {
-			return ch, 0, errorDecf(0, "invalid unicode replacement character in rune").wrap(ErrMalformedData)
+			err := errorDecf(n+strLength, "bytes could not be read as UTF-8 codepoint data").wrap(io.ErrUnexpectedEOF, ErrMalformedData)
+			return d("", 0), err
 		}
+		strLength += charBytesRead
 	}
-	return ch, charBytesRead, nil
+
+	return d(string(data[:strLength]), n+strLength), nil
 }
 
 // does not actually use analysis data, only native value. accepts
@@ -1167,7 +1172,7 @@ func encText(value analyzed[encoding.TextMarshaler]) ([]byte, error) {
 	}
 	tText := string(tTextSlice)
 
-	return encString(analyzed[string]{v: tText}), nil
+	return encCheckedString(analyzed[string]{v: tText, sess: value.sess})
 }
 
 func decText(data []byte, recv analyzed[any]) (decoded[any], error) {
@@ -1177,7 +1182,7 @@ func decText(data []byte, recv analyzed[any]) (decoded[any], error) {
 	var dec decoded[any]
 	var err error
 
-	textData, err = decString(data)
+	textData, err = decCheckedString(data, recv.sess.invalidUTF8())
 	if err != nil {
 		return dec, errorDecf(0, "decode text: %s", err).wrap(ErrMalformedData)
 	}
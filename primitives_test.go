@@ -162,6 +162,14 @@ func Test_encInt(t *testing.T) {
 			assert.Equal(tc.expect, actual)
 		})
 	}
+
+	t.Run("uint64 above max int64", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual := encInt(withNoAnalysis(uint64(1<<63 + 5)))
+
+		assert.Equal([]byte{0x08, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x05}, actual)
+	})
 }
 
 func Test_decInt(t *testing.T) {
@@ -2202,9 +2210,9 @@ func Test_Dec_String(t *testing.T) {
 			expect: result{err: true},
 		},
 		{
-			name:   "err invalid sequence (v1)",
+			name:   "invalid sequence is preserved (v1)",
 			input:  []byte{0x01, 0x01, 0xc3, 0x28},
-			expect: result{err: true},
+			expect: result{val: "\xc3", consumed: 3},
 		},
 		{
 			name:   "one char (v2)",
@@ -2232,9 +2240,9 @@ func Test_Dec_String(t *testing.T) {
 			expect: result{err: true},
 		},
 		{
-			name:   "err invalid sequence (v2)",
+			name:   "invalid sequence is preserved (v2)",
 			input:  []byte{0x41, 0x80, 0x02, 0xc3, 0x28},
-			expect: result{err: true},
+			expect: result{val: "\xc3\x28", consumed: 5},
 		},
 	}
 
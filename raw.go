@@ -0,0 +1,157 @@
+package rezi
+
+// raw.go contains functions for encoding and decoding values that are kept as
+// their encoded bytes.
+
+import (
+	"reflect"
+)
+
+// Raw is a single REZI-encoded value that is kept as its encoded bytes. It is
+// the REZI equivalent of json.RawMessage, and allows the decoding of part of a
+// value to be deferred, or allows it to be passed through to other encoded data
+// without knowing its type.
+//
+// Decoding to a Raw sets it to a copy of the bytes of exactly one encoded
+// value, whose length is found from its header alone. Encoding a Raw writes its
+// bytes as they are. A Raw with no bytes is encoded as nil. The bytes of a Raw
+// can be decoded to the type of the value they hold by passing them to [Dec].
+//
+// Because the length of an encoded integer, float, or bool cannot generally be
+// found without knowing its type, a Raw cannot hold one. The only exception is
+// a negative integer or float, whose header could not begin any other value.
+// When a Raw is the top-level value being decoded, data that cannot be a
+// counted value is assumed to hold a number or bool, and gives an error that
+// matches ErrInvalidType; data that is also a valid counted value is taken to
+// be one. A struct field of type Raw that holds a number is reported with an
+// error that matches ErrInvalidType when it causes the data after it to be
+// misread, but when it does not, the Raw and the fields after it will silently
+// hold the wrong bytes.
+//
+// Data that was encoded with TrackReferences or FieldNameTable enabled in its
+// [Format] may refer to values outside of a Raw, and such references are not
+// resolved when the Raw is decoded.
+type Raw []byte
+
+var refRawType = reflect.TypeOf(Raw(nil))
+
+// rawLen returns the number of bytes taken up by the encoded value at the
+// start of data, as found from its header.
+func rawLen(data []byte) (int, error) {
+	if len(data) < 1 {
+		return 0, errorDecf(0, "no bytes to decode").wrap(ErrMalformedData)
+	}
+
+	// a count is never negative, so a header with the sign bit set that is not
+	// nil and has no EXT byte can only be that of a negative integer or float.
+	shape := shapeCounted
+	if data[0]&(infoBitsSign|infoBitsExt|infoBitsNil) == infoBitsSign {
+		shape = shapeSized
+	}
+	return skipValue(data, shape)
+}
+
+// checkTopLevelRaw returns an error if raw, as decoded by decRaw, is not a
+// counted value, which means it may instead be the start of an integer or bool
+// whose length the header does not give. It is only needed when the Raw is not
+// within a counted value, as the end of that value would otherwise show when
+// too many bytes were taken.
+func checkTopLevelRaw(raw Raw) error {
+	// only a header with nothing but a length can begin a positive integer or
+	// a bool as well as a counted value.
+	if raw[0]&^infoBitsLen != 0 || raw[0] == 0 {
+		return nil
+	}
+
+	errNumber := errorDecf(0, "data is not a counted value and may be an integer or bool, which a Raw cannot hold").wrap(ErrInvalidType)
+
+	// counts are always encoded in as few bytes as possible.
+	if raw[1] == 0 {
+		return errNumber
+	}
+
+	// the values within a counted value must take up exactly its count. Each
+	// of them may have a header that does not give its length, so every way
+	// of reading them is tried.
+	items := raw[1+int(raw[0]&infoBitsLen):]
+	reachable := make([]bool, len(items)+1)
+	reachable[0] = true
+	for i := range items {
+		if !reachable[i] {
+			continue
+		}
+		for _, shape := range []valueShape{shapeByte, shapeSized, shapeCounted} {
+			if itemLen, err := skipValue(items[i:], shape); err == nil {
+				reachable[i+itemLen] = true
+			}
+		}
+	}
+	if !reachable[len(items)] {
+		return errNumber
+	}
+	return nil
+}
+
+// encCheckedRaw encodes a Raw.
+func encCheckedRaw(value analyzed[any]) ([]byte, error) {
+	if value.info.Main != mtRaw {
+		panic("not a raw type")
+	}
+
+	return encWithNilCheck(value, encRaw, func(r reflect.Value) Raw {
+		return r.Interface().(Raw)
+	})
+}
+
+func encRaw(value analyzed[Raw]) ([]byte, error) {
+	raw := value.v
+
+	if len(raw) == 0 {
+		return encNilHeader(0), nil
+	}
+
+	// the bytes are not being decoded, so report problems with them without
+	// the offset that a decode error would give.
+	if n, err := rawLen(raw); err != nil || n != len(raw) {
+		return nil, errorf("Raw does not hold exactly one encoded value").wrap(ErrMalformedData)
+	}
+
+	enc := make([]byte, len(raw))
+	copy(enc, raw)
+	return enc, nil
+}
+
+// decCheckedRaw decodes to a Raw.
+func decCheckedRaw(data []byte, recv analyzed[any]) (decoded[any], error) {
+	if recv.info.Main != mtRaw {
+		panic("not a raw type")
+	}
+
+	raw, err := decWithNilCheck(data, recv, decRaw)
+	if err != nil {
+		return decoded[any]{n: raw.n}, err
+	}
+	if recv.sess.decodingTopLevel() && len(raw.v) > 0 {
+		if err := checkTopLevelRaw(raw.v); err != nil {
+			return decoded[any]{}, err
+		}
+	}
+
+	dec := decoded[any]{v: raw.v, n: raw.n}
+	if recv.info.Indir == 0 {
+		refReceiver := recv.reflect
+		refReceiver.Elem().Set(reflect.ValueOf(raw.v))
+	}
+	return dec, nil
+}
+
+func decRaw(data []byte) (decoded[Raw], error) {
+	n, err := rawLen(data)
+	if err != nil {
+		return decoded[Raw]{}, err
+	}
+
+	raw := make(Raw, n)
+	copy(raw, data)
+	return d(raw, n), nil
+}
@@ -0,0 +1,391 @@
+package rezi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRawEnvelope struct {
+	Kind string
+	Body Raw
+}
+
+func Test_Enc_Raw(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     interface{}
+		expect    []byte
+		expectErr bool
+	}{
+		{
+			name:   "string",
+			input:  Raw{0x41, 0x82, 0x02, 0x68, 0x69},
+			expect: []byte{0x41, 0x82, 0x02, 0x68, 0x69},
+		},
+		{
+			name:   "slice",
+			input:  Raw{0x01, 0x04, 0x01, 0x01, 0x01, 0x02},
+			expect: []byte{0x01, 0x04, 0x01, 0x01, 0x01, 0x02},
+		},
+		{
+			name:   "empty",
+			input:  Raw{},
+			expect: []byte{0xa0},
+		},
+		{
+			name:   "nil",
+			input:  Raw(nil),
+			expect: []byte{0xa0},
+		},
+		{
+			name:   "nil *Raw",
+			input:  (*Raw)(nil),
+			expect: []byte{0xa0},
+		},
+		{
+			name:   "*Raw",
+			input:  &Raw{0x00},
+			expect: []byte{0x00},
+		},
+		{
+			name:   "in struct",
+			input:  testRawEnvelope{Kind: "k", Body: Raw{0x00}},
+			expect: MustEnc(struct{ Kind, Body string }{Kind: "k"}),
+		},
+		{
+			name:      "too few bytes",
+			input:     Raw{0x01, 0x04, 0x01, 0x01},
+			expectErr: true,
+		},
+		{
+			name:      "too many bytes",
+			input:     Raw{0x01, 0x02, 0x01, 0x01, 0x01, 0x02},
+			expectErr: true,
+		},
+		{
+			name:      "int",
+			input:     Raw{0x01, 0x05},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := Enc(tc.input)
+			if tc.expectErr {
+				assert.ErrorIs(err, ErrMalformedData)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_Dec_Raw(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  []byte
+		expect Raw
+	}{
+		{
+			name:   "string",
+			input:  []byte{0x41, 0x82, 0x02, 0x68, 0x69, 0xff},
+			expect: Raw{0x41, 0x82, 0x02, 0x68, 0x69},
+		},
+		{
+			name:   "slice",
+			input:  []byte{0x01, 0x04, 0x01, 0x01, 0x01, 0x02, 0xff},
+			expect: Raw{0x01, 0x04, 0x01, 0x01, 0x01, 0x02},
+		},
+		{
+			name:   "empty",
+			input:  []byte{0x00, 0xff},
+			expect: Raw{0x00},
+		},
+		{
+			name:   "nil",
+			input:  []byte{0xa0, 0xff},
+			expect: Raw{0xa0},
+		},
+		{
+			name:   "negative int",
+			input:  []byte{0x81, 0xfb, 0xff},
+			expect: Raw{0x81, 0xfb},
+		},
+		{
+			name:   "negative float",
+			input:  []byte{0x82, 0x40, 0x0c, 0xff},
+			expect: Raw{0x82, 0x40, 0x0c},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var actual Raw
+			n, err := Dec(tc.input, &actual)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(len(tc.expect), n)
+			assert.Equal(tc.expect, actual)
+		})
+	}
+
+	t.Run("copies bytes", func(t *testing.T) {
+		assert := assert.New(t)
+		input := []byte{0x41, 0x82, 0x02, 0x68, 0x69}
+
+		var actual Raw
+		_, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+		input[3] = 0x00
+
+		assert.Equal(Raw{0x41, 0x82, 0x02, 0x68, 0x69}, actual)
+	})
+
+	t.Run("nil to *Raw", func(t *testing.T) {
+		assert := assert.New(t)
+
+		actual := &Raw{0x00}
+		n, err := Dec([]byte{0xa0}, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(1, n)
+		assert.Nil(actual)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual Raw
+		_, err := Dec([]byte{0x01, 0x04, 0x01, 0x01}, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+}
+
+func Test_Dec_Raw_TopLevelNumber(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     []byte
+		expect    Raw
+		expectErr error
+	}{
+		{
+			name:      "int followed by other data",
+			input:     append(MustEnc(5), 9, 9, 9, 9, 9, 9),
+			expectErr: ErrInvalidType,
+		},
+		{
+			name:      "bool followed by zero",
+			input:     append(MustEnc(true), 0x00, 0xff),
+			expectErr: ErrInvalidType,
+		},
+		{
+			name:      "int alone",
+			input:     MustEnc(5),
+			expectErr: ErrMalformedData,
+		},
+		{
+			name:   "slice of ints followed by other data",
+			input:  append(MustEnc([]int{1, 300}), 9, 9, 9),
+			expect: Raw(MustEnc([]int{1, 300})),
+		},
+		{
+			name:   "map followed by other data",
+			input:  append(MustEnc(map[string]int{"a": 1}), 9, 9, 9),
+			expect: Raw(MustEnc(map[string]int{"a": 1})),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var actual Raw
+			n, err := Dec(tc.input, &actual)
+			if tc.expectErr != nil {
+				assert.ErrorIs(err, tc.expectErr)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(len(tc.expect), n)
+			assert.Equal(tc.expect, actual)
+		})
+	}
+
+	t.Run("to *Raw", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual *Raw
+		_, err := Dec(append(MustEnc(5), 9, 9, 9, 9, 9, 9), &actual)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+}
+
+func Test_Dec_Raw_NumberField(t *testing.T) {
+	type rawFirst struct {
+		X Raw
+		Y int
+	}
+	type rawLast struct {
+		A int
+		X Raw
+	}
+
+	testCases := []struct {
+		name         string
+		input        []byte
+		recv         interface{}
+		expectOffset int
+	}{
+		{
+			name:         "misreads next field name",
+			input:        MustEnc(struct{ X, Y int }{5, 6}),
+			recv:         &rawFirst{},
+			expectOffset: 6,
+		},
+		{
+			name:         "runs past end of struct",
+			input:        MustEnc(struct{ A, X int }{1, 200}),
+			recv:         &rawLast{},
+			expectOffset: 12,
+		},
+		{
+			name:  "float runs past end of struct",
+			input: MustEnc(struct{ X, Y float64 }{3.5, 1}),
+			recv: &struct {
+				X Raw
+				Y float64
+			}{},
+			expectOffset: 6,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := Dec(tc.input, tc.recv)
+			if !assert.ErrorIs(err, ErrInvalidType) {
+				return
+			}
+			assert.Contains(err.Error(), ".X: ")
+
+			var decErr *DecodeError
+			if assert.ErrorAs(err, &decErr) {
+				assert.Equal(tc.expectOffset, decErr.Offset)
+				assert.Equal(FieldPath{{Kind: reflect.Struct, Field: "X"}}, decErr.Path)
+			}
+		})
+	}
+
+	t.Run("negative number", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual rawFirst
+		_, err := Dec(MustEnc(struct{ X, Y int }{-5, 6}), &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(Raw(MustEnc(-5)), actual.X)
+		assert.Equal(6, actual.Y)
+	})
+}
+
+func Test_Raw_PassThrough(t *testing.T) {
+	t.Run("deferred decode", func(t *testing.T) {
+		assert := assert.New(t)
+		body := map[string]int{"a": 1, "b": 2}
+		input := MustEnc(struct {
+			Kind string
+			Body map[string]int
+		}{Kind: "counts", Body: body})
+
+		var env testRawEnvelope
+		n, err := Dec(input, &env)
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(len(input), n)
+		assert.Equal("counts", env.Kind)
+
+		var actual map[string]int
+		_, err = Dec(env.Body, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal(body, actual)
+	})
+
+	t.Run("re-encoded unchanged", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(struct {
+			Kind string
+			Body []string
+		}{Kind: "names", Body: []string{"Karkat", "Terezi"}})
+
+		var env testRawEnvelope
+		_, err := Dec(input, &env)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(input, MustEnc(env))
+	})
+
+	t.Run("Writer and Reader", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, nil)
+		if !assert.NoError(err) {
+			return
+		}
+		if !assert.NoError(w.Enc(Raw(MustEnc("Vriska")))) {
+			return
+		}
+		if !assert.NoError(w.Enc(Raw(MustEnc([]int{8, 8})))) {
+			return
+		}
+		if !assert.NoError(w.Close()) {
+			return
+		}
+
+		r, err := NewReader(&buf, nil)
+		if !assert.NoError(err) {
+			return
+		}
+		var first, second Raw
+		if !assert.NoError(r.Dec(&first)) {
+			return
+		}
+		if !assert.NoError(r.Dec(&second)) {
+			return
+		}
+
+		assert.Equal(Raw(MustEnc("Vriska")), first)
+		assert.Equal(Raw(MustEnc([]int{8, 8})), second)
+	})
+}
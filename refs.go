@@ -0,0 +1,345 @@
+package rezi
+
+// refs.go contains functions for tracking shared references within a single
+// encoded value.
+
+import (
+	"reflect"
+)
+
+// session holds the state of a single top-level encode or decode. Its methods
+// may be called on a nil *session, which behaves as a session with the default
+// Format.
+type session struct {
+	f Format
+
+	// encRefs maps the identity of every trackable value encoded so far to the
+	// index it was assigned.
+	encRefs map[refKey]int
+
+	// encCount is the number of trackable values encoded so far, including
+	// those that have no identity and so are not in encRefs.
+	encCount int
+
+	// decRefs holds every trackable value decoded so far, at the index it was
+	// assigned. An entry is the zero Value if its value has not yet been
+	// completely decoded.
+	decRefs []reflect.Value
+
+	// pendingMap is the index in decRefs of the map that is about to be
+	// created by decMap. It is only valid if hasPendingMap is set.
+	pendingMap    int
+	hasPendingMap bool
+
+	// names is the field-name table of the session. It is created when first
+	// needed unless it is given by the Writer or Reader the session is for.
+	names *nameTable
+
+	// fieldSet receives the fields of every struct that is decoded, if it is
+	// set. path is the steps taken from the top-level value to the value
+	// currently being decoded; it is only kept while fieldSet is set.
+	fieldSet *FieldSet
+	path     []PathStep
+
+	// mask selects the fields of the structs that are encoded. It is nil if
+	// every field is encoded.
+	mask fieldMask
+
+	// visiting holds the identity of every trackable value that is being
+	// encoded without reference tracking, so that a value that is within
+	// itself is found instead of being encoded forever.
+	visiting map[refKey]bool
+
+	// depth is the number of values that are being decoded, including the
+	// current one. It is 1 while the top-level value is being decoded.
+	depth int
+}
+
+// refKey uniquely identifies the data a trackable value refers to.
+type refKey struct {
+	ptr uintptr
+	len int
+	t   reflect.Type
+}
+
+func newSession(f *Format) *session {
+	s := &session{}
+	if f != nil {
+		s.f = *f
+	}
+	return s
+}
+
+// packing returns the Packing to use for encoding slices and arrays whose type
+// info does not give one.
+func (s *session) packing() Packing {
+	if s == nil {
+		return PackNone
+	}
+	return s.f.Packing
+}
+
+// decodingTopLevel returns whether the value being decoded is the top-level
+// value, and so is not within any counted value that bounds its data.
+func (s *session) decodingTopLevel() bool {
+	return s != nil && s.depth == 1
+}
+
+func (s *session) trackingRefs() bool {
+	return s != nil && s.f.TrackReferences
+}
+
+// omittingEmpty returns whether every struct field that is empty is left out
+// of the encoding.
+func (s *session) omittingEmpty() bool {
+	return s != nil && s.f.OmitEmpty
+}
+
+// tablingNames returns whether struct field names are written to the
+// field-name table of the session rather than with each struct.
+func (s *session) tablingNames() bool {
+	return s != nil && s.f.FieldNameTable
+}
+
+// nameTable returns the field-name table of the session, creating it if
+// needed. It returns nil if s is nil.
+func (s *session) nameTable() *nameTable {
+	if s == nil {
+		return nil
+	}
+	if s.names == nil {
+		s.names = newNameTable()
+	}
+	return s.names
+}
+
+// stateless returns a session that has the same Format as s but with every
+// option that makes an encoding depend on what was encoded before it disabled,
+// which are reference tracking and the field-name table.
+func (s *session) stateless() *session {
+	if s == nil {
+		return nil
+	}
+	f := s.f
+	f.TrackReferences = false
+	f.FieldNameTable = false
+	return newSession(&f)
+}
+
+// claimPendingMap sets m as the value of the map being decoded, if one is
+// pending.
+func (s *session) claimPendingMap(m reflect.Value) {
+	if s == nil || !s.hasPendingMap {
+		return
+	}
+	s.decRefs[s.pendingMap] = m
+	s.hasPendingMap = false
+}
+
+// refTarget returns the value that is used to identify v for the purposes of
+// reference tracking. For pointers, this is the innermost pointer. If v does
+// not refer to any data, ok will be false.
+func refTarget(v reflect.Value, info typeInfo) (target reflect.Value, ok bool) {
+	if !v.IsValid() {
+		return v, false
+	}
+
+	if info.Indir > 0 {
+		for i := 0; i < info.Indir-1; i++ {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+	}
+
+	if v.IsNil() {
+		return v, false
+	}
+
+	// a pointer to a nil value is encoded as a nil pointer, so it must not be
+	// tracked either.
+	if info.Indir > 0 {
+		switch v.Elem().Kind() {
+		case reflect.Map, reflect.Slice, reflect.Pointer, reflect.Interface:
+			if v.Elem().IsNil() {
+				return v, false
+			}
+		}
+	}
+	return v, true
+}
+
+// encTracked encodes a trackable value. The first time the data referred to
+// by v is encountered, it is encoded normally and assigned the next index; each
+// subsequent time, only a reference to that index is encoded.
+func encTracked(v interface{}, info typeInfo, sess *session) ([]byte, error) {
+	target, ok := refTarget(reflect.ValueOf(v), info)
+	if !ok {
+		return encDispatch(v, info, sess)
+	}
+
+	// empty slices do not refer to any data, but still take up an index so
+	// that the decoder, which cannot tell them apart from others until after
+	// they are decoded, stays in sync.
+	keyed := info.Indir > 0 || info.Main != mtSlice || target.Len() > 0
+	var key refKey
+	if keyed {
+		key = refKey{ptr: target.Pointer(), t: target.Type()}
+		if info.Indir == 0 && info.Main == mtSlice {
+			key.len = target.Len()
+		}
+
+		if idx, seen := sess.encRefs[key]; seen {
+			return encCount(idx, &countHeader{Reference: true}), nil
+		}
+		if sess.encRefs == nil {
+			sess.encRefs = map[refKey]int{}
+		}
+		sess.encRefs[key] = sess.encCount
+	}
+	sess.encCount++
+
+	if info.Indir > 0 {
+		// the pointer is now tracked; encode the pointed-to value as its own
+		// trackable value.
+		contentInfo := info
+		contentInfo.Indir = 0
+		return encWithTypeInfo(target.Elem().Interface(), contentInfo, sess)
+	}
+	return encDispatch(v, info, sess)
+}
+
+// enterUntracked records that the trackable value v with type info info is
+// about to be encoded without reference tracking. If v refers to the same data
+// as a value that it is being encoded within, v contains a cycle and an error
+// matching ErrInvalidType is returned. Otherwise, the returned function must be
+// called once v has been encoded.
+func (s *session) enterUntracked(v interface{}, info typeInfo) (leave func(), err error) {
+	target, ok := refTarget(reflect.ValueOf(v), info)
+	if s == nil || !ok {
+		return func() {}, nil
+	}
+
+	key := refKey{ptr: target.Pointer(), t: target.Type()}
+	if info.Indir == 0 && info.Main == mtSlice {
+		key.len = target.Len()
+	}
+	if s.visiting[key] {
+		const errFmt = "value of type %s contains a cycle; enable TrackReferences in the Format to encode it"
+		return nil, errorf(errFmt, target.Type()).wrap(ErrInvalidType)
+	}
+
+	if s.visiting == nil {
+		s.visiting = map[refKey]bool{}
+	}
+	s.visiting[key] = true
+	return func() { delete(s.visiting, key) }, nil
+}
+
+// decTracked decodes a trackable value, resolving it to a previously-decoded
+// one if data holds a reference.
+func decTracked(data []byte, v interface{}, info typeInfo, sess *session) (int, error) {
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return 0, errorDecf(0, "check count header: %s", err)
+	}
+	if hdr.v.IsNil() {
+		return decDispatch(data, v, info, sess)
+	}
+
+	refVal := reflect.ValueOf(v)
+
+	// the type of value held in decRefs for this receiver; for pointers,
+	// that's the innermost pointer type.
+	heldType := refVal.Type().Elem()
+	for i := 1; i < info.Indir; i++ {
+		heldType = heldType.Elem()
+	}
+
+	if hdr.v.Reference {
+		idx, err := decInt[int](data)
+		if err != nil {
+			return 0, errorDecf(0, "decode reference index: %s", err)
+		}
+		if idx.v < 0 || idx.v >= len(sess.decRefs) {
+			return 0, errorDecf(0, "reference to undecoded value %d", idx.v).wrap(ErrMalformedData)
+		}
+		held := sess.decRefs[idx.v]
+		if !held.IsValid() {
+			return 0, errorDecf(0, "reference to value %d, which is not yet completely decoded", idx.v).wrap(ErrMalformedData)
+		}
+		if held.Kind() != heldType.Kind() || !held.Type().ConvertibleTo(heldType) {
+			return 0, errorDecf(0, "reference to value %d of type %s cannot be assigned to %s", idx.v, held.Type(), heldType).wrap(ErrMalformedData)
+		}
+		assignTracked(refVal, held.Convert(heldType), info.Indir)
+		return idx.n, nil
+	}
+
+	idx := len(sess.decRefs)
+	sess.decRefs = append(sess.decRefs, reflect.Value{})
+
+	if info.Indir > 0 {
+		// allocate the pointer first so that anything within the pointed-to
+		// value can refer back to it.
+		target := reflect.New(heldType.Elem())
+		if info.Main == mtStruct || info.Main.Unmarshaled() || sess.merging() != MergeNone {
+			// preserve the original value, same as for untracked decoding.
+			if orig, ok := refTarget(refVal.Elem(), info); ok {
+				target.Elem().Set(orig.Elem())
+			}
+		}
+		sess.decRefs[idx] = target
+
+		contentInfo := info
+		contentInfo.Indir = 0
+		n, err := decWithTypeInfo(data, target.Interface(), contentInfo, sess)
+		if err != nil {
+			return n, err
+		}
+		assignTracked(refVal, target, info.Indir)
+		return n, nil
+	}
+
+	if info.Main == mtMap {
+		sess.pendingMap = idx
+		sess.hasPendingMap = true
+	}
+	n, err := decDispatch(data, v, info, sess)
+	sess.hasPendingMap = false
+	if err != nil {
+		return n, err
+	}
+	sess.decRefs[idx] = refVal.Elem()
+	return n, nil
+}
+
+// assignTracked assigns held to the receiver pointed to by refVal, allocating
+// any pointers needed between them. For pointers, held must be the innermost
+// pointer.
+func assignTracked(refVal reflect.Value, held reflect.Value, indir int) {
+	assignTarget := refVal
+	for i := 1; i < indir; i++ {
+		newTarget := reflect.New(assignTarget.Type().Elem().Elem())
+		assignTarget.Elem().Set(newTarget)
+		assignTarget = newTarget
+	}
+	assignTarget.Elem().Set(held)
+}
+
+// checkNotReference returns an error if data begins with a reference, which
+// can only be decoded with reference tracking enabled.
+func checkNotReference(data []byte) error {
+	if len(data) < 1 || data[0]&infoBitsExt == 0 {
+		return nil
+	}
+
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return errorDecf(0, "check count header: %s", err)
+	}
+	if hdr.v.Reference {
+		return errorDecf(0, "data contains a reference but reference tracking is not enabled").wrap(ErrMalformedData)
+	}
+	return nil
+}
@@ -0,0 +1,495 @@
+package rezi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testRefNode struct {
+	Name string
+	Next *testRefNode
+}
+
+type testRefConfig struct {
+	Level int
+}
+
+type testRefShared struct {
+	A *testRefConfig
+	B *testRefConfig
+}
+
+type testRefGraph struct {
+	Nodes map[string]*testRefGraphNode
+}
+
+type testRefGraphNode struct {
+	Value int
+	Graph map[string]*testRefGraphNode
+}
+
+type testRefTree struct {
+	Children []*testRefTree
+	Siblings []*testRefTree
+}
+
+func Test_EncWithFormat_References(t *testing.T) {
+	trackRefs := &Format{TrackReferences: true}
+
+	t.Run("no tracking", func(t *testing.T) {
+		assert := assert.New(t)
+
+		x := 1
+		input := []*int{&x, &x}
+		expect := []byte{
+			0x01, 0x04, // len=4
+
+			0x01, 0x01, // 1
+
+			0x01, 0x01, // 1
+		}
+
+		actual, err := EncWithFormat(input, nil)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("cycle without tracking", func(t *testing.T) {
+		assert := assert.New(t)
+
+		node := &testRefNode{Name: "a"}
+		node.Next = &testRefNode{Name: "b", Next: node}
+
+		_, err := EncWithFormat(node, nil)
+		assert.ErrorIs(err, ErrInvalidType)
+		assert.ErrorContains(err, "TrackReferences")
+	})
+
+	t.Run("cycle through map without tracking", func(t *testing.T) {
+		assert := assert.New(t)
+
+		node := &testRefGraphNode{Value: 1}
+		node.Graph = map[string]*testRefGraphNode{"self": node}
+
+		_, err := EncWithFormat(node, nil)
+		assert.ErrorIs(err, ErrInvalidType)
+		assert.ErrorContains(err, "TrackReferences")
+	})
+
+	t.Run("shared pointer", func(t *testing.T) {
+		assert := assert.New(t)
+
+		x := 1
+		input := []*int{&x, &x}
+		expect := []byte{
+			0x01, 0x05, // len=5
+
+			0x01, 0x01, // 1
+
+			0x41, 0x20, 0x01, // ref=1
+		}
+
+		actual, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("distinct pointers with equal values", func(t *testing.T) {
+		assert := assert.New(t)
+
+		x := 1
+		y := 1
+		input := []*int{&x, &y}
+		expect := []byte{
+			0x01, 0x04, // len=4
+
+			0x01, 0x01, // 1
+
+			0x01, 0x01, // 1
+		}
+
+		actual, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("shared map", func(t *testing.T) {
+		assert := assert.New(t)
+
+		m := map[string]bool{"a": true}
+		input := []map[string]bool{m, m}
+		expect := []byte{
+			0x01, 0x0a, // len=10
+
+			0x01, 0x05, // len=5
+			0x41, 0x82, 0x01, 0x61, // "a"
+			0x01, // true
+
+			0x41, 0x20, 0x01, // ref=1
+		}
+
+		actual, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("nil pointers are not tracked", func(t *testing.T) {
+		assert := assert.New(t)
+
+		x := 1
+		input := []*int{nil, &x, nil, &x}
+		expect := []byte{
+			0x01, 0x07, // len=7
+
+			0xa0, // nil
+
+			0x01, 0x01, // 1
+
+			0xa0, // nil
+
+			0x41, 0x20, 0x01, // ref=1
+		}
+
+		actual, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := &testRefNode{Name: "a"}
+		input.Next = input
+		expect := []byte{
+			0x01, 0x14, // len=20
+
+			0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
+			0x41, 0x82, 0x01, 0x61, // "a"
+
+			0x41, 0x82, 0x04, 0x4e, 0x65, 0x78, 0x74, // "Next"
+			0x40, 0x20, // ref=0
+		}
+
+		actual, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+}
+
+func Test_DecWithFormat_References(t *testing.T) {
+	trackRefs := &Format{TrackReferences: true}
+
+	t.Run("shared pointer", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{
+			0x01, 0x05, // len=5
+
+			0x01, 0x01, // 1
+
+			0x41, 0x20, 0x01, // ref=1
+		}
+
+		var actual []*int
+		n, err := DecWithFormat(input, &actual, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(input), n)
+		if !assert.Len(actual, 2) {
+			return
+		}
+		assert.Equal(1, *actual[0])
+		assert.Same(actual[0], actual[1])
+	})
+
+	t.Run("reference without tracking", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{
+			0x01, 0x05, // len=5
+
+			0x01, 0x01, // 1
+
+			0x41, 0x20, 0x01, // ref=1
+		}
+
+		var actual []*int
+		_, err := DecWithFormat(input, &actual, nil)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("reference to unknown index", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{
+			0x01, 0x05, // len=5
+
+			0x01, 0x01, // 1
+
+			0x41, 0x20, 0x08, // ref=8
+		}
+
+		var actual []*int
+		_, err := DecWithFormat(input, &actual, trackRefs)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("reference to incomplete slice", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{
+			0x01, 0x02, // len=2
+
+			0x40, 0x20, // ref=0
+		}
+
+		var actual [][]int
+		_, err := DecWithFormat(input, &actual, trackRefs)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+}
+
+func Test_EncDecWithFormat_References(t *testing.T) {
+	trackRefs := &Format{TrackReferences: true}
+
+	t.Run("shared struct pointer", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cfg := &testRefConfig{Level: 8}
+		input := testRefShared{A: cfg, B: cfg}
+
+		data, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testRefShared
+		n, err := DecWithFormat(data, &actual, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(data), n)
+		assert.Equal(input, actual)
+		assert.Same(actual.A, actual.B)
+	})
+
+	t.Run("shared struct pointer without tracking", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cfg := &testRefConfig{Level: 8}
+		input := testRefShared{A: cfg, B: cfg}
+
+		data, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testRefShared
+		_, err = Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(input, actual)
+		assert.NotSame(actual.A, actual.B)
+	})
+
+	t.Run("recursive type without cycle", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := &testRefNode{Name: "a", Next: &testRefNode{Name: "b"}}
+
+		data, err := Enc(input)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual *testRefNode
+		_, err = Dec(data, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(input, actual)
+	})
+
+	t.Run("pointer cycle", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := &testRefNode{Name: "a"}
+		input.Next = &testRefNode{Name: "b", Next: input}
+
+		data, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual *testRefNode
+		_, err = DecWithFormat(data, &actual, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("a", actual.Name)
+		assert.Equal("b", actual.Next.Name)
+		assert.Same(actual, actual.Next.Next)
+	})
+
+	t.Run("map cycle", func(t *testing.T) {
+		assert := assert.New(t)
+
+		nodes := map[string]*testRefGraphNode{}
+		nodes["x"] = &testRefGraphNode{Value: 1, Graph: nodes}
+		nodes["y"] = &testRefGraphNode{Value: 2, Graph: nodes}
+		input := testRefGraph{Nodes: nodes}
+
+		data, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual testRefGraph
+		_, err = DecWithFormat(data, &actual, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		if !assert.Len(actual.Nodes, 2) {
+			return
+		}
+		assert.Equal(1, actual.Nodes["x"].Value)
+		assert.Equal(2, actual.Nodes["y"].Value)
+
+		// maps cannot be compared for identity directly, so mutate one and
+		// check the other.
+		actual.Nodes["z"] = nil
+		assert.Contains(actual.Nodes["x"].Graph, "z")
+		assert.Contains(actual.Nodes["y"].Graph, "z")
+	})
+
+	t.Run("shared slice", func(t *testing.T) {
+		assert := assert.New(t)
+
+		children := []*testRefTree{{}, {}}
+		children[0].Siblings = children
+		children[1].Siblings = children
+		input := &testRefTree{Children: children}
+
+		data, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual *testRefTree
+		_, err = DecWithFormat(data, &actual, trackRefs)
+		assert.ErrorIs(err, ErrMalformedData, "slice referring to itself must not be decodable")
+
+		// but sharing that is not a cycle is fine
+		input = &testRefTree{Children: []*testRefTree{{}}}
+		input.Siblings = input.Children
+
+		data, err = EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		actual = nil
+		_, err = DecWithFormat(data, &actual, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		if !assert.Len(actual.Siblings, 1) {
+			return
+		}
+		assert.Same(actual.Children[0], actual.Siblings[0])
+	})
+
+	t.Run("pointer map keys", func(t *testing.T) {
+		assert := assert.New(t)
+
+		k1 := "a"
+		k2 := "b"
+		input := struct {
+			M map[*string]int
+			K *string
+		}{M: map[*string]int{&k1: 1, &k2: 2}, K: &k2}
+
+		data, err := EncWithFormat(input, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		var actual struct {
+			M map[*string]int
+			K *string
+		}
+		_, err = DecWithFormat(data, &actual, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+
+		if !assert.Len(actual.M, 2) {
+			return
+		}
+		assert.Equal("b", *actual.K)
+		assert.Equal(2, actual.M[actual.K])
+	})
+
+	t.Run("writer and reader", func(t *testing.T) {
+		assert := assert.New(t)
+
+		cfg := &testRefConfig{Level: 8}
+		input := testRefShared{A: cfg, B: cfg}
+
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+		if !assert.NoError(w.Enc(input)) {
+			return
+		}
+		if !assert.NoError(w.Close()) {
+			return
+		}
+
+		r, err := NewReader(&buf, trackRefs)
+		if !assert.NoError(err) {
+			return
+		}
+		var actual testRefShared
+		if !assert.NoError(r.Dec(&actual)) {
+			return
+		}
+
+		assert.Equal(input, actual)
+		assert.Same(actual.A, actual.B)
+	})
+}
@@ -114,6 +114,17 @@
 // See the individual functions for a list of error types that returned errors
 // may be checked against.
 //
+// Errors caused by a problem with the data being decoded can additionally be
+// converted to a [DecodeError] with [errors.As]. It gives the offset of the
+// problem, the path of struct fields, slice and array indexes, and map keys that
+// lead from the top-level value to the value that could not be decoded, and the
+// type of that value:
+//
+//	var decErr *rezi.DecodeError
+//	if errors.As(err, &decErr) {
+//		fmt.Printf("bad %s at %v\n", decErr.Type, decErr.Path)
+//	}
+//
 // # Supported Data Types
 //
 // REZI supports all built-in basic Go types: int (as well as all of its
@@ -126,17 +137,35 @@
 // a REZI function called from within UnmarshalBinary to supply additional
 // offset information, but this is not strictly required.
 //
+// Types that implement [Marshaler] or [Unmarshaler] are also supported, and
+// their methods are used in preference to any other marshaling methods. They
+// write to and read from a [Writer] and [Reader] that are part of the
+// surrounding encode or decode, so errors returned by the Reader already
+// include their full offset and need no [Wrapf]. These methods can be
+// generated for struct types with the rezigen command, found in the
+// cmd/rezigen directory of this module; see its documentation for details.
+//
 // Slices, arrays, and maps are supported with some stipulations. Slices and
 // arrays must contain only other supported types (or pointers to them). Maps
 // have the same restrictions on their values, but only maps with a key type of
 // string, int (or any of its unsigned or specific-size varieties), float32,
-// float64, or bool are supported.
+// float64, complex64, complex128, bool, or a type that implements
+// encoding.TextMarshaler are supported. Additionally, maps may be keyed by a
+// struct or array type that is itself made up only of those key types (or of
+// other such structs or arrays), without any pointers; this allows maps keyed
+// by coordinate pairs such as struct{X, Y int} or by fixed-size IDs such as
+// [16]byte.
 //
 // Pointers to any supported type are also accepted, including to other pointer
 // types with any number of indirections. The REZI format encodes information on
-// how many levels of indirection are valid, though of course note that it does
-// not have any concept of two different pointer variables pointing to the same
-// data.
+// how many levels of indirection are valid. By default, it does not have any
+// concept of two different pointer variables pointing to the same data; each
+// is encoded separately and will be decoded to separate copies, and encoding
+// a value that contains a cycle results in an error that matches
+// [ErrInvalidType]. Enabling TrackReferences in the [Format] passed to
+// [EncWithFormat] and [DecWithFormat] (or to [NewWriter] and [NewReader])
+// changes this so that data shared by pointers, maps, and slices is encoded
+// only once and remains shared when decoded.
 //
 // All non-struct types whose underlying type is a supported type are themselves
 // supported as well. For example, time.Duration has an underlying type of
@@ -146,7 +175,11 @@
 // marshaling functions, provided all of their exported fields are of a
 // supported type. Both decoding and encoding ignore all unexported fields. If a
 // field is not present in the given bytes during decoding, its original value
-// is left intact, even if it is exported.
+// is left intact, even if it is exported. The exported fields of embedded
+// structs are promoted to the outer struct using the same rules as
+// encoding/json. As with encoding/json, decoding a field promoted through a nil
+// embedded pointer to an unexported struct type causes an error, as the pointer
+// cannot be allocated.
 //
 // # Binary Data Format
 //
@@ -180,7 +213,9 @@
 //
 // The "X" bit is the extension flag, and indicates that the next byte is a
 // second info byte with additional information, called the info extension byte.
-// At this time, only encoded string values use this extension byte.
+// It is used by strings, by slices, arrays, and structs encoded in any form
+// other than the original one, by numbers written with their kind, and by
+// references and recorded array lengths.
 //
 // The "N" bit is the explicit nil flag, and when set it indicates that the
 // value is a nil and that there are no following bytes in the encoded value
@@ -203,7 +238,7 @@
 //
 //	Layout:
 //
-//	BXUUVVVV
+//	BXRAVVVV
 //	|      |
 //	MSB  LSB
 //
@@ -221,13 +256,34 @@
 // byte-based without an EXT byte in its layout diagram will be assumed to have
 // a byte-based length.
 //
+// The "R" bit is the reference flag. If this is set, the int that follows is not
+// a count but the index of a previously-encoded value that the encoded value
+// refers to. It is only present in data encoded with reference tracking; see
+// the section on references below.
+//
 // The "V" bits make up the version field of the extension byte. This indicates
 // the version of encoding of the particular type that is represented, encoded
 // as a 4-bit unsigned integer. If not present (all 0's, or the EXT byte itself
-// is not present), it is assumed to be 1. This version number is purely
-// informative and does not affect decoding in any way.
-//
-// The "U" bits are unused at this time and are reserved for future use.
+// is not present), it is assumed to be 1 for every type other than numbers. The
+// version selects how the rest of the value is decoded:
+//
+//   - Strings: version 2 gives the length of the string in bytes; version 1
+//     gives it in runes.
+//   - Slices and arrays: version 2 is a raw byte sequence and version 3 is
+//     packed numbers.
+//   - Structs: version 2 refers to field names by their index in a field-name
+//     table, version 3 gives numbered fields, and version 4 is a tuple.
+//   - Integers and floats: version 1, 2, or 3 marks the number as a signed
+//     integer, an unsigned integer, or a float. A number with no version is
+//     unmarked, and is decoded as the kind of number it is decoded to.
+//
+// Each of these is described in the section for its type below.
+//
+// The "A" bit is the array length flag. If this is set, the count is followed by
+// an int giving the length of the array that was encoded, and the count
+// includes the bytes of that int. It is only present in data encoded with
+// [Format.ArrayLengths] enabled. A value whose array length does not match the
+// length of the array it is decoded to cannot be decoded.
 //
 //	Bool Values
 //
@@ -268,7 +324,20 @@
 // All Go integer types are encoded in the same way. This includes int, int8,
 // int16, int32, int64, uint, uint8, uint16, uint32, and uint64. The specific
 // interpretation into a value is handled at decoding time by infering the type
-// from the pointer passed to Enc.
+// from the pointer passed to Enc. Unsigned integers are never encoded as
+// negative; a uint64 above the maximum int64 is encoded with all 8 of its bytes
+// and the S bit clear. A value that does not fit in the type it is decoded to,
+// including a negative value decoded to an unsigned type, results in an error
+// matching [ErrRange].
+//
+// Integers and floats that are written with the kind of number they are, as
+// selected with [Format.ConvertNumbers] or with the was option of a rezi
+// struct tag, have an EXT byte after their INFO byte. Its version gives the
+// kind of number: 1 for signed integers, 2 for unsigned integers, and 3 for
+// floats. The rest of the encoded number is unchanged. A number without an EXT
+// byte is not marked with any kind; it is taken to be of the kind given by the
+// was option of the field it is decoded to, if there is one, and otherwise of
+// the kind of the type it is decoded to.
 //
 //	Float Values
 //
@@ -375,6 +444,10 @@
 // UTF-8. Non-empty strings will use the full layout; an empty string will use
 // the abbreviated short-form layout.
 //
+// The bytes of a string are written exactly as they are held by the Go string,
+// so a string that is not valid UTF-8 keeps its invalid bytes. How such
+// strings are handled can be changed with [Format.InvalidUTF8].
+//
 // A non-empty string value's first info byte will have its extension bit set
 // and will indicate explicitly that it uses a byte-based count in the
 // extension byte that follows. This is to distinguish it from older-style (V0)
@@ -431,6 +504,78 @@
 // The encoded names are placed in a consistent order; encoding the same struct
 // will result in the same encoding.
 //
+// Fields with the omitempty option in their rezi struct tag, such as
+// `rezi:",omitempty"`, are left out of the encoding entirely when they are
+// empty, as are all empty fields when [Format.OmitEmpty] is enabled. A field is
+// empty if it is the zero value of its type or if it is a string, slice, or map
+// with a length of 0. Fields that are required or have a default are never
+// left out because of Format.OmitEmpty, and cannot have the omitempty option.
+//
+//	Struct Values With a Field-Name Table
+//
+//	Layout:
+//
+//	[ INFO ] [ EXT ] [ INT VALUE ] [ NAMES ] [ VALUE 1 ] ... [ VALUE N ]
+//	<----------COUNT-----------> <--------------VALUES--------------->
+//	        2..10 bytes                       COUNT bytes
+//
+// When [Format.FieldNameTable] is enabled, structs with at least one field are
+// instead encoded with an EXT byte that gives a version of 2, and the names of
+// the fields are given once, before all of the values, as a reference to an
+// entry in a table of field-name sets. The table starts out empty at the start
+// of each encoded value, or at the start of the stream for values written by a
+// [Writer].
+//
+// The NAMES reference of a struct whose set of field names is not yet in the
+// table defines a new entry at the next index. It is encoded as an integer
+// giving the bitwise complement of that index (which is always negative),
+// followed by an integer giving the number of names and then each name encoded
+// as a string. The NAMES reference of a struct whose set of names is already in
+// the table is encoded as an integer giving the index of the entry. Either
+// way, the values of the fields follow in the order of the names, without any
+// delimiter.
+//
+//	Struct Values With Numbered Fields
+//
+//	Layout:
+//
+//	[ INFO ] [ EXT ] [ INT VALUE ] [ KEY 1 ] [ VALUE 1 ] ... [ KEY N ] [ VALUE N ]
+//	<----------COUNT-----------> <------------------VALUES------------------->
+//	        2..10 bytes                           COUNT bytes
+//
+// Structs that have at least one field with an ID, given with the id option of
+// a rezi struct tag such as `rezi:",id=3"`, are encoded with an EXT byte that
+// gives a version of 3. Each field with an ID is keyed by an integer instead of
+// by its name. The key is the ID shifted left by 2 bits, with the low 2 bits
+// giving how the length of the encoded value can be found: 0 if the INFO byte of
+// the value gives the number of bytes that follow it (integers and floats), 1 if
+// the value is a single byte (bools), and 2 if the value is counted (all other
+// types). Nil values and references are given by their header alone in every
+// case. This allows the values of fields with an ID that is not in the
+// decoded-to struct to be skipped. Fields without an ID are keyed by their name
+// as in other structs; names are always encoded with an EXT byte and keys never
+// are, which is how the two are told apart.
+//
+//	Tuple Struct Values
+//
+//	Layout:
+//
+//	[ INFO ] [ EXT ] [ INT VALUE ] [ INT FIELDS ] [ INT FINGERPRINT ] [ VALUE 1 ] ... [ VALUE N ]
+//	<----------COUNT-----------> <---------------------------VALUES--------------------------->
+//	        2..10 bytes                                   COUNT bytes
+//
+// Structs that have a blank field with the tuple option, such as a field
+// declared as "_ struct{}" with the tag `rezi:",tuple"`, are encoded with an
+// EXT byte that gives a version of 4. The count of bytes is followed by an
+// integer giving the number of fields, then by an integer fingerprint of the
+// fields, and then by the value of each field in the order the fields are
+// declared, without any names. The fingerprint is the CRC-32 (IEEE) checksum of
+// the name and the kind of value of each field in order, so that it changes if
+// the fields are renamed, reordered, or changed to a kind of value that cannot
+// be decoded from the old one. Tuple structs can only be decoded to a tuple
+// struct with the same number of fields and the same fingerprint; decoding to
+// any other struct results in an error.
+//
 //	Slice Values
 //
 //	Layout:
@@ -453,6 +598,47 @@
 // Arrays are encoded in an identical fashion to slices. They do not record the
 // size of the array type.
 //
+//	Byte Slice and Array Values
+//
+//	Layout:
+//
+//	[ INFO ] [ EXT ] [ INT VALUE ] [ BYTE 1 ] ... [ BYTE N ]
+//	<---------COUNT------------> <--------VALUES--------->
+//	        2..10 bytes                 COUNT bytes
+//
+// Slices and arrays whose element type has an underlying type of byte are
+// encoded as a count of bytes, followed by the bytes themselves with no
+// per-item header. The EXT byte gives a version of 2 to distinguish this from
+// the general slice encoding. An empty byte slice is encoded as the single
+// byte 0x00, the same as any other empty slice.
+//
+//	Packed Slice and Array Values
+//
+//	Layout:
+//
+//	[ INFO ] [ EXT ] [ INT VALUE ] [ PACKING ] [ PACKED DATA ]
+//	<---------COUNT------------> <---------VALUES--------->
+//	        2..10 bytes                 COUNT bytes
+//
+// Slices and arrays of integers or floats may be encoded in packed form, as
+// selected with [Format.Packing] or with the packed option of a rezi struct
+// tag. Packed values are encoded as a count of bytes, followed by a single
+// packing byte and then the packed numbers. The EXT byte gives a version of 3.
+//
+// The packing byte has the layout WWKKPPPP. The "P" bits give the [Packing]
+// as a 4-bit unsigned integer. The "K" bits give the kind of number: 0 for
+// signed integers, 1 for unsigned integers, and 2 for floats. The "W" bits
+// give the base-2 logarithm of the number of bytes in each number when the
+// packing is PackFixed.
+//
+// With PackFixed, each number is given as a big-endian integer of exactly the
+// width in the packing byte; floats are given as their IEEE-754 bits. With
+// PackVarint, each integer is given as a base-128 varint as defined by the
+// encoding/binary package, with zig-zag encoding applied to signed integers.
+// With PackDelta, the first integer is given as a zig-zag varint, and every
+// integer after it is given as the zig-zag varint of its difference from the
+// integer before it.
+//
 //	Map Values
 //
 //	Layout:
@@ -470,7 +656,9 @@
 //
 // The encoded keys are placed in a consistent order; encoding the same map will
 // result in the same encoding regardless of the order of keys encountered
-// during iteration over the keys.
+// during iteration over the keys. Keys of type bool, string, or one of the int
+// or float types are ordered by their value. Keys of any other type are ordered
+// by comparing the bytes of their encoded values.
 //
 //	Nil Values
 //
@@ -521,6 +709,28 @@
 // encoded as a nil value; see the section on nil value encodings for a
 // description of how this information is captured.
 //
+//	References
+//
+//	Layout:
+//
+//	[ INFO ] [ EXT ] [ INT VALUE ]
+//	 1 byte  1 byte    0..8 bytes
+//
+// When reference tracking is enabled, each non-nil pointer, map, and slice is
+// assigned an index in the order that it is first encoded, starting at 0. For a
+// pointer with multiple levels of indirection, only the innermost pointer is
+// assigned an index; the value it points to is then assigned its own index if
+// it is a map or slice. The first time that the data referred to by one of
+// these values is encountered, it is encoded as normal. Every later time, it is
+// instead encoded as an integer with the R bit set in its EXT byte, and the
+// integer gives the index of the first value. Slices are considered to refer
+// to the same data only if they start at the same element and are of the same
+// length.
+//
+// A slice that is referred to from within its own items cannot be decoded, as
+// the slice is not complete until all of its items have been decoded. Pointers
+// and maps do not have this limitation.
+//
 // # Backward Compatibility
 //
 // Older versions of the REZI library use a binary data format that differs from
@@ -541,6 +751,10 @@
 // this allows versions prior to v1.1.0 to be able to read it, as long as it has
 // only a single level of indirection.
 //
+// Older versions of this library encode slices and arrays of bytes as any
+// other slice, with an info byte preceding each byte. Data in this format can
+// still be decoded as normal with [Dec] and [Reader.Dec].
+//
 // REZI library versions prior to v2.1.0 encode string data length as the number
 // of Unicode codepoints rather than the number of bytes and do so in the info
 // byte with no info extension byte. These strings can be decoded as normal with
@@ -584,6 +798,10 @@ type analyzed[E any] struct {
 	v       E
 	reflect reflect.Value
 	info    typeInfo
+
+	// sess is the state of the encode or decode that the value is a part of.
+	// It may be nil, in which case no options are in effect.
+	sess *session
 }
 
 type (
@@ -628,7 +846,84 @@ func Enc(v interface{}) (data []byte, err error) {
 		return nil, err
 	}
 
-	return encWithTypeInfo(v, info)
+	return encWithTypeInfo(v, info, newSession(nil))
+}
+
+// EncWithFormat is identical to Enc, but it encodes v using the options given
+// in f. If f is nil, the default Format is used. The Version and Compression
+// options of f are ignored; compression is only performed by a [Writer].
+func EncWithFormat(v interface{}, f *Format) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	return encWithSession(v, newSession(f))
+}
+
+// encWithSession performs type analysis on v and encodes it as part of the
+// given session. It is panic safe.
+func encWithSession(v interface{}, sess *session) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	info, err := canEncode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return encWithTypeInfo(v, info, sess)
+}
+
+// EncFields is identical to Enc, but only the struct fields with the given
+// paths are encoded. Each path is a sequence of field names separated by dots,
+// such as "Name" or "Info.AverageAge"; a leading dot is optional. Selecting a
+// field selects all of the fields of the structs within it, and selecting a
+// field within a struct leaves out the fields of that struct that are not
+// selected. Slices, arrays, maps, and pointers between the structs on a path
+// are passed through, so that a path such as "Items.Name" selects the Name
+// field of every struct in the Items slice.
+//
+// Fields that are not selected are left out of the encoding in the same way as
+// empty fields with the omitempty option, so the data can be decoded to the
+// same type as v. Fields that are not selected but are required or have a
+// default are instead encoded as the zero value, so that they are decoded as
+// the zero value too. Tuple structs always have every field, so their fields
+// that are not selected are also encoded as the zero value. The fields of
+// structs used as map keys are always all encoded. If a path is not the path
+// of a field within v, the returned error will match [ErrInvalidType].
+func EncFields(v interface{}, fields ...string) (data []byte, err error) {
+	return EncFieldsWithFormat(v, nil, fields...)
+}
+
+// EncFieldsWithFormat is identical to EncFields, but it encodes v using the
+// options given in f. If f is nil, the default Format is used.
+//
+// If f has TrackReferences enabled, a value that is referred to from more
+// than one place is encoded with the fields selected for the first place it
+// is encoded in.
+func EncFieldsWithFormat(v interface{}, f *Format, fields ...string) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	info, err := canEncode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := newSession(f)
+	sess.mask, err = newFieldMask(reflect.TypeOf(v), info, fields)
+	if err != nil {
+		return nil, err
+	}
+	return encWithTypeInfo(v, info, sess)
 }
 
 // MustEnc is identical to Enc, but panics if an error would be returned.
@@ -684,7 +979,197 @@ func Dec(data []byte, v interface{}) (n int, err error) {
 		return 0, err
 	}
 
-	return decWithTypeInfo(data, v, info)
+	return decWithTypeInfo(data, v, info, newSession(nil))
+}
+
+// DecWithFormat is identical to Dec, but it decodes data using the options
+// given in f. If f is nil, the default Format is used. The Version and
+// Compression options of f are ignored; decompression is only performed by a
+// [Reader].
+func DecWithFormat(data []byte, v interface{}, f *Format) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	info, err := canDecode(v)
+	if err != nil {
+		return 0, err
+	}
+
+	return decWithTypeInfo(data, v, info, newSession(f))
+}
+
+// DecWithFields is identical to DecWithFormat, but it also returns the fields
+// of every struct within v that were present in data and those that were
+// absent. If f is nil, the default Format is used. The returned FieldSet is
+// only valid if err is nil.
+func DecWithFields(data []byte, v interface{}, f *Format) (fs FieldSet, n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	info, err := canDecode(v)
+	if err != nil {
+		return fs, 0, err
+	}
+
+	sess := newSession(f)
+	sess.fieldSet = &fs
+	n, err = decWithTypeInfo(data, v, info, sess)
+	return fs, n, err
+}
+
+// DecPath decodes only the value at path within data to v, skipping over the
+// encoded values around it by reading their headers rather than decoding them.
+// Type parameter T is the type of the value that data holds, which is needed
+// to find the length of the skipped values.
+//
+// The path is given in the same form returned by [FieldPath.String], such as
+// ".Info.Taxonomy[2]" or "[Karkat].Age"; the leading dot is optional. Fields
+// are given by the names they are encoded with, indexes of slices and arrays
+// by a decimal number, and keys of maps by their value, which is only possible
+// for maps whose keys have a bool, string, integer, or float type. An empty
+// path decodes all of data.
+//
+// The returned n is the number of bytes in data taken up by the entire
+// encoded T, not only by the decoded value, so that it can be used to advance
+// past it in the same way as the value returned by [Dec]. If the value at path
+// is not in data, the returned error will match [ErrNotFound].
+func DecPath[T any](data []byte, path string, v interface{}) (n int, err error) {
+	return DecPathWithFormat[T](data, path, v, nil)
+}
+
+// DecPathWithFormat is identical to DecPath, but it decodes data using the
+// options given in f. If f is nil, the default Format is used.
+//
+// Values encoded with TrackReferences or FieldNameTable enabled can depend on
+// the values before them, so they cannot be skipped. If f has either option
+// enabled, or if data is found to have been encoded with a field-name table,
+// all of data is decoded before the value at path is taken from it.
+func DecPathWithFormat[T any](data []byte, path string, v interface{}, f *Format) (n int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	info, err := canDecode(v)
+	if err != nil {
+		return 0, err
+	}
+	parts, err := parsePath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	dataInfo, err := decTypeInfo(t, nil)
+	if err != nil {
+		return 0, err
+	}
+	n, err = skipValue(data, shapeOf(dataInfo))
+	if err != nil {
+		return 0, err
+	}
+
+	sess := newSession(f)
+	m, err := locatePath(data, n, t, dataInfo, parts, sess)
+	if err != nil {
+		return 0, err
+	}
+
+	found := data[m.start:m.end]
+	if len(m.rest) > 0 {
+		found, err = findDecoded(found, m.t, m.ti, m.rest, sess)
+		if err != nil {
+			return 0, err
+		}
+		sess = sess.stateless()
+	}
+
+	if _, err := decWithTypeInfo(found, v, info, sess); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// EncPath replaces only the value at path within data with v, and returns the
+// updated data. Only v is encoded; the count in the header of every value that
+// encloses it is changed to match its new length, and all other bytes are
+// copied as they are. data itself is not modified. Type parameter T is the
+// type of the value that data holds, and path is given in the same form as for
+// [DecPath].
+//
+// v must be of the type of the value at path, or a pointer to that type. It is
+// encoded with the options that apply to that value, such as those given in the
+// rezi tag of the struct field it is in. Only a value that is already in data
+// can be replaced; if the value at path is not in data, such as a key that is
+// not in a map or a field that was omitted, the returned error will match
+// [ErrNotFound].
+func EncPath[T any](data []byte, path string, v interface{}) (updated []byte, err error) {
+	return EncPathWithFormat[T](data, path, v, nil)
+}
+
+// EncPathWithFormat is identical to EncPath, but it decodes and encodes data
+// using the options given in f. If f is nil, the default Format is used.
+//
+// In the same way as with [DecPathWithFormat], values encoded with
+// TrackReferences or FieldNameTable enabled cannot be skipped. If f has either
+// option enabled, or if data is found to have been encoded with a field-name
+// table, all of data is decoded, changed, and encoded again.
+func EncPathWithFormat[T any](data []byte, path string, v interface{}, f *Format) (updated []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errorf("%v", r)
+		}
+	}()
+
+	parts, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	dataInfo, err := decTypeInfo(t, nil)
+	if err != nil {
+		return nil, err
+	}
+	n, err := skipValue(data, shapeOf(dataInfo))
+	if err != nil {
+		return nil, err
+	}
+
+	sess := newSession(f)
+	m, err := locatePath(data, n, t, dataInfo, parts, sess)
+	if err != nil {
+		return nil, err
+	}
+
+	var enc []byte
+	if len(m.rest) > 0 {
+		enc, err = replaceDecoded(data[m.start:m.end], m.t, m.ti, m.rest, v, f)
+	} else {
+		var newVal reflect.Value
+		newVal, err = pathValue(v, m.t)
+		if err != nil {
+			return nil, err
+		}
+		enc, err = encWithTypeInfo(newVal.Interface(), m.ti, sess)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	updated = make([]byte, 0, len(data)-(m.end-m.start)+len(enc))
+	updated = append(updated, data[:m.start]...)
+	updated = append(updated, enc...)
+	updated = append(updated, data[m.end:]...)
+
+	return patchHeaders(updated, m.headers, len(enc)-(m.end-m.start))
 }
 
 // MustDec is identical to Dec, but panics if an error would be returned.
@@ -698,15 +1183,40 @@ func MustDec(data []byte, v interface{}) int {
 
 // encWithTypeInfo has type analysis already performed, and it is not panic
 // safe.
-func encWithTypeInfo(v interface{}, info typeInfo) (data []byte, err error) {
+func encWithTypeInfo(v interface{}, info typeInfo, sess *session) (data []byte, err error) {
+	if info.Trackable() {
+		if sess.trackingRefs() {
+			return encTracked(v, info, sess)
+		}
+
+		leave, err := sess.enterUntracked(v, info)
+		if err != nil {
+			return nil, err
+		}
+		defer leave()
+	}
+	return encDispatch(v, info, sess)
+}
+
+// encDispatch encodes v with the encoder for its main type. It does not
+// perform reference tracking.
+func encDispatch(v interface{}, info typeInfo, sess *session) (data []byte, err error) {
 	value := analyzed[any]{
 		v:       v,
 		reflect: reflect.ValueOf(v),
 		info:    info,
+		sess:    sess,
 	}
 
 	if info.Primitive() {
-		return encCheckedPrim(value)
+		data, err := encCheckedPrim(value)
+		if err != nil {
+			return nil, err
+		}
+		if kind := markedNumKind(data, info, sess); kind != numKindNone {
+			data = markNumKind(data, kind)
+		}
+		return data, nil
 	} else if info.Main == mtNil {
 		return encNilHeader(0), nil
 	} else if info.Main == mtMap {
@@ -715,6 +1225,10 @@ func encWithTypeInfo(v interface{}, info typeInfo) (data []byte, err error) {
 		return encCheckedSlice(value)
 	} else if info.Main == mtStruct {
 		return encCheckedStruct(value)
+	} else if info.Main == mtRezi {
+		return encCheckedRezi(value)
+	} else if info.Main == mtRaw {
+		return encCheckedRaw(value)
 	} else {
 		panic("no possible encoding")
 	}
@@ -722,11 +1236,40 @@ func encWithTypeInfo(v interface{}, info typeInfo) (data []byte, err error) {
 
 // decWithTypeInfo has type analysis already performed, and it is not panic
 // safe.
-func decWithTypeInfo(data []byte, v interface{}, info typeInfo) (n int, err error) {
+func decWithTypeInfo(data []byte, v interface{}, info typeInfo, sess *session) (n int, err error) {
+	defer func() {
+		if rErr, ok := err.(reziError); ok && rErr.typ == nil {
+			rErr.typ = reflect.TypeOf(v)
+			if rErr.typ != nil && rErr.typ.Kind() == reflect.Pointer {
+				rErr.typ = rErr.typ.Elem()
+			}
+			err = rErr
+		}
+	}()
+
+	if sess != nil {
+		sess.depth++
+		defer func() { sess.depth-- }()
+	}
+
+	if info.Trackable() {
+		if sess.trackingRefs() {
+			return decTracked(data, v, info, sess)
+		} else if err := checkNotReference(data); err != nil {
+			return 0, err
+		}
+	}
+	return decDispatch(data, v, info, sess)
+}
+
+// decDispatch decodes data with the decoder for the main type of info. It does
+// not perform reference tracking.
+func decDispatch(data []byte, v interface{}, info typeInfo, sess *session) (n int, err error) {
 	recv := analyzed[any]{
 		v:       v,
 		reflect: reflect.ValueOf(v),
 		info:    info,
+		sess:    sess,
 	}
 
 	var dec decoded[any]
@@ -738,6 +1281,10 @@ func decWithTypeInfo(data []byte, v interface{}, info typeInfo) (n int, err erro
 		dec, err = decCheckedSlice(data, recv)
 	} else if info.Main == mtStruct {
 		dec, err = decCheckedStruct(data, recv)
+	} else if info.Main == mtRezi {
+		dec, err = decCheckedRezi(data, recv)
+	} else if info.Main == mtRaw {
+		dec, err = decCheckedRaw(data, recv)
 	} else {
 		panic("no possible decoding")
 	}
@@ -771,6 +1318,7 @@ func encWithNilCheck[E any](value analyzed[any], encFn encFunc[E], convFn func(r
 			v:       convTarget,
 			reflect: reflect.ValueOf(convTarget),
 			info:    value.info,
+			sess:    value.sess,
 		}
 		return encFn(reAnalyzed)
 	} else {
@@ -871,12 +1419,18 @@ func fn_DecToWrappedReceiver(wrapped analyzed[any], assertFn func(reflect.Type)
 		receiverType := refWrapped.Type()
 		refUnwrapped := refWrapped
 
+		// when merging, the decode func needs to see the value that is
+		// already in the receiver in order to combine the decoded value
+		// with it.
+		merging := wrapped.sess.merging() != MergeNone
+		keepExisting := wrapped.info.Main.Unmarshaled() || merging
+
 		if receiverType.Kind() == reflect.Pointer { // future-proofing - binary unmarshaler might come in as a T
 			// for every * in the (...*) part of *(...*)T up until the
 			// implementor/slice-ptr, do a deref.
 			for i := 0; i < wrapped.info.Indir; i++ {
 				receiverType = receiverType.Elem()
-				if (wrapped.info.Main == mtText || wrapped.info.Main == mtBinary) && refUnwrapped.IsValid() {
+				if keepExisting && refUnwrapped.IsValid() {
 					if !refUnwrapped.IsNil() {
 						refUnwrapped = refUnwrapped.Elem()
 					} else {
@@ -908,14 +1462,17 @@ func fn_DecToWrappedReceiver(wrapped analyzed[any], assertFn func(reflect.Type)
 			// automatic and we have full control; we don't (and can't) rely on
 			// already set things and use reflect after actual decoding to copy
 			// the decoded values into the passed-in pointer.
-			if (wrapped.info.Main == mtText || wrapped.info.Main == mtBinary) && refUnwrapped.IsValid() && !refUnwrapped.IsNil() {
+			if wrapped.info.Main.Unmarshaled() && refUnwrapped.IsValid() && !refUnwrapped.IsNil() {
 				receiverValue = refUnwrapped
 			} else {
 				receiverValue = reflect.New(receiverType.Elem())
+				if merging && refUnwrapped.IsValid() && !refUnwrapped.IsNil() {
+					receiverValue.Elem().Set(refUnwrapped.Elem())
+				}
 			}
 		} else {
 			// receiverType is itself T (future-proofing)
-			if (wrapped.info.Main == mtText || wrapped.info.Main == mtBinary) && refUnwrapped.IsValid() {
+			if wrapped.info.Main.Unmarshaled() && refUnwrapped.IsValid() {
 				receiverValue = refUnwrapped
 			} else {
 				receiverValue = reflect.Zero(receiverType)
@@ -925,7 +1482,7 @@ func fn_DecToWrappedReceiver(wrapped analyzed[any], assertFn func(reflect.Type)
 		var decodedValue interface{}
 
 		receiver := receiverValue.Interface()
-		recvAnalyzed := analyzed[any]{v: receiver, reflect: receiverValue, info: wrapped.info}
+		recvAnalyzed := analyzed[any]{v: receiver, reflect: receiverValue, info: wrapped.info, sess: wrapped.sess}
 		dec, decErr := decToUnwrappedFn(data, recvAnalyzed)
 
 		if decErr != nil {
@@ -944,7 +1501,7 @@ func fn_DecToWrappedReceiver(wrapped analyzed[any], assertFn func(reflect.Type)
 }
 
 func preAnalyzed[E any](oldAnalysis analyzed[any], newVal E) analyzed[E] {
-	return analyzed[E]{v: newVal, reflect: oldAnalysis.reflect, info: oldAnalysis.info}
+	return analyzed[E]{v: newVal, reflect: oldAnalysis.reflect, info: oldAnalysis.info, sess: oldAnalysis.sess}
 }
 
 func nilErrEncoder[E any](fn func(analyzed[E]) []byte) encFunc[E] {
@@ -986,6 +1543,18 @@ type countHeader struct {
 	// implies ExtensionLevel >= 1
 	Version int
 
+	// Whether the count is the index of a previously-encoded value that the
+	// value being checked refers to, rather than the count of the value's own
+	// bytes. Only present in data encoded with reference tracking enabled. If
+	// true, automatically implies ExtensionLevel >= 1.
+	Reference bool
+
+	// Whether the count is followed by the length of the array that was
+	// encoded, which is included in the count. Only present in data encoded
+	// with array lengths recorded. If true, automatically implies
+	// ExtensionLevel >= 1.
+	ArrayLength bool
+
 	// ExtensionLevel is number of extension bytes that are in the
 	// representation. Caveat - this can be "wrong". When encoding, regardless
 	// of this value as many extension bytes as are needed to encode non-default
@@ -1032,11 +1601,12 @@ func (hdr countHeader) MarshalBinary() ([]byte, error) {
 	//
 	//
 	// extension byte 1 layout for ref:
-	// BXUUVVVV
+	// BXRAVVVV
 	//
 	// B = length is Byte count. not included if not needed.
 	// X = eXtension
-	// U = Unused
+	// R = count is a back-Reference index.
+	// A = count is followed by the Array length.
 	// V = binary format explicit Version
 
 	if hdr.Length > 15 || hdr.Length < 0 {
@@ -1070,7 +1640,7 @@ func (hdr countHeader) MarshalBinary() ([]byte, error) {
 	encoded = append(encoded, infoByte)
 
 	// if later things require more info bytes, continue to the next
-	if hdr.ByteLength || hdr.Version > 0 || hdr.ExtensionLevel >= 1 {
+	if hdr.ByteLength || hdr.Reference || hdr.ArrayLength || hdr.Version > 0 || hdr.ExtensionLevel >= 1 {
 		encoded[0] |= infoBitsExt
 
 		// do the extension byte
@@ -1081,6 +1651,14 @@ func (hdr countHeader) MarshalBinary() ([]byte, error) {
 			extByte |= infoBitsByteCount
 		}
 
+		if hdr.Reference {
+			extByte |= infoBitsRef
+		}
+
+		if hdr.ArrayLength {
+			extByte |= infoBitsArrayLen
+		}
+
 		encoded = append(encoded, extByte)
 	}
 
@@ -1136,9 +1714,11 @@ func (hdr *countHeader) UnmarshalBinary(data []byte) error {
 
 		// interpret the extension byte based on which one it is
 		if decodedHdr.ExtensionLevel == 1 {
-			// first extension byte, layout: BXUUVVVV.
+			// first extension byte, layout: BXRAVVVV.
 			decodedHdr.Version = int(extByte & infoBitsVersion)
 			decodedHdr.ByteLength = extByte&infoBitsByteCount != 0
+			decodedHdr.Reference = extByte&infoBitsRef != 0
+			decodedHdr.ArrayLength = extByte&infoBitsArrayLen != 0
 		}
 
 		// future: more extension bytes, if needed. for now, just run through
@@ -4,10 +4,82 @@ package rezi
 // basic types.
 
 import (
+	"fmt"
 	"io"
 	"reflect"
 )
 
+// rawBytesVersion is the version given in the EXT byte of a slice or array of
+// bytes that is encoded as a blob of raw bytes.
+const rawBytesVersion = 2
+
+// ArrayLengthPolicy is how an encoded slice or array is decoded to an array
+// whose length is different from the number of items in the encoded data. It
+// is selected with [Format.ArrayLength].
+type ArrayLengthPolicy int
+
+const (
+	// ArrayExact decodes only data with exactly as many items as the array
+	// has. It is the default.
+	ArrayExact ArrayLengthPolicy = iota
+
+	// ArrayPad decodes data with fewer items than the array has by leaving
+	// the items after them as the zero value of their type. Data with more
+	// items than the array has cannot be decoded.
+	ArrayPad
+
+	// ArrayTruncate decodes data with fewer items than the array has as for
+	// ArrayPad, and decodes data with more items than the array has by
+	// dropping the items that do not fit.
+	ArrayTruncate
+)
+
+// String returns the name of the ArrayLengthPolicy.
+func (p ArrayLengthPolicy) String() string {
+	switch p {
+	case ArrayExact:
+		return "exact"
+	case ArrayPad:
+		return "pad"
+	case ArrayTruncate:
+		return "truncate"
+	default:
+		return fmt.Sprintf("ArrayLengthPolicy(%d)", int(p))
+	}
+}
+
+// arrayLength returns the ArrayLengthPolicy to use for decoding.
+func (s *session) arrayLength() ArrayLengthPolicy {
+	if s == nil {
+		return ArrayExact
+	}
+	return s.f.ArrayLength
+}
+
+// recordingArrayLengths returns whether the length of every array is encoded
+// with it.
+func (s *session) recordingArrayLengths() bool {
+	return s != nil && s.f.ArrayLengths
+}
+
+// arrayItemCount returns how many of count decoded items are kept when they
+// are decoded to an array of the given length using policy. If they cannot be
+// decoded to the array, the returned error will be non-nil.
+func arrayItemCount(count, length int, policy ArrayLengthPolicy) (int, error) {
+	if count == length {
+		return count, nil
+	}
+	if policy == ArrayExact || (count > length && policy != ArrayTruncate) {
+		return 0, errorf("decoded %d items but array has length %d", count, length).wrap(ErrMalformedData, ErrArrayLength)
+	}
+	if count > length {
+		return length, nil
+	}
+	return count, nil
+}
+
+var refByteType = reflect.TypeOf(byte(0))
+
 // encMap encodes a compatible slice as a REZI map.
 func encCheckedSlice(value analyzed[any]) ([]byte, error) {
 	if value.info.Main != mtSlice && value.info.Main != mtArray {
@@ -17,6 +89,8 @@ func encCheckedSlice(value analyzed[any]) ([]byte, error) {
 	return encWithNilCheck(value, encSlice, reflect.Value.Interface)
 }
 
+// encSlice encodes a slice or array, with the length of the array given
+// before its items if the session is recording array lengths.
 func encSlice(value analyzed[any]) ([]byte, error) {
 	isArray := value.reflect.Type().Kind() == reflect.Array
 
@@ -24,11 +98,55 @@ func encSlice(value analyzed[any]) ([]byte, error) {
 		return encNilHeader(0), nil
 	}
 
+	enc, err := encSliceItems(value)
+	if err != nil || !isArray || !value.sess.recordingArrayLengths() {
+		return enc, err
+	}
+	return withArrayLength(enc, value.reflect.Len())
+}
+
+// withArrayLength returns the encoded slice or array enc with length given
+// before its items.
+func withArrayLength(enc []byte, length int) ([]byte, error) {
+	hdr, err := decCountHeader(enc)
+	if err != nil {
+		return nil, err
+	}
+	count, err := decInt[tLen](enc)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := encInt(analyzed[tLen]{v: length})
+	contents = append(contents, enc[count.n:]...)
+
+	withLen := encCount(len(contents), &countHeader{Version: hdr.v.Version, ArrayLength: true})
+	return append(withLen, contents...), nil
+}
+
+// encSliceItems encodes the items of a non-nil slice or array.
+func encSliceItems(value analyzed[any]) ([]byte, error) {
+	isArray := value.reflect.Type().Kind() == reflect.Array
+
+	if value.info.RawBytes() {
+		return encRawBytes(value.reflect), nil
+	}
+
+	if value.info.Packable() {
+		packing := value.info.Packing
+		if packing == PackNone {
+			packing = value.sess.packing()
+		}
+		if packing != PackNone {
+			return encPacked(value.reflect, value.info, packing), nil
+		}
+	}
+
 	enc := make([]byte, 0)
 
 	for i := 0; i < value.reflect.Len(); i++ {
 		v := value.reflect.Index(i)
-		encData, err := encWithTypeInfo(v.Interface(), *value.info.ValType)
+		encData, err := encWithTypeInfo(v.Interface(), *value.info.ValType, value.sess)
 		if err != nil {
 			if isArray {
 				return nil, errorf("array item[%d]: %s", i, err)
@@ -43,6 +161,24 @@ func encSlice(value analyzed[any]) ([]byte, error) {
 	return enc, nil
 }
 
+// encRawBytes encodes a slice or array of bytes as a blob of raw bytes.
+func encRawBytes(v reflect.Value) []byte {
+	if v.Len() == 0 {
+		return []byte{0x00}
+	}
+
+	enc := encCount(v.Len(), &countHeader{Version: rawBytesVersion})
+
+	if v.Kind() == reflect.Slice {
+		return append(enc, v.Bytes()...)
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		enc = append(enc, byte(v.Index(i).Uint()))
+	}
+	return enc
+}
+
 func decCheckedSlice(data []byte, recv analyzed[any]) (decoded[any], error) {
 	if recv.info.Main != mtSlice && recv.info.Main != mtArray {
 		panic("not a slice or array type")
@@ -64,9 +200,46 @@ func decCheckedSlice(data []byte, recv analyzed[any]) (decoded[any], error) {
 	return sl, err
 }
 
+// decSlice decodes a slice or array, combining a decoded slice with the one
+// already in the receiver if the session is merging.
 func decSlice(data []byte, recv analyzed[any]) (decoded[any], error) {
+	mode := recv.sess.merging()
+	if mode == MergeNone || recv.info.Main != mtSlice {
+		return decNewSlice(data, recv, reflect.Value{})
+	}
+
+	existing := reflect.New(recv.reflect.Type().Elem()).Elem()
+	existing.Set(recv.reflect.Elem())
+
+	// when overwriting, items are decoded straight into the existing backing
+	// array rather than into a new slice that is then copied over it.
+	var into reflect.Value
+	if mode == MergeOverwrite && !existing.IsNil() {
+		into = existing.Slice(0, 0)
+	}
+
+	dec, err := decNewSlice(data, recv, into)
+	if err != nil {
+		return dec, err
+	}
+
+	sl := mergeSlice(existing, dec.reflect, mode)
+	recv.reflect.Elem().Set(sl)
+	dec.v = sl.Interface()
+	dec.reflect = sl
+	return dec, nil
+}
+
+// decNewSlice decodes a slice or array, replacing whatever is in the receiver.
+// If into is valid, it must be an empty slice of the receiver's slice type;
+// decoded items are appended to it instead of to a newly-allocated slice.
+func decNewSlice(data []byte, recv analyzed[any], into reflect.Value) (decoded[any], error) {
 	var dec decoded[any]
 
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return dec, errorDecf(0, "decode byte count: %s", err)
+	}
 	toConsume, err := decInt[tLen](data)
 	if err != nil {
 		return dec, errorDecf(0, "decode byte count: %s", err)
@@ -84,11 +257,19 @@ func decSlice(data []byte, recv analyzed[any]) (decoded[any], error) {
 		sliceOrArrStr = "array"
 	}
 
+	if isArray && toConsume.v <= 0 {
+		if _, err := arrayItemCount(0, refArrType.Len(), recv.sess.arrayLength()); err != nil {
+			return dec, errorDecf(0, "%s", err)
+		}
+	}
+
 	if toConsume.v == 0 {
 		// initialize to the empty slice/array
 		var empty reflect.Value
 		if isArray {
 			empty = reflect.New(refArrType).Elem()
+		} else if into.IsValid() {
+			empty = into
 		} else {
 			empty = reflect.MakeSlice(refSliceType, 0, 0)
 		}
@@ -124,10 +305,67 @@ func decSlice(data []byte, recv analyzed[any]) (decoded[any], error) {
 	// clamp values we are allowed to read so we don't try to read other data
 	data = data[:toConsume.v]
 
+	// an encoded array may give its length before its items. it must match
+	// the length of an array it is decoded to.
+	if hdr.v.ArrayLength {
+		arrLen, err := decInt[tLen](data)
+		if err != nil {
+			return dec, errorDecf(dec.n, "decode array length: %s", err)
+		}
+		if isArray && arrLen.v != refArrType.Len() {
+			err := errorDecf(dec.n, "encoded array has length %d but decoded-to array has length %d", arrLen.v, refArrType.Len())
+			return dec, err.wrap(ErrMalformedData, ErrArrayLength)
+		}
+		dec.n += arrLen.n
+		data = data[arrLen.n:]
+		toConsume.v -= arrLen.n
+	}
+
+	if hdr.v.Version == rawBytesVersion {
+		if !recv.info.RawBytes() {
+			err := errorDecf(0, "raw bytes cannot be decoded to a %s of %s", sliceOrArrStr, refSliceType.Elem())
+			return dec, err.wrap(ErrMalformedData, ErrInvalidType)
+		}
+		raw := data
+		if isArray {
+			keep, err := arrayItemCount(len(data), refArrType.Len(), recv.sess.arrayLength())
+			if err != nil {
+				return dec, errorDecf(0, "%s", err)
+			}
+			raw = data[:keep]
+		}
+
+		sl := decRawBytes(raw, refSliceType)
+		dec.n += toConsume.v
+
+		refSliceVal.Elem().Set(sl)
+		dec.v = sl.Interface()
+		dec.reflect = sl
+		return dec, nil
+	} else if hdr.v.Version == packedVersion {
+		if !recv.info.Packable() {
+			err := errorDecf(0, "packed data cannot be decoded to a %s of %s", sliceOrArrStr, refSliceType.Elem())
+			return dec, err.wrap(ErrMalformedData, ErrInvalidType)
+		}
+
+		sl, err := decPacked(data, refSliceType, *recv.info.ValType, recv.sess.arrayLength())
+		if err != nil {
+			return dec, errorDecf(dec.n, "%s", err)
+		}
+		dec.n += toConsume.v
+
+		refSliceVal.Elem().Set(sl)
+		dec.v = sl.Interface()
+		dec.reflect = sl
+		return dec, nil
+	}
+
 	var sl reflect.Value
 
 	if isArray {
 		sl = reflect.New(refArrType).Elem()
+	} else if into.IsValid() {
+		sl = into
 	} else {
 		sl = reflect.MakeSlice(refSliceType, 0, 0)
 	}
@@ -137,24 +375,61 @@ func decSlice(data []byte, recv analyzed[any]) (decoded[any], error) {
 	refVType := refSliceType.Elem()
 	for i < toConsume.v {
 		refValue := reflect.New(refVType)
-		n, err := decWithTypeInfo(data, refValue.Interface(), *recv.info.ValType)
+		step := PathStep{Kind: refSliceType.Kind(), Index: itemIdx}
+		recv.sess.enterStep(step)
+		n, err := decWithTypeInfo(data, refValue.Interface(), *recv.info.ValType, recv.sess)
+		recv.sess.leaveStep()
 		if err != nil {
-			return dec, errorDecf(dec.n, "%s item[%d]: %s", sliceOrArrStr, itemIdx, err)
+			return dec, errorDecf(dec.n, "%s item[%d]: %s", sliceOrArrStr, itemIdx, err).withStep(step)
 		}
 		dec.n += n
 		i += n
 		data = data[n:]
 
 		if isArray {
-			sl.Index(itemIdx).Set(refValue.Elem())
+			if itemIdx < refArrType.Len() {
+				sl.Index(itemIdx).Set(refValue.Elem())
+			} else if recv.sess.arrayLength() != ArrayTruncate {
+				err := errorDecf(dec.n, "decoded more than %d items but array has length %d", itemIdx, refArrType.Len())
+				return dec, err.wrap(ErrMalformedData, ErrArrayLength)
+			}
 		} else {
 			sl = reflect.Append(sl, refValue.Elem())
 		}
 		itemIdx++
 	}
 
+	if isArray {
+		if _, err := arrayItemCount(itemIdx, refArrType.Len(), recv.sess.arrayLength()); err != nil {
+			return dec, errorDecf(dec.n, "%s", err)
+		}
+	}
+
 	refSliceVal.Elem().Set(sl)
 	dec.v = sl.Interface()
 	dec.reflect = sl
 	return dec, nil
 }
+
+// decRawBytes creates a new value of type t, which must be a slice or array of
+// bytes, that holds a copy of data. If t is an array, data must not be longer
+// than it.
+func decRawBytes(data []byte, t reflect.Type) reflect.Value {
+	var v reflect.Value
+	if t.Kind() == reflect.Array {
+		v = reflect.New(t).Elem()
+	} else {
+		v = reflect.MakeSlice(t, len(data), len(data))
+	}
+
+	if t.Elem() == refByteType {
+		reflect.Copy(v, reflect.ValueOf(data))
+		return v
+	}
+
+	// a named byte type; copy each byte individually.
+	for i := range data {
+		v.Index(i).SetUint(uint64(data[i]))
+	}
+	return v
+}
@@ -2,6 +2,7 @@ package rezi
 
 import (
 	"encoding/asn1"
+	"io"
 	"math"
 	"sync"
 	"testing"
@@ -3228,3 +3229,206 @@ func Test_Dec_Slice_ValueIndirection(t *testing.T) {
 		assert.Equal(expect, actual)
 	})
 }
+
+type testNamedByte uint8
+
+type testByteSlice []byte
+
+func Test_Enc_Slice_RawBytes(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  interface{}
+		expect []byte
+	}{
+		{
+			name:   "nil []byte",
+			input:  []byte(nil),
+			expect: []byte{0xa0},
+		},
+		{
+			name:   "empty []byte",
+			input:  []byte{},
+			expect: []byte{0x00},
+		},
+		{
+			name:   "[]byte",
+			input:  []byte{0x01, 0xff, 0x00},
+			expect: []byte{0x41, 0x02, 0x03, 0x01, 0xff, 0x00},
+		},
+		{
+			name:   "[4]byte",
+			input:  [4]byte{0x0a, 0x0b, 0x0c, 0x0d},
+			expect: []byte{0x41, 0x02, 0x04, 0x0a, 0x0b, 0x0c, 0x0d},
+		},
+		{
+			name:   "named byte slice type",
+			input:  testByteSlice{0x41, 0x42},
+			expect: []byte{0x41, 0x02, 0x02, 0x41, 0x42},
+		},
+		{
+			name:   "slice of named byte type",
+			input:  []testNamedByte{0x41, 0x42},
+			expect: []byte{0x41, 0x02, 0x02, 0x41, 0x42},
+		},
+		{
+			name:  "[]*byte is not raw",
+			input: []*byte{ref(byte(0x08))},
+			expect: []byte{
+				0x01, 0x02,
+				0x01, 0x08,
+			},
+		},
+		{
+			name:  "[]int8 is not raw",
+			input: []int8{0x08},
+			expect: []byte{
+				0x01, 0x02,
+				0x01, 0x08,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := Enc(tc.input)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_Dec_Slice_RawBytes(t *testing.T) {
+	t.Run("[]byte", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x41, 0x02, 0x03, 0x01, 0xff, 0x00, 0x88}
+		expect := []byte{0x01, 0xff, 0x00}
+
+		var actual []byte
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(6, n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("[]byte in older item-wise format", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x01, 0x05, 0x01, 0x01, 0x01, 0xff, 0x00}
+		expect := []byte{0x01, 0xff, 0x00}
+
+		var actual []byte
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(7, n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("[4]byte", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x41, 0x02, 0x04, 0x0a, 0x0b, 0x0c, 0x0d}
+		expect := [4]byte{0x0a, 0x0b, 0x0c, 0x0d}
+
+		var actual [4]byte
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(7, n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("[4]byte with fewer bytes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x41, 0x02, 0x02, 0x0a, 0x0b}
+		expect := [4]byte{0x0a, 0x0b, 0x00, 0x00}
+
+		var actual [4]byte
+		n, err := DecWithFormat(input, &actual, &Format{ArrayLength: ArrayPad})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(5, n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("named byte slice type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x41, 0x02, 0x02, 0x41, 0x42}
+		expect := testByteSlice{0x41, 0x42}
+
+		var actual testByteSlice
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(5, n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("slice of named byte type", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x41, 0x02, 0x02, 0x41, 0x42}
+		expect := []testNamedByte{0x41, 0x42}
+
+		var actual []testNamedByte
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(5, n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("[2]byte with too many bytes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x41, 0x02, 0x03, 0x01, 0x02, 0x03}
+
+		var actual [2]byte
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("raw bytes to []int", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x41, 0x02, 0x02, 0x01, 0x02}
+
+		var actual []int
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("not enough bytes", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := []byte{0x41, 0x02, 0x03, 0x01, 0x02}
+
+		var actual []byte
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, io.ErrUnexpectedEOF)
+	})
+}
@@ -2,9 +2,11 @@ package rezi
 
 import (
 	"bufio"
+	"bytes"
 	"compress/zlib"
 	"errors"
 	"io"
+	"reflect"
 )
 
 // Format is a specification of a binary data format used by REZI. It specifies
@@ -32,6 +34,93 @@ type Format struct {
 	//
 	// This property is used only by NewWriter and is ignored by NewReader.
 	CompressionLevel int
+
+	// TrackReferences is whether pointers, maps, and slices that refer to the
+	// same data are written only once within a single encoded value. Each
+	// later occurrence is written as a back-reference to the first, and when
+	// decoded it will refer to the same data as the first did. This also
+	// allows values that contain cycles to be encoded.
+	//
+	// Data written with TrackReferences enabled must be read with it enabled
+	// as well.
+	TrackReferences bool
+
+	// Packing is the packed encoding used for all slices and arrays of
+	// integers or floats that are written. Struct fields whose rezi tag gives
+	// the packed option use the Packing in the tag instead. See [Packing] for
+	// the available packings.
+	//
+	// This property is used only for writing; packed data is always read
+	// correctly regardless of the Packing used to write it.
+	Packing Packing
+
+	// FieldNameTable is whether the set of field names of each struct is
+	// written only once. The first struct with a given set of field names
+	// defines an entry in a table of field-name sets, and every later struct
+	// with the same set gives only the index of that entry. A [Writer] keeps
+	// its table across calls to Enc, so each set of names is written once per
+	// stream; otherwise, the table lasts for a single encoded value.
+	//
+	// This property is used only for writing; data written with a field-name
+	// table is always read correctly regardless of this property. A stream
+	// written by a Writer with FieldNameTable enabled must be read by a
+	// single [Reader], as values in it may refer to entries defined by earlier
+	// values.
+	FieldNameTable bool
+
+	// OmitEmpty is whether every struct field that is empty is left out when
+	// written, as if it had the omitempty option in its rezi tag. A field is
+	// empty if it is the zero value of its type, a float equal to 0 including
+	// -0.0, or a string, slice, or map with a length of 0. This does not
+	// apply to tuple structs, which always include every field.
+	//
+	// This property is used only for writing. As with any field that is not
+	// in the data, decoding leaves the receiver's value of an omitted field
+	// unchanged.
+	OmitEmpty bool
+
+	// ConvertNumbers is whether every integer and float is written along with
+	// the kind of number it is, so that it can later be decoded to an integer,
+	// unsigned integer, or float of any size that can represent it exactly.
+	// This allows the type of a stored number to be changed, such as from
+	// int32 to float64, without the stored data needing to be rewritten.
+	//
+	// This property is used only for writing; numbers written with it are
+	// always read correctly regardless of this property. Numbers written
+	// without it can be converted only if the rezi tag of their field gives the
+	// kind of number they were written as with the was option, such as
+	// `rezi:",was=int"`.
+	ConvertNumbers bool
+
+	// ArrayLength is how a slice or array is decoded to an array that has a
+	// different length than the number of items in the data. See
+	// [ArrayLengthPolicy] for the available policies.
+	//
+	// This property is used only for reading.
+	ArrayLength ArrayLengthPolicy
+
+	// ArrayLengths is whether the length of every array is written with it.
+	// An array written with its length can only be decoded to an array of the
+	// same length, regardless of the ArrayLength policy used to read it,
+	// although it can still be decoded to a slice.
+	//
+	// This property is used only for writing; arrays written with their
+	// length are always read correctly regardless of this property.
+	ArrayLengths bool
+
+	// InvalidUTF8 is how strings that are not valid UTF-8 are handled. See
+	// [UTF8Policy] for the available policies.
+	//
+	// This property is used for both reading and writing.
+	InvalidUTF8 UTF8Policy
+
+	// Merge is how decoded maps and slices are combined with those that the
+	// receiver already holds. See [MergeMode] for the available modes. If a
+	// value cannot be decoded, maps that it was being merged into may have
+	// been partly updated.
+	//
+	// This property is used only for reading.
+	Merge MergeMode
 }
 
 // Writer is an io.WriteCloser that writes REZI data streams. A Writer may be
@@ -45,6 +134,37 @@ type Writer struct {
 	dst        io.Writer
 	dstCloser  func() error // does any closing of dst, if needed
 	dstFlusher func() error // does any flushing of dst, if possible
+
+	// sess is the session of the encode that the Writer is writing a part of.
+	// It is only set for Writers passed to MarshalREZI; all other Writers
+	// begin a new session for each value they encode.
+	sess *session
+
+	// names is the field-name table that is kept across every value the
+	// Writer encodes. It is created when first needed.
+	names *nameTable
+
+	// fields holds the struct fields begun by EncField and EncFieldValue when
+	// their names are given in a field-name table. Their values are kept
+	// until MarshalREZI returns, so that the reference to the names can be
+	// written before them.
+	fields []*pendingField
+}
+
+// pendingField is a struct field whose value is waiting to be written after
+// the field-name table reference of its struct.
+type pendingField struct {
+	name string
+
+	// data is the already-encoded value of the field.
+	data []byte
+
+	// v is the value of the field if it is not yet encoded. Values that may
+	// hold structs are encoded only after the reference to the names of the
+	// struct they are in, so that the entries for the names of their own
+	// structs come after it in the field-name table.
+	v        interface{}
+	deferred bool
 }
 
 // NewWriter creates a new Writer ready to write data to w. If Compression is
@@ -104,6 +224,29 @@ func NewWriter(w io.Writer, f *Format) (*Writer, error) {
 	return streamWriter, nil
 }
 
+// newSubWriter creates a Writer that writes the contents of a value being
+// encoded by a MarshalREZI method to dst as part of the encode with the given
+// session. sess may be nil.
+func newSubWriter(dst io.Writer, sess *session) *Writer {
+	if sess == nil {
+		sess = newSession(nil)
+	}
+
+	f := sess.f
+	f.Compression = false
+	if f.Version == 0 {
+		f.Version = 1
+	}
+
+	return &Writer{
+		f:          f,
+		dst:        dst,
+		dstCloser:  func() error { return nil },
+		dstFlusher: func() error { return nil },
+		sess:       sess,
+	}
+}
+
 // Format returns the Format that w encodes data as.
 func (w *Writer) Format() Format {
 	return w.f
@@ -140,7 +283,7 @@ func (w *Writer) Flush() error {
 // underlying data stream. Any number of bytes written in this function across
 // multiple calls to Write can be read by Reader.Read in any aribitrary order;
 // this makes it so that the length does not need to be known ahead of time on
-// either side, at the cost of data space.
+// either side, at the cost of a small header for each call to Write.
 //
 // If the Writer was opened with compression enabled, the written bytes are not
 // necessarily flushed until the Writer is closed or explicitly flushed.
@@ -165,7 +308,7 @@ func (w *Writer) Write(p []byte) (n int, err error) {
 	// speed hits from that.
 	ti := typeInfo{Main: mtSlice, ValType: &typeInfo{Main: mtIntegral, Bits: 8, Signed: false}}
 
-	toWrite, err := encWithTypeInfo(p, ti)
+	toWrite, err := encWithTypeInfo(p, ti, nil)
 	if err != nil {
 		return 0, err
 	}
@@ -182,13 +325,182 @@ func (w *Writer) Write(p []byte) (n int, err error) {
 // flushed until the Writer is closed or explicitly flushed.
 //
 // Parameter v must be a type supported by REZI.
-func (w *Writer) Enc(v interface{}) error {
-	data, err := Enc(v)
+func (w *Writer) Enc(v interface{}) (err error) {
+	sess := w.sess
+	if sess == nil {
+		sess = newSession(&w.f)
+		if w.f.FieldNameTable {
+			if w.names == nil {
+				w.names = newNameTable()
+			}
+			sess.names = w.names
+
+			// entries added for a value that is not written would be referred
+			// to by later values without ever being defined, so remove them.
+			mark := len(w.names.keys)
+			defer func() {
+				if err != nil {
+					w.names.truncate(mark)
+				}
+			}()
+		}
+	}
+
+	data, err := encWithSession(v, sess)
 	if err != nil {
 		return err
 	}
 
-	_, err = w.dst.Write(data)
+	return w.writeEncoded(data)
+}
+
+// EncString writes the REZI-encoded bytes of s to w. It is identical to calling
+// Enc with s, but does not require the use of reflection.
+func (w *Writer) EncString(s string) error {
+	enc, err := encCheckedString(analyzed[string]{v: s, sess: w.session()})
+	if err != nil {
+		return err
+	}
+	return w.writeEncoded(enc)
+}
+
+// EncBool writes the REZI-encoded bytes of b to w. It is identical to calling
+// Enc with b, but does not require the use of reflection.
+func (w *Writer) EncBool(b bool) error {
+	return w.writeEncoded(encBool(analyzed[bool]{v: b}))
+}
+
+// EncInt writes the REZI-encoded bytes of i to w. It is identical to calling
+// Enc with i converted to any signed integer type that can hold it, but does
+// not require the use of reflection.
+func (w *Writer) EncInt(i int64) error {
+	enc := encInt(analyzed[int64]{v: i})
+	return w.writeEncoded(w.markedNum(enc, typeInfo{Main: mtIntegral, Bits: 64, Signed: true}))
+}
+
+// EncUint writes the REZI-encoded bytes of u to w. It is identical to calling
+// Enc with u converted to any unsigned integer type that can hold it, but does
+// not require the use of reflection.
+func (w *Writer) EncUint(u uint64) error {
+	enc := encInt(analyzed[uint64]{v: u})
+	return w.writeEncoded(w.markedNum(enc, typeInfo{Main: mtIntegral, Bits: 64}))
+}
+
+// EncFloat writes the REZI-encoded bytes of f to w. It is identical to calling
+// Enc with f converted to either float type, but does not require the use of
+// reflection.
+func (w *Writer) EncFloat(f float64) error {
+	enc := encFloat(analyzed[float64]{v: f})
+	return w.writeEncoded(w.markedNum(enc, typeInfo{Main: mtFloat, Bits: 64}))
+}
+
+// EncField writes the name of a struct field whose value is to be written next
+// with one of the typed Enc methods of w, such as EncString or EncInt. It is
+// for MarshalREZI methods that encode a struct by its fields, and leaves the
+// field out in the same cases that encoding the struct without MarshalREZI
+// would: if empty is true and the Format has OmitEmpty enabled, or if the field
+// is not among those selected by [EncFields]. The returned bool is whether the
+// field was kept; its value must be written only if it is true.
+//
+// A field with a value of any other type must be written with EncFieldValue
+// instead.
+func (w *Writer) EncField(name string, empty bool) (bool, error) {
+	sess := w.session()
+	if (empty && sess.omittingEmpty()) || !sess.selectsField(name) {
+		return false, nil
+	}
+
+	if w.sess.tablingNames() {
+		w.fields = append(w.fields, &pendingField{name: name})
+		return true, nil
+	}
+	return true, w.EncString(name)
+}
+
+// EncFieldValue writes the name of a struct field followed by its value v. It
+// is for MarshalREZI methods that encode a struct by its fields, and leaves the
+// field out in the same cases that EncField does, with v being empty if it is
+// nil, the zero value of its type, or a string, slice, or map with a length of
+// 0. When only some fields are selected by [EncFields], the fields selected
+// within the field are applied to v.
+func (w *Writer) EncFieldValue(name string, v interface{}) error {
+	empty := v == nil || isEmptyValue(reflect.ValueOf(v))
+	if ok, err := w.EncField(name, empty); !ok || err != nil {
+		return err
+	}
+
+	if w.sess.tablingNames() {
+		pf := w.fields[len(w.fields)-1]
+		pf.v, pf.deferred = v, true
+		return nil
+	}
+
+	leaveField := w.session().enterField(name)
+	defer leaveField()
+	return w.Enc(v)
+}
+
+// encTabledFields returns the encoded contents of a struct whose fields were
+// given to EncField and EncFieldValue with a field-name table: a reference to
+// the names of the fields followed by their values. It returns nil if there
+// are no such fields.
+func (w *Writer) encTabledFields() ([]byte, error) {
+	if len(w.fields) < 1 {
+		return nil, nil
+	}
+
+	names := make([]string, len(w.fields))
+	for i, pf := range w.fields {
+		names[i] = pf.name
+	}
+	enc := encNameTableRef(w.sess.nameTable(), names)
+
+	for _, pf := range w.fields {
+		if pf.deferred {
+			leaveField := w.sess.enterField(pf.name)
+			data, err := encWithSession(pf.v, w.sess)
+			leaveField()
+			if err != nil {
+				return nil, err
+			}
+			enc = append(enc, data...)
+		}
+		enc = append(enc, pf.data...)
+	}
+	w.fields = nil
+
+	return enc, nil
+}
+
+// session returns the session of the encode that w is writing a part of, or a
+// new session with the Format of w if it is not writing part of one.
+func (w *Writer) session() *session {
+	if w.sess != nil {
+		return w.sess
+	}
+	return newSession(&w.f)
+}
+
+// markedNum returns the encoded number enc of a value with the given type info,
+// marked with its kind if the Format of w calls for it.
+func (w *Writer) markedNum(enc []byte, ti typeInfo) []byte {
+	if kind := markedNumKind(enc, ti, w.session()); kind != numKindNone {
+		return markNumKind(enc, kind)
+	}
+	return enc
+}
+
+// writeEncoded writes already-encoded data to the underlying stream.
+func (w *Writer) writeEncoded(data []byte) error {
+	// the values of fields given in a field-name table are kept until the
+	// reference to their names is written.
+	if len(w.fields) > 0 {
+		pf := w.fields[len(w.fields)-1]
+		pf.data = append(pf.data, data...)
+		return nil
+	}
+
+	_, err := w.dst.Write(data)
 	if err != nil {
 		return err
 	}
@@ -214,6 +526,27 @@ type Reader struct {
 	// for 'normal io.Reader' use of Reader. It holds any loaded decoded bytes
 	// that were not used to fill the slice passed in by the caller of Read.
 	readBuf []byte
+
+	// sess is the session of the decode that the Reader is reading a part of.
+	// It is only set for Readers passed to UnmarshalREZI; all other Readers
+	// begin a new session for each value they decode.
+	sess *session
+
+	// end is the offset at which the data available to the Reader ends. It is
+	// only valid if bounded is set, which it is for Readers passed to
+	// UnmarshalREZI.
+	end     int
+	bounded bool
+
+	// names is the field-name table that is kept across every value the
+	// Reader decodes. It is created when first needed.
+	names *nameTable
+
+	// field is the name of the struct field last given by DecField, and
+	// fieldOffset is the offset of its value. Errors from reading the value
+	// give a step into the field in their path.
+	field       string
+	fieldOffset int
 }
 
 // NewReader creates a new Reader ready to read data from r. If Compression is
@@ -264,6 +597,30 @@ func NewReader(r io.Reader, f *Format) (*Reader, error) {
 	return streamReader, nil
 }
 
+// newSubReader creates a Reader that reads the contents of a value being
+// decoded by an UnmarshalREZI method from data as part of the decode with the
+// given session. sess may be nil.
+func newSubReader(data []byte, sess *session) *Reader {
+	if sess == nil {
+		sess = newSession(nil)
+	}
+
+	f := sess.f
+	f.Compression = false
+	if f.Version == 0 {
+		f.Version = 1
+	}
+
+	return &Reader{
+		f:         f,
+		src:       bytes.NewReader(data),
+		srcCloser: func() error { return nil },
+		sess:      sess,
+		end:       len(data),
+		bounded:   true,
+	}
+}
+
 // Format returns the Format that r interprets data as.
 func (r *Reader) Format() Format {
 	return r.f
@@ -337,12 +694,18 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 		}
 	}
 
+	// manually create type info for []byte to avoid reflection analysis and any
+	// speed hits from that.
+	ti := typeInfo{Main: mtSlice, ValType: &typeInfo{Main: mtIntegral, Bits: 8, Signed: false, Dec: true}, Dec: true}
+
 	for cur < len(p) {
 		var loadedBytes []byte
 
 		// need to capture this to check if any bytes actually read
 		oldOffset := r.offset
-		err := r.Dec(&loadedBytes)
+		err := r.decLoaded(ti, func(data []byte) (int, error) {
+			return decWithTypeInfo(data, &loadedBytes, ti, nil)
+		})
 		if err != nil {
 			// okay, if we got UnexpectedEOF due to no bytes at all being
 			// present, that is okay, actually. we just hit the end of the
@@ -372,6 +735,14 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 	return cur, nil
 }
 
+// More returns whether there are bytes remaining to be read from r. It can only
+// determine this for a Reader passed to an UnmarshalREZI method, which reads
+// only the bytes of the value being decoded. For all other Readers, More always
+// returns true.
+func (r *Reader) More() bool {
+	return !r.bounded || r.offset < r.end
+}
+
 // Dec decodes REZI-encoded bytes in r at the current position into the supplied
 // value v, then advances the data stream past those bytes.
 //
@@ -390,20 +761,157 @@ func (r *Reader) Dec(v interface{}) (err error) {
 		return err
 	}
 
+	sess := r.sess
+	if sess == nil {
+		if r.names == nil {
+			r.names = newNameTable()
+		}
+		sess = newSession(&r.f)
+		sess.names = r.names
+	}
+
+	return r.decLoaded(info, func(data []byte) (int, error) {
+		return decWithTypeInfo(data, v, info, sess)
+	})
+}
+
+// DecString decodes a string from the REZI-encoded bytes in r at the current
+// position, then advances the data stream past those bytes. It is identical to
+// calling Dec with a pointer to a string, but does not require the use of
+// reflection.
+func (r *Reader) DecString() (string, error) {
+	sess := r.sess
+	if sess == nil {
+		sess = newSession(&r.f)
+	}
+
+	var s string
+	err := r.decLoaded(typeInfo{Main: mtString, Dec: true}, func(data []byte) (int, error) {
+		dec, err := decCheckedString(data, sess.invalidUTF8())
+		s = dec.v
+		return dec.n, err
+	})
+	return s, err
+}
+
+// DecField decodes the name of a struct field from the REZI-encoded bytes in r
+// at the current position, then advances the data stream past those bytes. It
+// is for UnmarshalREZI methods that decode a struct by its fields, and is
+// otherwise identical to DecString. Errors returned by the methods of r until
+// the next call to DecField give a step into the field in the Path of their
+// [DecodeError], as they do when a struct is decoded without UnmarshalREZI.
+func (r *Reader) DecField() (string, error) {
+	r.field = ""
+	name, err := r.DecString()
+	if err != nil {
+		return name, err
+	}
+	r.field, r.fieldOffset = name, r.offset
+	return name, nil
+}
+
+// FieldError returns an error for a problem with the decoded value of the
+// struct field last given by DecField, such as a number that does not fit in
+// the type of the field. The returned error matches err, and is reported at the
+// offset of the value with a step into the field in the Path of its
+// [DecodeError].
+func (r *Reader) FieldError(err error) error {
+	return r.inField(errorDecf(r.fieldOffset, "%s", err))
+}
+
+// UnknownFieldError returns an error for a struct field last given by DecField
+// that the struct type with the given name does not have. The returned error
+// matches [ErrMalformedData] and [ErrInvalidType], the same as the error for an
+// unknown field when a struct is decoded without UnmarshalREZI.
+func (r *Reader) UnknownFieldError(typeName string) error {
+	const errFmt = "field name .%s does not exist in decoded-to %s"
+	return errorDecf(r.fieldOffset, errFmt, r.field, typeName).wrap(ErrMalformedData, ErrInvalidType)
+}
+
+// inField returns err with a step into the struct field last given by DecField,
+// if there is one.
+func (r *Reader) inField(err reziError) reziError {
+	if r.field == "" {
+		return err
+	}
+	return err.withStep(PathStep{Kind: reflect.Struct, Field: r.field})
+}
+
+// DecBool decodes a bool from the REZI-encoded bytes in r at the current
+// position, then advances the data stream past those bytes. It is identical to
+// calling Dec with a pointer to a bool, but does not require the use of
+// reflection.
+func (r *Reader) DecBool() (bool, error) {
+	var b bool
+	err := r.decLoaded(typeInfo{Main: mtBool, Dec: true}, func(data []byte) (int, error) {
+		dec, err := decBool(data)
+		b = dec.v
+		return dec.n, err
+	})
+	return b, err
+}
+
+// DecInt decodes a signed integer from the REZI-encoded bytes in r at the
+// current position, then advances the data stream past those bytes. It is
+// identical to calling Dec with a pointer to an int64, but does not require the
+// use of reflection.
+func (r *Reader) DecInt() (int64, error) {
+	var i int64
+	err := r.decLoaded(typeInfo{Main: mtIntegral, Bits: 64, Signed: true, Dec: true}, func(data []byte) (int, error) {
+		dec, err := decInt[int64](data)
+		i = dec.v
+		return dec.n, err
+	})
+	return i, err
+}
+
+// DecUint decodes an unsigned integer from the REZI-encoded bytes in r at the
+// current position, then advances the data stream past those bytes. It is
+// identical to calling Dec with a pointer to a uint64, but does not require the
+// use of reflection.
+func (r *Reader) DecUint() (uint64, error) {
+	var u uint64
+	err := r.decLoaded(typeInfo{Main: mtIntegral, Bits: 64, Dec: true}, func(data []byte) (int, error) {
+		dec, err := decInt[uint64](data)
+		u = dec.v
+		return dec.n, err
+	})
+	return u, err
+}
+
+// DecFloat decodes a floating-point value from the REZI-encoded bytes in r at
+// the current position, then advances the data stream past those bytes. It is
+// identical to calling Dec with a pointer to a float64, but does not require
+// the use of reflection.
+func (r *Reader) DecFloat() (float64, error) {
+	var f float64
+	err := r.decLoaded(typeInfo{Main: mtFloat, Bits: 64, Signed: true, Dec: true}, func(data []byte) (int, error) {
+		dec, err := decFloat[float64](data)
+		f = dec.v
+		return dec.n, err
+	})
+	return f, err
+}
+
+// decLoaded loads the bytes of the next value of the type described by info
+// from the stream and decodes them with decFn, which must return the number of
+// bytes it consumed. The offset of r is advanced past the loaded bytes.
+func (r *Reader) decLoaded(info typeInfo, decFn func([]byte) (int, error)) error {
 	datumBytes, err := r.loadDecodeableBytes(info)
 	if err != nil && err != io.EOF {
+		err = r.inField(errorDecf(r.offset, "%s", err))
 		r.offset += len(datumBytes)
 		return err
 	}
 
-	n, err := decWithTypeInfo(datumBytes, v, info)
+	n, err := decFn(datumBytes)
 	if err != nil {
-		err = errorDecf(r.offset, "%s", err)
+		err = r.inField(errorDecf(r.offset, "%s", err))
 		r.offset += len(datumBytes)
 		return err
 	}
 	if n != len(datumBytes) {
-		err = errorDecf(r.offset, "expected decoded data at offset to consume byte len of %d but actual consumed is %d", len(datumBytes), n)
+		err = r.inField(errorDecf(r.offset, "expected decoded data at offset to consume byte len of %d but actual consumed is %d", len(datumBytes), n))
 		r.offset += len(datumBytes)
 		return err
 	}
@@ -482,6 +990,18 @@ func (r *Reader) loadDecodeableBytes(info typeInfo) ([]byte, error) {
 		return hdrBytes, nil
 	}
 
+	// a reference is only an int giving the index of the value it refers to,
+	// regardless of the type of that value.
+	if hdr.Reference {
+		intBytes, err := r.loadBytes(hdr.Length)
+		lastErr = err
+		loaded := append(hdrBytes, intBytes...)
+		if err != nil && err != io.EOF {
+			return loaded, errorDecf(totalRead, "%s", err)
+		}
+		return loaded, lastErr
+	}
+
 	// special case: if it's a non-nil v0 string, we need to immediately decode
 	// it as we go. We can only tell this once we have the header and can
 	// determine that it is not, in fact, a nil.
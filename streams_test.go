@@ -193,6 +193,44 @@ func Test_Writer_Enc(t *testing.T) {
 	assert.Equal(expect, actual)
 }
 
+func Test_Writer_EncTyped(t *testing.T) {
+	assert := assert.New(t)
+
+	buf := &bytes.Buffer{}
+	w, err := NewWriter(buf, nil)
+	if !assert.NoError(err, "error creating writer") {
+		return
+	}
+
+	expect := []byte{
+		0x41, 0x82, 0x06, 0x4e, 0x45, 0x50, 0x45, 0x54, 0x41,
+		0x01,
+		0x02, 0x01, 0x9d,
+		0x01, 0x08,
+		0x04, 0xc0, 0x70, 0x00, 0x32,
+	}
+
+	if !assert.NoError(w.EncString("NEPETA"), "error writing string") {
+		return
+	}
+	if !assert.NoError(w.EncBool(true), "error writing bool") {
+		return
+	}
+	if !assert.NoError(w.EncInt(413), "error writing int") {
+		return
+	}
+	if !assert.NoError(w.EncUint(8), "error writing uint") {
+		return
+	}
+	if !assert.NoError(w.EncFloat(256.01220703125), "error writing float") {
+		return
+	}
+	w.Flush()
+
+	actual := buf.Bytes()
+	assert.Equal(expect, actual)
+}
+
 func Test_Reader_Read_oneCall(t *testing.T) {
 	testCases := []struct {
 		name      string
@@ -1886,10 +1924,14 @@ func Test_Reader_Dec_array(t *testing.T) {
 		{
 			name: "empty",
 			input: []byte{
-				0x00, // len=0
+				0x01, 0x03, // len=3
+
+				0x00, // ""
+				0x00, // ""
+				0x00, // ""
 			},
 			expect:    [3]string{},
-			expectOff: 1,
+			expectOff: 5,
 		},
 		{
 			name: "3 value",
@@ -1906,10 +1948,27 @@ func Test_Reader_Dec_array(t *testing.T) {
 		{
 			name: "empty x2",
 			input: []byte{
-				0x00, // len=0
-				0x00, // len=0
+				0x01, 0x03, // len=3
+
+				0x00, // ""
+				0x00, // ""
+				0x00, // ""
+
+				0x01, 0x03, // len=3
+
+				0x00, // ""
+				0x00, // ""
+				0x00, // ""
 			},
 			expect:    [3]string{},
+			expectOff: 5,
+		},
+		{
+			name: "error - fewer items than array",
+			input: []byte{
+				0x00, // len=0
+			},
+			expectErr: true,
 			expectOff: 1,
 		},
 		{
@@ -2122,3 +2181,72 @@ func Test_Reader_Dec_map(t *testing.T) {
 		assert.Equal(expectOff, r.offset, "offset mismatch")
 	})
 }
+
+func Test_Reader_DecTyped(t *testing.T) {
+	assert := assert.New(t)
+
+	input := []byte{
+		0x41, 0x82, 0x06, 0x4e, 0x45, 0x50, 0x45, 0x54, 0x41,
+		0x01,
+		0x02, 0x01, 0x9d,
+		0x01, 0x08,
+		0x04, 0xc0, 0x70, 0x00, 0x32,
+	}
+
+	r, err := NewReader(bytes.NewReader(input), nil)
+	if !assert.NoError(err, "error creating reader") {
+		return
+	}
+
+	s, err := r.DecString()
+	if !assert.NoError(err, "error reading string") {
+		return
+	}
+	b, err := r.DecBool()
+	if !assert.NoError(err, "error reading bool") {
+		return
+	}
+	i, err := r.DecInt()
+	if !assert.NoError(err, "error reading int") {
+		return
+	}
+	u, err := r.DecUint()
+	if !assert.NoError(err, "error reading uint") {
+		return
+	}
+	f, err := r.DecFloat()
+	if !assert.NoError(err, "error reading float") {
+		return
+	}
+
+	assert.Equal("NEPETA", s)
+	assert.Equal(true, b)
+	assert.Equal(int64(413), i)
+	assert.Equal(uint64(8), u)
+	assert.Equal(256.01220703125, f)
+
+	// unbounded readers always report more data
+	assert.True(r.More())
+
+	_, err = r.DecString()
+	assert.ErrorIs(err, io.ErrUnexpectedEOF)
+}
+
+func Test_Reader_More(t *testing.T) {
+	assert := assert.New(t)
+
+	input := []byte{0x01, 0x08, 0x00}
+
+	r := newSubReader(input, nil)
+
+	var values []int
+	for r.More() {
+		var v int
+		if !assert.NoError(r.Dec(&v)) {
+			return
+		}
+		values = append(values, v)
+	}
+
+	assert.Equal([]int{8, 0}, values)
+}
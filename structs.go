@@ -1,10 +1,16 @@
 package rezi
 
 import (
+	"fmt"
+	"hash/crc32"
 	"io"
 	"reflect"
 )
 
+// tupleStructVersion is the version given in the EXT byte of a struct that is
+// encoded as a tuple.
+const tupleStructVersion = 4
+
 // encCheckedStruct encodes a compatible struct as a REZI .
 func encCheckedStruct(value analyzed[any]) ([]byte, error) {
 	if value.info.Main != mtStruct {
@@ -17,34 +23,203 @@ func encCheckedStruct(value analyzed[any]) ([]byte, error) {
 func encStruct(value analyzed[any]) ([]byte, error) {
 	enc := make([]byte, 0)
 
-	for _, fi := range value.info.Fields.ByOrder {
-		v := value.reflect.Field(fi.Index)
+	msgTypeName := value.reflect.Type().Name()
+	if msgTypeName == "" {
+		msgTypeName = "(anonymous type)"
+	}
 
-		fNameData, err := encWithTypeInfo(fi.Name, typeInfo{Indir: 0, Underlying: false, Main: mtString})
-		if err != nil {
-			msgTypeName := value.reflect.Type().Name()
-			if msgTypeName == "" {
-				msgTypeName = "(anonymous type)"
+	// tuples are given as the number of fields and their fingerprint
+	// followed by only the values.
+	tuple := value.info.Fields.Tuple
+	if tuple {
+		enc = append(enc, encInt(analyzed[tLen]{v: len(value.info.Fields.ByOrder)})...)
+		enc = append(enc, encInt(analyzed[uint32]{v: tupleFingerprint(value.info.Fields)})...)
+	}
+
+	// empty fields are left out entirely if they are to be omitted. tuples
+	// always have every field, as their fields are found by position.
+	included := value.info.Fields.ByOrder
+	if !tuple {
+		included = nonEmptyFields(value.reflect, included, value.sess.omittingEmpty())
+		included = value.sess.selectedFields(included)
+	}
+
+	// fields with an ID are keyed by it instead of by their name; such structs
+	// do not use the field-name table as their keys vary field by field.
+	numbered := value.info.Fields.Numbered()
+
+	// with a field-name table, the names are given once up front by a
+	// reference to their entry and only the values follow.
+	tabled := !tuple && !numbered && value.sess.tablingNames() && len(included) > 0
+	if tabled {
+		names := make([]string, len(included))
+		for i, fi := range included {
+			names[i] = fi.Name
+		}
+		enc = append(enc, encNameTableRef(value.sess.nameTable(), names)...)
+	}
+
+	for _, fi := range included {
+		// only tuples include fields behind a nil embedded pointer, and they
+		// are given the zero value.
+		// likewise, tuple fields and required or defaulted fields that are
+		// not selected are given the zero value.
+		v, ok := fieldByIndex(value.reflect, fi.Index)
+		if !ok || !value.sess.selectsField(fi.Name) {
+			v = reflect.Zero(value.reflect.Type().FieldByIndex(fi.Index).Type)
+		}
+
+		if numbered && fi.ID != 0 {
+			enc = append(enc, encFieldKey(fi)...)
+		} else if !tabled && !tuple {
+			fNameData, err := encWithTypeInfo(fi.Name, typeInfo{Indir: 0, Underlying: false, Main: mtString}, value.sess)
+			if err != nil {
+				return nil, errorf("%s.%s field name: %s", msgTypeName, fi.Name, err)
 			}
-			return nil, errorf("%s.%s field name: %s", msgTypeName, fi.Name, err)
+			enc = append(enc, fNameData...)
 		}
-		fValData, err := encWithTypeInfo(v.Interface(), fi.Type)
+
+		leaveField := value.sess.enterField(fi.Name)
+		fValData, err := encWithTypeInfo(v.Interface(), fi.Type, value.sess)
+		leaveField()
 		if err != nil {
-			msgTypeName := value.reflect.Type().Name()
-			if msgTypeName == "" {
-				msgTypeName = "(anonymous type)"
-			}
 			return nil, errorf("%s.%s: %v", msgTypeName, fi.Name, err)
 		}
-
-		enc = append(enc, fNameData...)
 		enc = append(enc, fValData...)
 	}
 
-	enc = append(encCount(len(enc), nil), enc...)
+	var hdr *countHeader
+	if tuple {
+		hdr = &countHeader{Version: tupleStructVersion}
+	} else if tabled {
+		hdr = &countHeader{Version: fieldNameTableVersion}
+	} else if numbered {
+		hdr = &countHeader{Version: numberedFieldsVersion}
+	}
+	enc = append(encCount(len(enc), hdr), enc...)
 	return enc, nil
 }
 
+// tupleFingerprint returns the fingerprint of the fields of a tuple struct,
+// which is a checksum of the name and kind of value of each field in the order
+// they are encoded. It is encoded with the tuple so that it is not decoded to a
+// struct with the same number of fields but different ones or in a different
+// order.
+func tupleFingerprint(fs *fields) uint32 {
+	h := crc32.NewIEEE()
+	for _, fi := range fs.ByOrder {
+		fmt.Fprintf(h, "%s:%s;", fi.Name, tupleFieldKind(fi.Type))
+	}
+	return h.Sum32()
+}
+
+// tupleFieldKind returns the kind of value that a tuple field with type info ti
+// is given as in its fingerprint. Types that can be decoded to each other share
+// a kind.
+func tupleFieldKind(ti typeInfo) string {
+	switch ti.Main {
+	case mtIntegral, mtFloat:
+		return "number"
+	case mtComplex:
+		return "complex"
+	case mtBool:
+		return "bool"
+	case mtString, mtText:
+		return "string"
+	case mtBinary:
+		return "binary"
+	case mtRezi:
+		return "rezi"
+	case mtSlice, mtArray:
+		return "list"
+	case mtMap, mtStruct:
+		return "struct"
+	case mtRaw:
+		return "raw"
+	default:
+		return ""
+	}
+}
+
+// nonEmptyFields returns the fields of the struct v that are to be encoded,
+// which is all of them except those that are behind a nil embedded pointer and
+// those that are empty and are to be omitted, either because omitAll is set or
+// because they have the omitempty option. Fields that are required or have a
+// default are never omitted because of omitAll, as they would not be decoded
+// as the empty value if they were absent.
+func nonEmptyFields(v reflect.Value, fields []fieldInfo, omitAll bool) []fieldInfo {
+	var included []fieldInfo
+	for i, fi := range fields {
+		omit := fi.OmitEmpty || (omitAll && !fi.Required && !fi.Default.IsValid())
+		fv, ok := fieldByIndex(v, fi.Index)
+		if !ok || (omit && isEmptyValue(fv)) {
+			if included == nil {
+				included = append(make([]fieldInfo, 0, len(fields)-1), fields[:i]...)
+			}
+			continue
+		}
+		if included != nil {
+			included = append(included, fi)
+		}
+	}
+
+	if included == nil {
+		// nothing was omitted
+		return fields
+	}
+	return included
+}
+
+// isEmptyValue returns whether v is the zero value of its type or is a string,
+// slice, or map with a length of 0. Floats are empty if they equal 0, so that
+// negative zero is empty as well.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// fieldByIndex returns the field of struct v with the given index sequence. If
+// an embedded pointer on the way to the field is nil, ok will be false.
+func fieldByIndex(v reflect.Value, index []int) (field reflect.Value, ok bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// settableFieldByIndex returns the field of struct v with the given index
+// sequence, allocating any embedded pointer on the way to it that is nil. v
+// must be addressable. As with encoding/json, an embedded pointer to an
+// unexported struct type cannot be allocated, as reflection cannot set it; an
+// error matching ErrInvalidType is returned if one is nil.
+func settableFieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return v, errorf("cannot set embedded pointer to unexported struct type %s", v.Type().Elem()).wrap(ErrInvalidType)
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
 // decCheckedStruct decodes a REZI bytes representation of a struct into a
 // compatible struct type.
 func decCheckedStruct(data []byte, recv analyzed[any]) (decoded[any], error) {
@@ -93,6 +268,10 @@ func decStruct(data []byte, recv analyzed[any]) (decoded[any], error) {
 		msgTypeName = "(anonymous type)"
 	}
 
+	hdr, err := decCountHeader(data)
+	if err != nil {
+		return dec, errorDecf(0, "decode %s byte count: %s", msgTypeName, err)
+	}
 	toConsume, err := decInt[tLen](data)
 	if err != nil {
 		return dec, errorDecf(0, "decode %s byte count: %s", msgTypeName, err)
@@ -106,8 +285,12 @@ func decStruct(data []byte, recv analyzed[any]) (decoded[any], error) {
 
 		// set it to the value
 		refVal.Elem().Set(emptyStruct.Elem())
-		dec.v = emptyStruct.Elem().Interface()
-		dec.reflect = emptyStruct.Elem()
+		recv.sess.recordFields(recv.info.Fields, dec.fields)
+		if err := decAbsentFields(refVal.Elem(), recv.info.Fields, &dec, msgTypeName); err != nil {
+			return dec, err
+		}
+		dec.v = refVal.Elem().Interface()
+		dec.reflect = refVal.Elem()
 		return dec, nil
 	}
 
@@ -127,32 +310,166 @@ func decStruct(data []byte, recv analyzed[any]) (decoded[any], error) {
 	data = data[:toConsume.v]
 
 	target := refVal.Elem()
-	var i int
-	for i < toConsume.v {
-		// get field name
-		var fNameVal string
-		n, err := decWithTypeInfo(data, &fNameVal, typeInfo{Indir: 0, Underlying: false, Main: mtString, Dec: true})
-		if err != nil {
-			return dec, errorDecf(dec.n, "decode %s field name: %s", msgTypeName, err)
+
+	// a Raw field that holds an integer, float, or bool is given the wrong
+	// length, which causes errors in the data after it. lastRaw is the field
+	// that was just decoded if it is a Raw, so such errors can be reported at
+	// it instead.
+	var lastRaw *fieldInfo
+	var lastRawOffset int
+	afterRaw := func(err reziError) error {
+		if lastRaw == nil {
+			return err
 		}
-		dec.n += n
-		i += n
-		data = data[n:]
+		step := PathStep{Kind: reflect.Struct, Field: lastRaw.Name}
+		const errFmt = "%s.%s: Raw value is misread; it may be an integer, float, or bool, which a Raw cannot hold"
+		return errorDecf(lastRawOffset, errFmt, msgTypeName, lastRaw.Name).wrap(ErrMalformedData, ErrInvalidType).withStep(step)
+	}
 
-		// get field info from name
-		fi, ok := recv.info.Fields.ByName[fNameVal]
+	decField := func(fName string) error {
+		// get field info from name. a name that is not a field might be that
+		// of an embedded struct whose fields were not promoted when encoded.
+		fi, ok := recv.info.Fields.ByName[fName]
+		nested := false
+		if !ok {
+			fi, ok = recv.info.Fields.Embedded[fName]
+			nested = true
+		}
 		if !ok {
-			return dec, errorDecf(dec.n, "field name .%s does not exist in decoded-to %s", fNameVal, msgTypeName).wrap(ErrMalformedData, ErrInvalidType)
+			return afterRaw(errorDecf(dec.n, "field name .%s does not exist in decoded-to %s", fName, msgTypeName).wrap(ErrMalformedData, ErrInvalidType))
+		}
+		step := PathStep{Kind: reflect.Struct, Field: fi.Name}
+		field, err := settableFieldByIndex(target, fi.Index)
+		if err != nil {
+			return errorDecf(dec.n, "%s.%s: %v", msgTypeName, fi.Name, err).withStep(step)
 		}
-		fieldPtr := target.Field(fi.Index).Addr()
-		n, err = decWithTypeInfo(data, fieldPtr.Interface(), fi.Type)
+		recv.sess.enterStep(step)
+		n, err := decWithTypeInfo(data, field.Addr().Interface(), fi.Type, recv.sess)
+		recv.sess.leaveStep()
 		if err != nil {
-			return dec, errorDecf(dec.n, "%s.%s: %v", msgTypeName, fi.Name, err)
+			if fi.Type.Main == mtRaw {
+				// the struct's byte count was checked, so a Raw that does
+				// not fit in it was given the wrong length.
+				const errFmt = "%s.%s: %v; Raw value may be an integer, float, or bool, which a Raw cannot hold"
+				return errorDecf(dec.n, errFmt, msgTypeName, fi.Name, err).wrap(ErrInvalidType).withStep(step)
+			}
+			return errorDecf(dec.n, "%s.%s: %v", msgTypeName, fi.Name, err).withStep(step)
+		}
+		lastRaw = nil
+		if fi.Type.Main == mtRaw {
+			lastRaw = &fi
+			lastRawOffset = dec.n
 		}
 		dec.n += n
-		i += n
 		data = data[n:]
 		dec.fields = append(dec.fields, fi)
+		if nested {
+			// every promoted field that it contains was decoded as well
+			dec.fields = append(dec.fields, recv.info.Fields.within(fi.Index)...)
+		}
+		return nil
+	}
+
+	if hdr.v.Version == tupleStructVersion {
+		// tuples can only be decoded to the exact same fields that they were
+		// encoded from, so make sure that's what we have.
+		if !recv.info.Fields.Tuple {
+			return dec, errorDecf(dec.n, "data is a tuple struct but decoded-to %s is not a tuple struct", msgTypeName).wrap(ErrMalformedData, ErrInvalidType)
+		}
+		count, err := decInt[tLen](data)
+		if err != nil {
+			return dec, errorDecf(dec.n, "decode %s field count: %s", msgTypeName, err)
+		}
+		if count.v != len(recv.info.Fields.ByOrder) {
+			return dec, errorDecf(dec.n, "data has %d fields but decoded-to tuple struct %s has %d", count.v, msgTypeName, len(recv.info.Fields.ByOrder)).wrap(ErrMalformedData, ErrInvalidType)
+		}
+		dec.n += count.n
+		data = data[count.n:]
+
+		fingerprint, err := decInt[uint32](data)
+		if err != nil {
+			return dec, errorDecf(dec.n, "decode %s field fingerprint: %s", msgTypeName, err)
+		}
+		if fingerprint.v != tupleFingerprint(recv.info.Fields) {
+			return dec, errorDecf(dec.n, "data has different fields than decoded-to tuple struct %s", msgTypeName).wrap(ErrMalformedData, ErrInvalidType)
+		}
+		dec.n += fingerprint.n
+		data = data[fingerprint.n:]
+
+		for _, fi := range recv.info.Fields.ByOrder {
+			if err := decField(fi.Name); err != nil {
+				return dec, err
+			}
+		}
+		if len(data) > 0 {
+			return dec, afterRaw(errorDecf(dec.n, "%d bytes remain in %s after its last field", len(data), msgTypeName).wrap(ErrMalformedData))
+		}
+	} else if hdr.v.Version == fieldNameTableVersion {
+		names, err := decNameTableRef(data, recv.sess.nameTable())
+		if err != nil {
+			return dec, errorDecf(dec.n, "decode %s field names: %s", msgTypeName, err)
+		}
+		dec.n += names.n
+		data = data[names.n:]
+
+		for _, fNameVal := range names.v {
+			if err := decField(fNameVal); err != nil {
+				return dec, err
+			}
+		}
+		if len(data) > 0 {
+			return dec, afterRaw(errorDecf(dec.n, "%d bytes remain in %s after its last field", len(data), msgTypeName).wrap(ErrMalformedData))
+		}
+	} else {
+		for len(data) > 0 {
+			// in numbered structs, fields with an ID are given by a key
+			// instead of a name. names are always encoded with an EXT byte,
+			// and keys never are.
+			if hdr.v.Version == numberedFieldsVersion && data[0]&infoBitsExt == 0 {
+				id, shape, n, err := decFieldKey(data)
+				if err != nil {
+					return dec, afterRaw(errorDecf(dec.n, "decode %s field key: %s", msgTypeName, err))
+				}
+				dec.n += n
+				data = data[n:]
+
+				fi, ok := recv.info.Fields.ByID[id]
+				if !ok {
+					// unknown IDs are skipped so that fields can be removed
+					// from a type while still decoding data that has them.
+					n, err := skipValue(data, shape)
+					if err != nil {
+						return dec, errorDecf(dec.n, "skip %s field with unknown id %d: %s", msgTypeName, id, err)
+					}
+					dec.n += n
+					data = data[n:]
+					lastRaw = nil
+					continue
+				}
+				if err := decField(fi.Name); err != nil {
+					return dec, err
+				}
+				continue
+			}
+
+			// get field name
+			var fNameVal string
+			n, err := decWithTypeInfo(data, &fNameVal, typeInfo{Indir: 0, Underlying: false, Main: mtString, Dec: true}, recv.sess)
+			if err != nil {
+				return dec, afterRaw(errorDecf(dec.n, "decode %s field name: %s", msgTypeName, err))
+			}
+			dec.n += n
+			data = data[n:]
+
+			if err := decField(fNameVal); err != nil {
+				return dec, err
+			}
+		}
+	}
+
+	recv.sess.recordFields(recv.info.Fields, dec.fields)
+	if err := decAbsentFields(target, recv.info.Fields, &dec, msgTypeName); err != nil {
+		return dec, err
 	}
 
 	dec.v = target.Interface()
@@ -165,9 +482,13 @@ func makeStructWithFieldValues(initial, setValues reflect.Value, affectedFields
 	newVal.Elem().Set(initial)
 
 	for _, fi := range affectedFields {
-		destPtr := newVal.Elem().Field(fi.Index).Addr()
-		fieldVal := setValues.Field(fi.Index)
-		destPtr.Elem().Set(fieldVal)
+		dest, err := settableFieldByIndex(newVal.Elem(), fi.Index)
+		if err != nil {
+			// setValues was decoded to, so every field in it can be set
+			panic(err.Error())
+		}
+		fieldVal, _ := fieldByIndex(setValues, fi.Index)
+		dest.Set(fieldVal)
 	}
 
 	return newVal.Elem()
@@ -831,13 +831,11 @@ func Test_Enc_Struct(t *testing.T) {
 				Name: "NEPETA",
 			}
 			expect = []byte{
-				0x01, 0x30, // len=48
+				0x01, 0x1a, // len=26
 
 				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
 				0x41, 0x82, 0x06, 0x4e, 0x45, 0x50, 0x45, 0x54, 0x41, // "NEPETA"
 
-				0x41, 0x82, 0x11, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x54, 0x6f, 0x45, 0x6d, 0x62, 0x65, 0x64, // "TestStructToEmbed"
-				0x01, 0x0a, // len=10
 				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
 				0x01, 0x04, // 4
 			}
@@ -863,13 +861,11 @@ func Test_Enc_Struct(t *testing.T) {
 				Name: "NEPETA",
 			}
 			expect = []byte{
-				0x01, 0x30, // len=48
+				0x01, 0x1a, // len=26
 
 				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
 				0x41, 0x82, 0x06, 0x4e, 0x45, 0x50, 0x45, 0x54, 0x41, // "NEPETA"
 
-				0x41, 0x82, 0x11, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x54, 0x6f, 0x45, 0x6d, 0x62, 0x65, 0x64, // "TestStructToEmbed"
-				0x01, 0x0a, // len=10
 				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
 				0x01, 0x04, // 4
 			}
@@ -913,13 +909,11 @@ func Test_Enc_Struct(t *testing.T) {
 			}
 			input  = &inputPtr
 			expect = []byte{
-				0x01, 0x30, // len=48
+				0x01, 0x1a, // len=26
 
 				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
 				0x41, 0x82, 0x06, 0x4e, 0x45, 0x50, 0x45, 0x54, 0x41, // "NEPETA"
 
-				0x41, 0x82, 0x11, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x54, 0x6f, 0x45, 0x6d, 0x62, 0x65, 0x64, // "TestStructToEmbed"
-				0x01, 0x0a, // len=10
 				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
 				0x01, 0x04, // 4
 			}
@@ -964,16 +958,11 @@ func Test_Enc_Struct(t *testing.T) {
 				Name:  "NEPETA",
 			}
 			expect = []byte{
-				0x01, 0x3c, // len=60
+				0x01, 0x1c, // len=28
 
 				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
 				0x41, 0x82, 0x06, 0x4e, 0x45, 0x50, 0x45, 0x54, 0x41, // "NEPETA"
 
-				0x41, 0x82, 0x11, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x54, 0x6f, 0x45, 0x6d, 0x62, 0x65, 0x64, // "TestStructToEmbed"
-				0x01, 0x0a, // len=10
-				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
-				0x01, 0x04, // 4
-
 				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
 				0x03, 0xc0, 0x20, 0x80, // 8.25
 			}
@@ -1000,16 +989,11 @@ func Test_Enc_Struct(t *testing.T) {
 				Name:  "NEPETA",
 			}
 			expect = []byte{
-				0x01, 0x3c, // len=60
+				0x01, 0x1c, // len=28
 
 				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
 				0x41, 0x82, 0x06, 0x4e, 0x45, 0x50, 0x45, 0x54, 0x41, // "NEPETA"
 
-				0x41, 0x82, 0x11, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x54, 0x6f, 0x45, 0x6d, 0x62, 0x65, 0x64, // "TestStructToEmbed"
-				0x01, 0x0a, // len=10
-				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
-				0x01, 0x04, // 4
-
 				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
 				0x03, 0xc0, 0x20, 0x80, // 8.25
 			}
@@ -1054,16 +1038,11 @@ func Test_Enc_Struct(t *testing.T) {
 			}
 			input  = &inputPtr
 			expect = []byte{
-				0x01, 0x3c, // len=60
+				0x01, 0x1c, // len=28
 
 				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
 				0x41, 0x82, 0x06, 0x4e, 0x45, 0x50, 0x45, 0x54, 0x41, // "NEPETA"
 
-				0x41, 0x82, 0x11, 0x54, 0x65, 0x73, 0x74, 0x53, 0x74, 0x72, 0x75, 0x63, 0x74, 0x54, 0x6f, 0x45, 0x6d, 0x62, 0x65, 0x64, // "TestStructToEmbed"
-				0x01, 0x0a, // len=10
-				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
-				0x01, 0x04, // 4
-
 				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
 				0x03, 0xc0, 0x20, 0x80, // 8.25
 			}
@@ -3018,3 +2997,509 @@ func Test_Dec_Struct(t *testing.T) {
 		assert.Equal(expectConsumed, consumed, "consumed bytes mismatch")
 	})
 }
+
+type testStructTuple struct {
+	_     struct{} `rezi:",tuple"`
+	Value int
+	Name  string
+	Flag  bool
+}
+
+type testStructTupleReordered struct {
+	_     struct{} `rezi:",tuple"`
+	Name  string
+	Value int
+	Flag  bool
+}
+
+type testStructTuplePoint struct {
+	_ struct{} `rezi:",tuple"`
+	X int
+	Y int
+}
+
+type testStructTuplePointSwapped struct {
+	_ struct{} `rezi:",tuple"`
+	Y int
+	X int
+}
+
+type testStructTuplePointRenamed struct {
+	_     struct{} `rezi:",tuple"`
+	Left  int
+	Right int
+}
+
+func Test_Enc_Struct_Tuple(t *testing.T) {
+	assert := assert.New(t)
+	input := testStructTuple{Value: 3, Name: "Jade", Flag: true}
+	expect := []byte{
+		0x41, 0x04, 0x11, // len=17, tuple
+		0x01, 0x03, // 3 fields
+		0x04, 0x8a, 0x84, 0x30, 0x5f, // fingerprint
+		0x01, 0x03, // 3
+		0x41, 0x82, 0x04, 0x4a, 0x61, 0x64, 0x65, // "Jade"
+		0x01, // true
+	}
+
+	actual, err := Enc(input)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal(expect, actual)
+}
+
+func Test_Dec_Struct_Tuple(t *testing.T) {
+	t.Run("tuple", func(t *testing.T) {
+		assert := assert.New(t)
+		input := []byte{
+			0x41, 0x04, 0x11, // len=17, tuple
+			0x01, 0x03, // 3 fields
+			0x04, 0x8a, 0x84, 0x30, 0x5f, // fingerprint
+			0x01, 0x03, // 3
+			0x41, 0x82, 0x04, 0x4a, 0x61, 0x64, 0x65, // "Jade"
+			0x01, // true
+		}
+		expect := testStructTuple{Value: 3, Name: "Jade", Flag: true}
+
+		var actual testStructTuple
+		consumed, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+		assert.Equal(len(input), consumed)
+	})
+
+	t.Run("named fields into tuple", func(t *testing.T) {
+		assert := assert.New(t)
+		input := []byte{
+			0x01, 0x12, // len=18
+			0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
+			0x01, 0x03, // 3
+			0x41, 0x82, 0x04, 0x46, 0x6c, 0x61, 0x67, // "Flag"
+			0x01, // true
+		}
+		expect := testStructTuple{Value: 3, Flag: true}
+
+		var actual testStructTuple
+		consumed, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+		assert.Equal(len(input), consumed)
+	})
+
+	t.Run("tuple into non-tuple", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructTuple{Value: 3, Name: "Jade", Flag: true})
+
+		var actual testStructMultiMember
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("tuple into different field count", func(t *testing.T) {
+		assert := assert.New(t)
+		input := []byte{
+			0x41, 0x04, 0x05, // len=5, tuple
+			0x01, 0x02, // 2 fields
+			0x01, 0x03, // 3
+			0x00, // ""
+		}
+
+		var actual testStructTuple
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("tuple into reordered fields", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructTuple{Value: 3, Name: "Jade", Flag: true})
+
+		var actual testStructTupleReordered
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("tuple into swapped fields of the same type", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructTuplePoint{X: 1, Y: 2})
+
+		var actual testStructTuplePointSwapped
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+		assert.ErrorIs(err, ErrInvalidType)
+		assert.Equal(testStructTuplePointSwapped{}, actual)
+	})
+
+	t.Run("tuple into renamed fields of the same type", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructTuplePoint{X: 1, Y: 2})
+
+		var actual testStructTuplePointRenamed
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrMalformedData)
+	})
+
+	t.Run("tuple into same fields", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructTuplePoint{X: 1, Y: 2})
+
+		var actual testStructTuplePoint
+		_, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(testStructTuplePoint{X: 1, Y: 2}, actual)
+	})
+}
+
+type testStructOmitEmpty struct {
+	Name  string            `rezi:",omitempty"`
+	Tags  []string          `rezi:",omitempty"`
+	Attrs map[string]string `rezi:",omitempty"`
+	Ptr   *int              `rezi:",omitempty"`
+	Count int
+}
+
+func Test_Enc_Struct_OmitEmpty(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  interface{}
+		format Format
+		expect []byte
+	}{
+		{
+			name:  "omitempty fields are omitted when empty",
+			input: testStructOmitEmpty{Tags: []string{}, Attrs: map[string]string{}},
+			expect: []byte{
+				0x01, 0x09, // len=9
+
+				0x41, 0x82, 0x05, 0x43, 0x6f, 0x75, 0x6e, 0x74, // "Count"
+				0x00, // 0
+			},
+		},
+		{
+			name:  "omitempty fields are kept when not empty",
+			input: testStructOmitEmpty{Name: "Karkat"},
+			expect: []byte{
+				0x01, 0x19, // len=25
+
+				0x41, 0x82, 0x05, 0x43, 0x6f, 0x75, 0x6e, 0x74, // "Count"
+				0x00, // 0
+
+				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
+				0x41, 0x82, 0x06, 0x4b, 0x61, 0x72, 0x6b, 0x61, 0x74, // "Karkat"
+			},
+		},
+		{
+			name:   "format omits all empty fields",
+			input:  testStructMultiMember{Value: 0, Name: "Karkat"},
+			format: Format{OmitEmpty: true},
+			expect: []byte{
+				0x01, 0x10, // len=16
+
+				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
+				0x41, 0x82, 0x06, 0x4b, 0x61, 0x72, 0x6b, 0x61, 0x74, // "Karkat"
+			},
+		},
+		{
+			name:   "format omits every field",
+			input:  testStructMultiMember{},
+			format: Format{OmitEmpty: true},
+			expect: []byte{
+				0x00,
+			},
+		},
+		{
+			name:   "format does not omit tuple fields",
+			input:  testStructTuple{Value: 3},
+			format: Format{OmitEmpty: true},
+			expect: []byte{
+				0x41, 0x04, 0x0b, // len=11, tuple
+				0x01, 0x03, // 3 fields
+				0x04, 0x8a, 0x84, 0x30, 0x5f, // fingerprint
+				0x01, 0x03, // 3
+				0x00, // ""
+				0x00, // false
+			},
+		},
+		{
+			name:   "field-name table entry has only non-empty fields",
+			input:  testStructMultiMember{Value: 0, Name: "Karkat"},
+			format: Format{OmitEmpty: true, FieldNameTable: true},
+			expect: []byte{
+				0x41, 0x02, 0x13, // len=19, field-name table
+				0x80,       // define entry 0
+				0x01, 0x01, // 1 name
+				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
+				0x41, 0x82, 0x06, 0x4b, 0x61, 0x72, 0x6b, 0x61, 0x74, // "Karkat"
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := EncWithFormat(tc.input, &tc.format)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_Dec_Struct_OmitEmpty(t *testing.T) {
+	assert := assert.New(t)
+	input := MustEnc(testStructOmitEmpty{Count: 4})
+	expect := testStructOmitEmpty{Name: "Sollux", Count: 4}
+
+	actual := testStructOmitEmpty{Name: "Sollux", Count: 2}
+	consumed, err := Dec(input, &actual)
+	if !assert.NoError(err) {
+		return
+	}
+
+	assert.Equal(expect, actual)
+	assert.Equal(len(input), consumed)
+}
+
+type TestEmbedValue struct {
+	Value int
+}
+
+type testEmbedSecret struct {
+	Secret string
+}
+
+type testEmbedA struct {
+	A int
+	X int
+}
+
+type testEmbedB struct {
+	B int
+	X int
+}
+
+type testStructEmbedUnexported struct {
+	testEmbedSecret
+	Name string
+}
+
+type testStructEmbedPtr struct {
+	*TestEmbedValue
+	Name string
+}
+
+type testStructEmbedUnexportedPtr struct {
+	*testEmbedSecret
+	Name string
+}
+
+type testStructEmbedAmbiguous struct {
+	testEmbedA
+	testEmbedB
+}
+
+func Test_Enc_Struct_Embedded(t *testing.T) {
+	testCases := []struct {
+		name   string
+		input  interface{}
+		expect []byte
+	}{
+		{
+			name:  "unexported embedded struct",
+			input: testStructEmbedUnexported{testEmbedSecret: testEmbedSecret{Secret: "x"}, Name: "y"},
+			expect: []byte{
+				0x01, 0x18, // len=24
+
+				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
+				0x41, 0x82, 0x01, 0x79, // "y"
+
+				0x41, 0x82, 0x06, 0x53, 0x65, 0x63, 0x72, 0x65, 0x74, // "Secret"
+				0x41, 0x82, 0x01, 0x78, // "x"
+			},
+		},
+		{
+			name:  "pointer embedded struct",
+			input: testStructEmbedPtr{TestEmbedValue: &TestEmbedValue{Value: 4}, Name: "y"},
+			expect: []byte{
+				0x01, 0x15, // len=21
+
+				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
+				0x41, 0x82, 0x01, 0x79, // "y"
+
+				0x41, 0x82, 0x05, 0x56, 0x61, 0x6c, 0x75, 0x65, // "Value"
+				0x01, 0x04, // 4
+			},
+		},
+		{
+			name:  "nil pointer embedded struct",
+			input: testStructEmbedPtr{Name: "y"},
+			expect: []byte{
+				0x01, 0x0b, // len=11
+
+				0x41, 0x82, 0x04, 0x4e, 0x61, 0x6d, 0x65, // "Name"
+				0x41, 0x82, 0x01, 0x79, // "y"
+			},
+		},
+		{
+			name:  "ambiguous fields are dropped",
+			input: testStructEmbedAmbiguous{testEmbedA{A: 1, X: 2}, testEmbedB{B: 3, X: 4}},
+			expect: []byte{
+				0x01, 0x0c, // len=12
+
+				0x41, 0x82, 0x01, 0x41, // "A"
+				0x01, 0x01, // 1
+
+				0x41, 0x82, 0x01, 0x42, // "B"
+				0x01, 0x03, // 3
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := Enc(tc.input)
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_Dec_Struct_Embedded(t *testing.T) {
+	t.Run("unexported embedded struct", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testStructEmbedUnexported{testEmbedSecret: testEmbedSecret{Secret: "x"}, Name: "y"}
+
+		input := MustEnc(expect)
+
+		var actual testStructEmbedUnexported
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(input), n)
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("pointer embedded struct is allocated", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testStructEmbedPtr{TestEmbedValue: &TestEmbedValue{Value: 4}, Name: "y"}
+
+		var actual testStructEmbedPtr
+		_, err := Dec(MustEnc(expect), &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("pointer embedded struct absent from data", func(t *testing.T) {
+		assert := assert.New(t)
+		expect := testStructEmbedPtr{Name: "y"}
+
+		var actual testStructEmbedPtr
+		_, err := Dec(MustEnc(expect), &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+
+	t.Run("nil unexported pointer embedded struct", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(testStructEmbedUnexportedPtr{testEmbedSecret: &testEmbedSecret{Secret: "x"}, Name: "Sollux"})
+
+		var actual testStructEmbedUnexportedPtr
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("non-nil unexported pointer embedded struct", func(t *testing.T) {
+		assert := assert.New(t)
+		existing := &testEmbedSecret{Secret: "old"}
+		input := MustEnc(testStructEmbedUnexportedPtr{testEmbedSecret: &testEmbedSecret{Secret: "new"}})
+
+		actual := testStructEmbedUnexportedPtr{testEmbedSecret: existing}
+		_, err := DecWithFormat(input, &actual, &Format{Merge: MergeOverwrite})
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal("new", actual.Secret)
+	})
+
+	t.Run("nil unexported pointer embedded struct in slice", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc([]testStructEmbedUnexportedPtr{{testEmbedSecret: &testEmbedSecret{Secret: "x"}}})
+
+		var actual []testStructEmbedUnexportedPtr
+		_, err := Dec(input, &actual)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("ambiguous fields are dropped", func(t *testing.T) {
+		assert := assert.New(t)
+		input := testStructEmbedAmbiguous{testEmbedA{A: 1, X: 2}, testEmbedB{B: 3, X: 4}}
+		expect := testStructEmbedAmbiguous{testEmbedA{A: 1}, testEmbedB{B: 3}}
+
+		var actual testStructEmbedAmbiguous
+		_, err := Dec(MustEnc(input), &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(expect, actual)
+	})
+}
+
+func Test_Dec_Struct_FromMap(t *testing.T) {
+	t.Run("string-keyed map", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(map[string]int{"Value": 3})
+
+		var actual testStructMultiMember
+		n, err := Dec(input, &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(len(input), n)
+		assert.Equal(testStructMultiMember{Value: 3}, actual)
+	})
+
+	t.Run("key that is not a field", func(t *testing.T) {
+		assert := assert.New(t)
+		input := MustEnc(map[string]int{"Other": 3})
+
+		var actual testStructMultiMember
+		_, err := Dec(input, &actual)
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+}
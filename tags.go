@@ -0,0 +1,181 @@
+package rezi
+
+// tags.go contains functions for reading the rezi struct tags of fields.
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// fieldTag holds the options given in the rezi tag of a struct field. A rezi
+// tag is a comma-separated list whose first element is reserved and must be
+// empty, and whose remaining elements are options, such as `rezi:",packed"` or
+// `rezi:",id=3"`. Options that apply to the entire struct, such as tuple, are
+// given in the rezi tag of a blank field named _.
+type fieldTag struct {
+	// packing is the Packing given with the packed option, or PackNone if it
+	// was not given.
+	packing Packing
+
+	// id is the numeric ID given with the id option, or 0 if it was not
+	// given.
+	id int
+
+	// omitEmpty is whether the omitempty option was given.
+	omitEmpty bool
+
+	// required is whether the required option was given.
+	required bool
+
+	// defaultValue is the text given with the default option. It is only
+	// valid if hasDefault is set.
+	defaultValue string
+	hasDefault   bool
+
+	// tuple is whether the tuple option was given. It is only valid on a blank
+	// field, as it applies to the entire struct.
+	tuple bool
+
+	// was is the kind of number given with the was option, or numKindNone if
+	// it was not given.
+	was numKind
+}
+
+// parseFieldTag reads the rezi tag of the given struct field.
+func parseFieldTag(sf reflect.StructField) (fieldTag, error) {
+	var tag fieldTag
+
+	tagStr, ok := sf.Tag.Lookup("rezi")
+	if !ok {
+		return tag, nil
+	}
+
+	parts := strings.Split(tagStr, ",")
+	if parts[0] != "" {
+		return tag, errorf("rezi tag of field .%s must begin with a comma", sf.Name).wrap(ErrInvalidType)
+	}
+
+	for _, opt := range parts[1:] {
+		name, value, hasValue := strings.Cut(opt, "=")
+
+		switch name {
+		case "packed":
+			if !hasValue {
+				tag.packing = PackVarint
+				break
+			}
+			switch value {
+			case "fixed":
+				tag.packing = PackFixed
+			case "varint":
+				tag.packing = PackVarint
+			case "delta":
+				tag.packing = PackDelta
+			default:
+				return tag, errorf("rezi tag of field .%s: unknown packing %q", sf.Name, value).wrap(ErrInvalidType)
+			}
+		case "id":
+			id, err := strconv.Atoi(value)
+			if err != nil || id < 1 {
+				return tag, errorf("rezi tag of field .%s: id must be an integer greater than 0, not %q", sf.Name, value).wrap(ErrInvalidType)
+			}
+			tag.id = id
+		case "omitempty":
+			tag.omitEmpty = true
+		case "required":
+			tag.required = true
+		case "default":
+			if !hasValue {
+				return tag, errorf("rezi tag of field .%s: default option must give a value", sf.Name).wrap(ErrInvalidType)
+			}
+			tag.defaultValue = value
+			tag.hasDefault = true
+		case "tuple":
+			tag.tuple = true
+		case "was":
+			switch value {
+			case "int":
+				tag.was = numKindInt
+			case "uint":
+				tag.was = numKindUint
+			case "float":
+				tag.was = numKindFloat
+			default:
+				return tag, errorf("rezi tag of field .%s: was option must be int, uint, or float, not %q", sf.Name, value).wrap(ErrInvalidType)
+			}
+		case "":
+			// allow empty options such as from a trailing comma
+		default:
+			return tag, errorf("rezi tag of field .%s: unknown option %q", sf.Name, name).wrap(ErrInvalidType)
+		}
+	}
+
+	return tag, nil
+}
+
+// newFieldInfo reads the rezi tag of sf, which is the field at the given index
+// sequence of its struct, and returns the fieldInfo for it with the options in
+// the tag applied. info is the type info of the field's value.
+func newFieldInfo(index []int, sf reflect.StructField, info typeInfo) (fieldInfo, error) {
+	fi := fieldInfo{Index: index, Name: sf.Name}
+
+	tag, err := parseFieldTag(sf)
+	if err != nil {
+		return fi, err
+	}
+
+	if tag.tuple {
+		return fi, errorf("field .%s has tuple option but is not a blank field", sf.Name).wrap(ErrInvalidType)
+	}
+	if tag.packing != PackNone {
+		if !info.Packable() {
+			return fi, errorf("field .%s has packed option but is not a slice or array of integers or floats", sf.Name).wrap(ErrInvalidType)
+		}
+		info.Packing = tag.packing
+	}
+	if tag.was != numKindNone {
+		if numKindOf(info) == numKindNone {
+			return fi, errorf("field .%s has was option but is not an integer or float", sf.Name).wrap(ErrInvalidType)
+		}
+		info.Was = tag.was
+	}
+	if tag.omitEmpty && (tag.required || tag.hasDefault) {
+		// an omitted empty field would be absent when decoded, so it could
+		// never be decoded as the empty value it was encoded from.
+		return fi, errorf("field .%s has omitempty option with required or default option", sf.Name).wrap(ErrInvalidType)
+	}
+	if tag.hasDefault {
+		if tag.required {
+			return fi, errorf("field .%s has both required and default options", sf.Name).wrap(ErrInvalidType)
+		}
+		fi.Default, err = parseDefault(sf, tag.defaultValue)
+		if err != nil {
+			return fi, err
+		}
+	}
+
+	fi.ID = tag.id
+	fi.OmitEmpty = tag.omitEmpty
+	fi.Required = tag.required
+	fi.Type = info
+	return fi, nil
+}
+
+// applyStructTag reads the rezi tag of sf, which must be a blank field, and
+// gives the options in it to the fields of the struct that it is in.
+func applyStructTag(sf reflect.StructField, fs *fields) error {
+	tag, err := parseFieldTag(sf)
+	if err != nil {
+		return err
+	}
+
+	if tag.packing != PackNone || tag.id != 0 || tag.omitEmpty || tag.required || tag.hasDefault || tag.was != numKindNone {
+		return errorf("blank field can only have options that apply to the entire struct").wrap(ErrInvalidType)
+	}
+	if tag.tuple {
+		fs.Tuple = true
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package rezi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_parseFieldTag(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     reflect.StructTag
+		expect    fieldTag
+		expectErr bool
+	}{
+		{name: "no tag", input: ``, expect: fieldTag{}},
+		{name: "other tags only", input: `json:"name"`, expect: fieldTag{}},
+		{name: "empty rezi tag", input: `rezi:""`, expect: fieldTag{}},
+		{name: "packed", input: `rezi:",packed"`, expect: fieldTag{packing: PackVarint}},
+		{name: "packed fixed", input: `rezi:",packed=fixed"`, expect: fieldTag{packing: PackFixed}},
+		{name: "packed varint", input: `rezi:",packed=varint"`, expect: fieldTag{packing: PackVarint}},
+		{name: "packed delta", input: `json:"ids" rezi:",packed=delta"`, expect: fieldTag{packing: PackDelta}},
+		{name: "trailing comma", input: `rezi:",packed,"`, expect: fieldTag{packing: PackVarint}},
+		{name: "id", input: `rezi:",id=3"`, expect: fieldTag{id: 3}},
+		{name: "id and packed", input: `rezi:",id=12,packed=delta"`, expect: fieldTag{id: 12, packing: PackDelta}},
+		{name: "tuple", input: `rezi:",tuple"`, expect: fieldTag{tuple: true}},
+		{name: "omitempty", input: `rezi:",omitempty"`, expect: fieldTag{omitEmpty: true}},
+		{name: "required", input: `rezi:",required"`, expect: fieldTag{required: true}},
+		{name: "default", input: `rezi:",default=5"`, expect: fieldTag{defaultValue: "5", hasDefault: true}},
+		{name: "empty default", input: `rezi:",default="`, expect: fieldTag{hasDefault: true}},
+		{name: "was int", input: `rezi:",was=int"`, expect: fieldTag{was: numKindInt}},
+		{name: "was uint", input: `rezi:",was=uint"`, expect: fieldTag{was: numKindUint}},
+		{name: "was float", input: `rezi:",was=float"`, expect: fieldTag{was: numKindFloat}},
+		{name: "was unknown kind", input: `rezi:",was=string"`, expectErr: true},
+		{name: "default without value", input: `rezi:",default"`, expectErr: true},
+		{name: "unknown packing", input: `rezi:",packed=zip"`, expectErr: true},
+		{name: "id of 0", input: `rezi:",id=0"`, expectErr: true},
+		{name: "negative id", input: `rezi:",id=-1"`, expectErr: true},
+		{name: "non-numeric id", input: `rezi:",id=three"`, expectErr: true},
+		{name: "id without value", input: `rezi:",id"`, expectErr: true},
+		{name: "unknown option", input: `rezi:",shiny"`, expectErr: true},
+		{name: "name given", input: `rezi:"name"`, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			sf := reflect.StructField{Name: "Field", Tag: tc.input}
+
+			actual, err := parseFieldTag(sf)
+			if tc.expectErr {
+				assert.ErrorIs(err, ErrInvalidType)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_Enc_InvalidFieldTag(t *testing.T) {
+	t.Run("packed on non-numeric slice", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := struct {
+			Names []string `rezi:",packed"`
+		}{}
+
+		_, err := Enc(input)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("packed on non-slice", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := struct {
+			Value int `rezi:",packed"`
+		}{}
+
+		_, err := Enc(input)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("duplicate id", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := struct {
+			A int `rezi:",id=1"`
+			B int `rezi:",id=1"`
+		}{}
+
+		_, err := Enc(input)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("tuple on non-blank field", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := struct {
+			A int `rezi:",tuple"`
+		}{}
+
+		_, err := Enc(input)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("field option on blank field", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := struct {
+			_ []int `rezi:",packed"`
+			A int
+		}{}
+
+		_, err := Enc(input)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("tuple with id", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := struct {
+			_ struct{} `rezi:",tuple"`
+			A int      `rezi:",id=1"`
+		}{}
+
+		_, err := Enc(input)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("omitempty in tuple", func(t *testing.T) {
+		assert := assert.New(t)
+
+		input := struct {
+			_ struct{} `rezi:",tuple"`
+			A int      `rezi:",omitempty"`
+		}{}
+
+		_, err := Enc(input)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+
+	t.Run("decode with packed on non-numeric slice", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var dest struct {
+			Names []string `rezi:",packed"`
+		}
+
+		_, err := Dec([]byte{0x00}, &dest)
+
+		assert.ErrorIs(err, ErrInvalidType)
+	})
+}
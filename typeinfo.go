@@ -12,6 +12,8 @@ var (
 	refBinaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
 	refTextMarshalerType     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
 	refTextUnmarshalerType   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	refReziMarshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	refReziUnmarshalerType   = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
 
 	refPrimitiveKindTypes = map[reflect.Kind]reflect.Type{
 		reflect.Bool:       reflect.TypeOf(true),
@@ -49,6 +51,8 @@ const (
 	mtArray
 	mtText
 	mtStruct
+	mtRezi
+	mtRaw
 )
 
 func (mt mainType) String() string {
@@ -79,21 +83,234 @@ func (mt mainType) String() string {
 		return "mtText"
 	case mtStruct:
 		return "mtStruct"
+	case mtRezi:
+		return "mtRezi"
+	case mtRaw:
+		return "mtRaw"
 	default:
 		return fmt.Sprintf("mainType(%d)", mt)
 	}
 }
 
+// Unmarshaled returns whether mt is a type that is decoded by calling one of
+// its own methods, which may depend on its existing value.
+func (mt mainType) Unmarshaled() bool {
+	return mt == mtText || mt == mtBinary || mt == mtRezi
+}
+
 // fieldInfo holds REZI-specific type info on fieldds of a struct
 type fieldInfo struct {
 	Name  string
-	Index int // position in fields by index
+	Index []int // index sequence of the field, as for reflect.Value.FieldByIndex
+	ID    int   // numeric ID given by the field's rezi tag; 0 if none was given
 	Type  typeInfo
+
+	// OmitEmpty is whether the field is left out of the encoding when it is
+	// empty.
+	OmitEmpty bool
+
+	// Required is whether decoding fails if the field is absent from the data.
+	Required bool
+
+	// Default is the value that the field is set to if it is absent from the
+	// data. It is the zero Value if the field has no default.
+	Default reflect.Value
 }
 
 type fields struct {
 	ByName  map[string]fieldInfo
+	ByID    map[int]fieldInfo // only contains fields that have an ID
 	ByOrder []fieldInfo
+
+	// Embedded holds each embedded struct field whose fields are promoted, by
+	// the name of the embedded field. It is used to decode data in which such
+	// fields were encoded as a nested struct.
+	Embedded map[string]fieldInfo
+
+	// Defaulter is whether a pointer to the struct implements Defaulter. It is
+	// only set by decTypeInfo.
+	Defaulter bool
+
+	// Tuple is whether the struct is encoded as only the values of its fields,
+	// in the order they are declared. If set, ByOrder is in declaration order
+	// rather than sorted by name.
+	Tuple bool
+}
+
+// add adds fi to the fields. It returns an error if fi has the same ID as a
+// field already added.
+func (fs *fields) add(fi fieldInfo) error {
+	if fi.ID != 0 {
+		if other, ok := fs.ByID[fi.ID]; ok {
+			return errorf("fields .%s and .%s both have id %d", other.Name, fi.Name, fi.ID).wrap(ErrInvalidType)
+		}
+		if fs.ByID == nil {
+			fs.ByID = map[int]fieldInfo{}
+		}
+		fs.ByID[fi.ID] = fi
+	}
+	fs.ByName[fi.Name] = fi
+	fs.ByOrder = append(fs.ByOrder, fi)
+	return nil
+}
+
+// analyzeStructFields adds the fields of struct type t to fs. The exported
+// fields of embedded structs are promoted to be fields of t, following the
+// same rules as encoding/json: a promoted field is shadowed by any field of
+// the same name at a shallower depth, and fields of the same name at the same
+// depth shadow each other. analyze gives the type info of the type of each
+// field, and able is used in the message of errors it returns.
+func analyzeStructFields(t reflect.Type, fs *fields, analyze func(reflect.Type) (typeInfo, error), able string) error {
+	type embeddedStruct struct {
+		t     reflect.Type
+		index []int
+	}
+
+	// walk the embedded structs one depth at a time so that shallower fields
+	// are found first.
+	var candidates []fieldInfo
+	next := []embeddedStruct{{t: t}}
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+
+		// a struct embedded more than once at the same depth is walked each
+		// time, so that its fields shadow each other.
+		level := map[reflect.Type]bool{}
+		for _, es := range current {
+			if visited[es.t] {
+				continue
+			}
+			level[es.t] = true
+
+			for i := 0; i < es.t.NumField(); i++ {
+				sf := es.t.Field(i)
+				index := make([]int, len(es.index)+1)
+				copy(index, es.index)
+				index[len(es.index)] = i
+
+				if sf.Name == "_" {
+					if len(es.index) == 0 {
+						if err := applyStructTag(sf, fs); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+
+				if !sf.IsExported() && !sf.Anonymous {
+					continue
+				}
+
+				// unexported embedded fields of a type that isn't a struct
+				// have nothing to promote.
+				embeddedType := sf.Type
+				if embeddedType.Kind() == reflect.Pointer {
+					embeddedType = embeddedType.Elem()
+				}
+				if !sf.IsExported() && embeddedType.Kind() != reflect.Struct {
+					continue
+				}
+
+				fieldValInfo, err := analyze(sf.Type)
+				if err != nil {
+					return errorf("field .%s is not %s: %s", sf.Name, able, err)
+				}
+
+				// embedded structs have their fields promoted unless they
+				// encode themselves.
+				if sf.Anonymous && fieldValInfo.Main == mtStruct {
+					if _, tagged := sf.Tag.Lookup("rezi"); tagged {
+						return errorf("embedded field .%s has rezi tag but its fields are promoted", sf.Name).wrap(ErrInvalidType)
+					}
+					if len(es.index) == 0 {
+						if fs.Embedded == nil {
+							fs.Embedded = map[string]fieldInfo{}
+						}
+						fs.Embedded[sf.Name] = fieldInfo{Name: sf.Name, Index: index, Type: fieldValInfo}
+					}
+					next = append(next, embeddedStruct{t: embeddedType, index: index})
+					continue
+				}
+
+				fi, err := newFieldInfo(index, sf, fieldValInfo)
+				if err != nil {
+					return err
+				}
+				candidates = append(candidates, fi)
+			}
+		}
+
+		for lt := range level {
+			visited[lt] = true
+		}
+	}
+
+	// candidates are in order of depth, so the first of each name is at the
+	// shallowest depth it occurs at. it is only kept if no other candidate of
+	// the same name is at that depth.
+	depths := map[string]int{}
+	counts := map[string]int{}
+	for _, fi := range candidates {
+		if d, ok := depths[fi.Name]; ok && d < len(fi.Index) {
+			continue
+		}
+		depths[fi.Name] = len(fi.Index)
+		counts[fi.Name]++
+	}
+
+	var kept []fieldInfo
+	for _, fi := range candidates {
+		if depths[fi.Name] == len(fi.Index) && counts[fi.Name] == 1 {
+			kept = append(kept, fi)
+		}
+	}
+	sorting := &sortableFields{fields: kept}
+	sort.Sort(sorting)
+
+	for _, fi := range sorting.fields {
+		if err := fs.add(fi); err != nil {
+			return err
+		}
+	}
+
+	return fs.finish()
+}
+
+// finish puts ByOrder in its final order once all fields have been added and
+// checks that the options given for the struct are compatible with its fields.
+func (fs *fields) finish() error {
+	if fs.Tuple {
+		if fs.Numbered() {
+			return errorf("tuple struct cannot have fields with an id").wrap(ErrInvalidType)
+		}
+		for _, fi := range fs.ByOrder {
+			if fi.OmitEmpty {
+				return errorf("tuple struct cannot have omitempty field .%s", fi.Name).wrap(ErrInvalidType)
+			}
+		}
+		return nil
+	}
+	fs.ByOrder = sortFieldsByName(fs.ByOrder)
+	return nil
+}
+
+// within returns the fields that are within the embedded struct field at the
+// given index sequence.
+func (fs *fields) within(index []int) []fieldInfo {
+	var contained []fieldInfo
+	for _, fi := range fs.ByOrder {
+		if len(fi.Index) > len(index) && reflect.DeepEqual(fi.Index[:len(index)], index) {
+			contained = append(contained, fi)
+		}
+	}
+	return contained
+}
+
+// Numbered returns whether any of the fields has an ID.
+func (fs *fields) Numbered() bool {
+	return len(fs.ByID) > 0
 }
 
 // sortableFields can sort a slice of fieldInfo. select whether by Name or by
@@ -116,7 +333,15 @@ func (sf *sortableFields) Less(i, j int) bool {
 	if sf.alpha {
 		return f1.Name < f2.Name
 	}
-	return f1.Index < f2.Index
+	for k := range f1.Index {
+		if k >= len(f2.Index) {
+			return false
+		}
+		if f1.Index[k] != f2.Index[k] {
+			return f1.Index[k] < f2.Index[k]
+		}
+	}
+	return len(f1.Index) < len(f2.Index)
 }
 
 // Swap implements sort.Interface
@@ -142,27 +367,109 @@ type typeInfo struct {
 	Main       mainType
 	Bits       int
 	Signed     bool
-	Underlying bool      // can be valid for any type that has the Main one as an underlying. will never be valid for mtText or mtBinary.
+	Underlying bool      // can be valid for any type that has the Main one as an underlying. will never be valid for mtText, mtBinary, or mtRezi.
 	Indir      int       // Indir is number of times that the value is deref'd. Used for encoding of ptr-to types.
 	KeyType    *typeInfo // only valid for maps
 	ValType    *typeInfo // valid for map, slice, and array
 	Len        int       // only valid for array
 	Dec        bool      // whether the info is for a decoded value. if false, it's for an encoded one.
-	Fields     fields    // valid for struct only
+	Fields     *fields   // valid for struct only. shared by every typeInfo for the same struct type within one analysis so recursive types can be described.
+	Packing    Packing   // only valid for slice and array. set from the rezi tag of the struct field the type info is for, if any.
+	Was        numKind   // only valid for integers and floats. set from the rezi tag of the struct field the type info is for, if any.
 }
 
 func (ti typeInfo) Primitive() bool {
 	return ti.Main == mtIntegral || ti.Main == mtBool || ti.Main == mtString || ti.Main == mtBinary || ti.Main == mtFloat || ti.Main == mtComplex || ti.Main == mtText
 }
 
+// RawBytes returns whether ti describes a slice or array of bytes, which is
+// encoded as a single blob of raw bytes rather than as a sequence of
+// individually-encoded items.
+func (ti typeInfo) RawBytes() bool {
+	if ti.Main != mtSlice && ti.Main != mtArray {
+		return false
+	}
+	vt := ti.ValType
+	return vt.Main == mtIntegral && vt.Bits == 8 && !vt.Signed && vt.Indir == 0
+}
+
+// ValidMapKey returns whether the type described by ti can be used as the key
+// of an encoded map. This is true for all non-binary primitives, as well as for
+// structs and arrays made up entirely of valid map key types with no pointer
+// indirection in any of the members.
+func (ti typeInfo) ValidMapKey() bool {
+	switch ti.Main {
+	case mtBinary:
+		return false
+	case mtStruct:
+		for _, fi := range ti.Fields.ByOrder {
+			if fi.Type.Indir > 0 || !fi.Type.ValidMapKey() {
+				return false
+			}
+		}
+		return true
+	case mtArray:
+		return ti.ValType.Indir == 0 && ti.ValType.ValidMapKey()
+	default:
+		return ti.Primitive()
+	}
+}
+
+// OrderedMapKey returns whether the type described by ti has a natural ordering
+// that is used when sorting keys of an encoded map. Valid map key types that
+// are not naturally ordered are instead ordered by their encoded bytes.
+func (ti typeInfo) OrderedMapKey() bool {
+	if ti.Indir > 0 {
+		return false
+	}
+	return ti.Main == mtBool || ti.Main == mtIntegral || ti.Main == mtFloat || ti.Main == mtString
+}
+
+// Trackable returns whether values of the type described by ti are checked for
+// shared references when reference tracking is enabled. This is true for all
+// pointers as well as for maps and slices.
+func (ti typeInfo) Trackable() bool {
+	return ti.Indir > 0 || ti.Main == mtMap || ti.Main == mtSlice
+}
+
 func canEncode(v interface{}) (typeInfo, error) {
-	return encTypeInfo(reflect.TypeOf(v))
+	return encTypeInfo(reflect.TypeOf(v), nil)
+}
+
+// encStructFields gets the fields of the struct type t for encoding. seen is as
+// for encTypeInfo, and must not be nil.
+func encStructFields(t reflect.Type, seen map[reflect.Type]*fields) (*fields, error) {
+	fieldsData := &fields{ByName: map[string]fieldInfo{}}
+	seen[t] = fieldsData
+
+	analyze := func(ft reflect.Type) (typeInfo, error) { return encTypeInfo(ft, seen) }
+	if err := analyzeStructFields(t, fieldsData, analyze, "encodeable"); err != nil {
+		return nil, err
+	}
+	return fieldsData, nil
 }
 
-func encTypeInfo(t reflect.Type) (info typeInfo, err error) {
+// encStructTypeInfo gets the typeInfo for encoding values of the struct type t
+// by their fields, even if t implements a marshaling interface.
+func encStructTypeInfo(t reflect.Type) (typeInfo, error) {
+	fieldsData, err := encStructFields(t, map[reflect.Type]*fields{})
+	if err != nil {
+		return typeInfo{}, err
+	}
+	return typeInfo{Main: mtStruct, Fields: fieldsData}, nil
+}
+
+// encTypeInfo gets the typeInfo for encoding values of type t. seen holds the
+// fields of every struct type whose analysis has begun; it is used to stop
+// analysis of recursive types and may be nil when called from outside of
+// encTypeInfo.
+func encTypeInfo(t reflect.Type, seen map[reflect.Type]*fields) (info typeInfo, err error) {
 	if t == nil {
 		return typeInfo{Main: mtNil}, nil
 	}
+	if seen == nil {
+		seen = map[reflect.Type]*fields{}
+	}
 
 	origType := t
 
@@ -170,7 +477,25 @@ func encTypeInfo(t reflect.Type) (info typeInfo, err error) {
 	indirCount := 0
 
 	for trying {
-		if t.Implements(refBinaryMarshalerType) {
+		if t == refRawType {
+			return typeInfo{Indir: indirCount, Main: mtRaw}, nil
+		} else if t.Implements(refReziMarshalerType) {
+			// same checks as for binary below, but REZI marshalers take
+			// priority over all others.
+			if t.Kind() == reflect.Pointer {
+				_, definedOnValue := t.Elem().MethodByName("MarshalREZI")
+
+				// only consider it to be implementing if it is *not* defined
+				// on the value type.
+				if !definedOnValue {
+					return typeInfo{Indir: indirCount, Main: mtRezi}, nil
+				}
+
+				// implicit deref, wait for next pass
+			} else {
+				return typeInfo{Indir: indirCount, Main: mtRezi}, nil
+			}
+		} else if t.Implements(refBinaryMarshalerType) {
 			// does it actually implement it itself? or did we just get handed a
 			// ptr type and the pointed-to type defines a value receiver and Go
 			// is performing implicit deref to make it be defined on the ptr
@@ -258,27 +583,27 @@ func encTypeInfo(t reflect.Type) (info typeInfo, err error) {
 			mValType := t.Elem()
 			mKeyType := t.Key()
 
-			mValInfo, err := encTypeInfo(mValType)
+			mValInfo, err := encTypeInfo(mValType, seen)
 			if err != nil {
 				return typeInfo{}, errorf("map value type is not encodable: %s", err)
 			}
-			mKeyInfo, err := encTypeInfo(mKeyType)
+			mKeyInfo, err := encTypeInfo(mKeyType, seen)
 			if err != nil {
 				return typeInfo{}, errorf("map key type is not encodable: %s", err)
 			}
 
-			// maps in general are not supported; the key type MUST be comparable
-			// and with an ordering, which p much means we exclusively support
-			// non-binary primitives.
-			if !mKeyInfo.Primitive() || mKeyInfo.Main == mtBinary {
-				return typeInfo{}, errorf("map key type must be bool, string, float, int, or text-encodable type").wrap(ErrInvalidType)
+			// the key type MUST be comparable and have a deterministic ordering.
+			// Go guarantees the former; for the latter, keys that are not
+			// naturally ordered are ordered by their encoded bytes.
+			if !mKeyInfo.ValidMapKey() {
+				return typeInfo{}, errorf("map key type must be bool, string, float, int, text-encodable type, or struct or array of those").wrap(ErrInvalidType)
 			}
 
 			return typeInfo{Indir: indirCount, Main: mtMap, KeyType: &mKeyInfo, ValType: &mValInfo}, nil
 		case reflect.Slice:
 			// could be okay, but val type must be encodable
 			slValType := t.Elem()
-			slValInfo, err := encTypeInfo(slValType)
+			slValInfo, err := encTypeInfo(slValType, seen)
 			if err != nil {
 				return typeInfo{}, errorf("slice value is not encodable: %s", err)
 			}
@@ -286,31 +611,26 @@ func encTypeInfo(t reflect.Type) (info typeInfo, err error) {
 		case reflect.Array:
 			// could be okay, but val type must be encodable.
 			arrValType := t.Elem()
-			arrValInfo, err := encTypeInfo(arrValType)
+			arrValInfo, err := encTypeInfo(arrValType, seen)
 			if err != nil {
 				return typeInfo{}, errorf("array value is not encodable: %s", err)
 			}
 			arrLen := t.Len()
 			return typeInfo{Indir: indirCount, Main: mtArray, ValType: &arrValInfo, Len: arrLen}, nil
 		case reflect.Struct:
+			// if we are already in the middle of analyzing this struct type,
+			// this is a recursive reference to it; re-use the fields that are
+			// being built.
+			if fieldsData, ok := seen[t]; ok {
+				return typeInfo{Indir: indirCount, Main: mtStruct, Fields: fieldsData}, nil
+			}
+
 			// could be okay, but all exported fields must be encodable.
 			// check while building lists of fields
-			fieldsData := fields{ByName: map[string]fieldInfo{}}
-
-			for i := 0; i < t.NumField(); i++ {
-				sf := t.Field(i)
-				if !sf.IsExported() {
-					continue
-				}
-				fieldValInfo, err := encTypeInfo(sf.Type)
-				if err != nil {
-					return typeInfo{}, errorf("field .%s is not encodeable: %s", sf.Name, err)
-				}
-				fi := fieldInfo{Index: i, Name: sf.Name, Type: fieldValInfo}
-				fieldsData.ByName[fi.Name] = fi
-				fieldsData.ByOrder = append(fieldsData.ByOrder, fi)
+			fieldsData, err := encStructFields(t, seen)
+			if err != nil {
+				return typeInfo{}, err
 			}
-			fieldsData.ByOrder = sortFieldsByName(fieldsData.ByOrder)
 			return typeInfo{Indir: indirCount, Main: mtStruct, Fields: fieldsData}, nil
 		case reflect.Pointer:
 			// try removing one level of indrection and checking THAT
@@ -341,7 +661,7 @@ func canDecode(v interface{}) (typeInfo, error) {
 		checkType = checkType.Elem()
 	}
 
-	info, err := decTypeInfo(checkType)
+	info, err := decTypeInfo(checkType, nil)
 	if err != nil {
 		return info, err
 	}
@@ -349,8 +669,38 @@ func canDecode(v interface{}) (typeInfo, error) {
 	return info, nil
 }
 
-func decTypeInfo(t reflect.Type) (info typeInfo, err error) {
+// decStructFields gets the fields of the struct type t for decoding. seen is as
+// for decTypeInfo, and must not be nil.
+func decStructFields(t reflect.Type, seen map[reflect.Type]*fields) (*fields, error) {
+	fieldsData := &fields{ByName: map[string]fieldInfo{}, Defaulter: reflect.PointerTo(t).Implements(refDefaulterType)}
+	seen[t] = fieldsData
+
+	analyze := func(ft reflect.Type) (typeInfo, error) { return decTypeInfo(ft, seen) }
+	if err := analyzeStructFields(t, fieldsData, analyze, "decodeable"); err != nil {
+		return nil, err
+	}
+	return fieldsData, nil
+}
+
+// decStructTypeInfo gets the typeInfo for decoding values of the struct type t
+// by their fields, even if t implements an unmarshaling interface.
+func decStructTypeInfo(t reflect.Type) (typeInfo, error) {
+	fieldsData, err := decStructFields(t, map[reflect.Type]*fields{})
+	if err != nil {
+		return typeInfo{}, err
+	}
+	return typeInfo{Dec: true, Main: mtStruct, Fields: fieldsData}, nil
+}
+
+// decTypeInfo gets the typeInfo for decoding values of type t. seen holds the
+// fields of every struct type whose analysis has begun; it is used to stop
+// analysis of recursive types and may be nil when called from outside of
+// decTypeInfo.
+func decTypeInfo(t reflect.Type, seen map[reflect.Type]*fields) (info typeInfo, err error) {
 	origType := t
+	if seen == nil {
+		seen = map[reflect.Type]*fields{}
+	}
 
 	trying := true
 	indirCount := 0
@@ -358,7 +708,11 @@ func decTypeInfo(t reflect.Type) (info typeInfo, err error) {
 	for trying {
 		trying = false
 
-		if reflect.PointerTo(t).Implements(refBinaryUnmarshalerType) {
+		if t == refRawType {
+			return typeInfo{Dec: true, Indir: indirCount, Main: mtRaw}, nil
+		} else if reflect.PointerTo(t).Implements(refReziUnmarshalerType) {
+			return typeInfo{Dec: true, Indir: indirCount, Main: mtRezi}, nil
+		} else if reflect.PointerTo(t).Implements(refBinaryUnmarshalerType) {
 			return typeInfo{Dec: true, Indir: indirCount, Main: mtBinary}, nil
 		} else if reflect.PointerTo(t).Implements(refTextUnmarshalerType) {
 			return typeInfo{Dec: true, Indir: indirCount, Main: mtText}, nil
@@ -407,27 +761,27 @@ func decTypeInfo(t reflect.Type) (info typeInfo, err error) {
 			mValType := t.Elem()
 			mKeyType := t.Key()
 
-			mValInfo, err := decTypeInfo(mValType)
+			mValInfo, err := decTypeInfo(mValType, seen)
 			if err != nil {
 				return typeInfo{}, errorf("map value type is not decodable: %s", err)
 			}
-			mKeyInfo, err := decTypeInfo(mKeyType)
+			mKeyInfo, err := decTypeInfo(mKeyType, seen)
 			if err != nil {
 				return typeInfo{}, errorf("map key type is not decodable: %s", err)
 			}
 
-			// maps in general are not supported; the key type MUST be comparable
-			// and with an ordering, which p much means we exclusively support
-			// non-binary primitives.
-			if !mKeyInfo.Primitive() || mKeyInfo.Main == mtBinary {
-				return typeInfo{}, errorf("map key type must be bool, string, float, int, or text-encodable type").wrap(ErrInvalidType)
+			// the key type MUST be comparable and have a deterministic ordering.
+			// Go guarantees the former; for the latter, keys that are not
+			// naturally ordered are ordered by their encoded bytes.
+			if !mKeyInfo.ValidMapKey() {
+				return typeInfo{}, errorf("map key type must be bool, string, float, int, text-encodable type, or struct or array of those").wrap(ErrInvalidType)
 			}
 
 			return typeInfo{Dec: true, Indir: indirCount, Underlying: under, Main: mtMap, KeyType: &mKeyInfo, ValType: &mValInfo}, nil
 		case reflect.Slice:
 			// could be okay, but val type must be encodable
 			slValType := t.Elem()
-			slValInfo, err := decTypeInfo(slValType)
+			slValInfo, err := decTypeInfo(slValType, seen)
 			if err != nil {
 				return typeInfo{}, errorf("slice value is not decodable: %s", err)
 			}
@@ -435,31 +789,26 @@ func decTypeInfo(t reflect.Type) (info typeInfo, err error) {
 		case reflect.Array:
 			// could be okay, but val type must be encodable
 			arrValType := t.Elem()
-			arrValInfo, err := decTypeInfo(arrValType)
+			arrValInfo, err := decTypeInfo(arrValType, seen)
 			if err != nil {
 				return typeInfo{}, errorf("array value is not decodable: %s", err)
 			}
 			arrLen := t.Len()
 			return typeInfo{Dec: true, Indir: indirCount, Underlying: under, Main: mtArray, ValType: &arrValInfo, Len: arrLen}, nil
 		case reflect.Struct:
+			// if we are already in the middle of analyzing this struct type,
+			// this is a recursive reference to it; re-use the fields that are
+			// being built.
+			if fieldsData, ok := seen[t]; ok {
+				return typeInfo{Dec: true, Indir: indirCount, Main: mtStruct, Fields: fieldsData}, nil
+			}
+
 			// could be okay, but all exported fields must be encodable.
 			// check while building lists of fields
-			fieldsData := fields{ByName: map[string]fieldInfo{}}
-
-			for i := 0; i < t.NumField(); i++ {
-				sf := t.Field(i)
-				if !sf.IsExported() {
-					continue
-				}
-				fieldValInfo, err := decTypeInfo(sf.Type)
-				if err != nil {
-					return typeInfo{}, errorf("field .%s is not decodeable: %s", sf.Name, err)
-				}
-				fi := fieldInfo{Index: i, Name: sf.Name, Type: fieldValInfo}
-				fieldsData.ByName[fi.Name] = fi
-				fieldsData.ByOrder = append(fieldsData.ByOrder, fi)
+			fieldsData, err := decStructFields(t, seen)
+			if err != nil {
+				return typeInfo{}, err
 			}
-			fieldsData.ByOrder = sortFieldsByName(fieldsData.ByOrder)
 			// doesn't make sense to set Underlying for a struct; it will ALWAYS be the 'underlying' type.
 			return typeInfo{Dec: true, Indir: indirCount, Main: mtStruct, Fields: fieldsData}, nil
 		case reflect.Pointer:
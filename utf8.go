@@ -0,0 +1,110 @@
+package rezi
+
+// utf8.go contains functions for handling strings that are not valid UTF-8.
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// UTF8Policy is how strings that are not valid UTF-8 are handled when they are
+// encoded and decoded. It is selected with [Format.InvalidUTF8].
+//
+// The policy applies to every string, including the text of values that
+// implement encoding.TextMarshaler and encoding.TextUnmarshaler, but not to the
+// names of struct fields.
+type UTF8Policy int
+
+const (
+	// UTF8Preserve encodes and decodes the bytes of every string exactly as
+	// they are, whether or not they are valid UTF-8. A string holding
+	// arbitrary binary data has the same bytes after it is decoded as it did
+	// when it was encoded. It is the default.
+	UTF8Preserve UTF8Policy = iota
+
+	// UTF8Replace replaces each byte of every invalid UTF-8 sequence with the
+	// Unicode replacement character U+FFFD, as ranging over the string would.
+	UTF8Replace
+
+	// UTF8Reject gives an error for any string that is not valid UTF-8.
+	UTF8Reject
+)
+
+// String returns the name of the UTF8Policy.
+func (p UTF8Policy) String() string {
+	switch p {
+	case UTF8Preserve:
+		return "preserve"
+	case UTF8Replace:
+		return "replace"
+	case UTF8Reject:
+		return "reject"
+	default:
+		return fmt.Sprintf("UTF8Policy(%d)", int(p))
+	}
+}
+
+// invalidUTF8 returns the UTF8Policy to use for strings.
+func (s *session) invalidUTF8() UTF8Policy {
+	if s == nil {
+		return UTF8Preserve
+	}
+	return s.f.InvalidUTF8
+}
+
+// applyUTF8Policy returns s as it is to be encoded or decoded under policy. If
+// policy is UTF8Reject and s is not valid UTF-8, ok is false and invalidAt is
+// the index of the first byte in s that is not part of a valid UTF-8 sequence.
+func applyUTF8Policy(s string, policy UTF8Policy) (result string, invalidAt int, ok bool) {
+	switch policy {
+	case UTF8Replace:
+		if utf8.ValidString(s) {
+			return s, 0, true
+		}
+		var sb strings.Builder
+		for _, ch := range s {
+			sb.WriteRune(ch)
+		}
+		return sb.String(), 0, true
+	case UTF8Reject:
+		for i := 0; i < len(s); {
+			ch, size := utf8.DecodeRuneInString(s[i:])
+			if ch == utf8.RuneError && size == 1 {
+				return "", i, false
+			}
+			i += size
+		}
+		return s, 0, true
+	default:
+		return s, 0, true
+	}
+}
+
+// encCheckedString encodes a string after applying the UTF8Policy of the
+// session to it.
+func encCheckedString(value analyzed[string]) ([]byte, error) {
+	s, invalidAt, ok := applyUTF8Policy(value.v, value.sess.invalidUTF8())
+	if !ok {
+		return nil, errorf("invalid UTF-8 encoding in string at byte %d", invalidAt).wrap(ErrInvalidUTF8)
+	}
+	return encString(analyzed[string]{v: s}), nil
+}
+
+// decCheckedString decodes a string of any version and applies policy to it.
+func decCheckedString(data []byte, policy UTF8Policy) (decoded[string], error) {
+	dec, err := decString(data)
+	if err != nil {
+		return dec, err
+	}
+
+	s, invalidAt, ok := applyUTF8Policy(dec.v, policy)
+	if !ok {
+		// the bytes of the string are always the last ones read
+		offset := dec.n - len(dec.v) + invalidAt
+		err := errorDecf(offset, "invalid UTF-8 encoding in string").wrap(ErrMalformedData, ErrInvalidUTF8)
+		return d("", 0), err
+	}
+	dec.v = s
+	return dec, nil
+}
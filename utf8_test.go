@@ -0,0 +1,253 @@
+package rezi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_UTF8Policy_String(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("preserve", UTF8Preserve.String())
+	assert.Equal("replace", UTF8Replace.String())
+	assert.Equal("reject", UTF8Reject.String())
+	assert.Equal("UTF8Policy(3)", UTF8Policy(3).String())
+}
+
+func Test_EncWithFormat_InvalidUTF8(t *testing.T) {
+	testCases := []struct {
+		name      string
+		input     string
+		policy    UTF8Policy
+		expect    []byte
+		expectErr bool
+	}{
+		{
+			name:   "preserve valid",
+			input:  "Vriska",
+			policy: UTF8Preserve,
+			expect: []byte{0x41, 0x82, 0x06, 0x56, 0x72, 0x69, 0x73, 0x6b, 0x61},
+		},
+		{
+			name:   "preserve invalid",
+			input:  "A\xc3\x28\xff",
+			policy: UTF8Preserve,
+			expect: []byte{0x41, 0x82, 0x04, 0x41, 0xc3, 0x28, 0xff},
+		},
+		{
+			name:   "preserve replacement character",
+			input:  "�",
+			policy: UTF8Preserve,
+			expect: []byte{0x41, 0x82, 0x03, 0xef, 0xbf, 0xbd},
+		},
+		{
+			name:   "replace valid",
+			input:  "Vriska",
+			policy: UTF8Replace,
+			expect: []byte{0x41, 0x82, 0x06, 0x56, 0x72, 0x69, 0x73, 0x6b, 0x61},
+		},
+		{
+			name:   "replace invalid",
+			input:  "A\xc3\x28\xff",
+			policy: UTF8Replace,
+			expect: []byte{
+				0x41, 0x82, 0x08, // len=8
+
+				0x41,             // "A"
+				0xef, 0xbf, 0xbd, // U+FFFD
+				0x28,             // "("
+				0xef, 0xbf, 0xbd, // U+FFFD
+			},
+		},
+		{
+			name:   "reject valid",
+			input:  "Vriska",
+			policy: UTF8Reject,
+			expect: []byte{0x41, 0x82, 0x06, 0x56, 0x72, 0x69, 0x73, 0x6b, 0x61},
+		},
+		{
+			name:      "reject invalid",
+			input:     "A\xc3\x28\xff",
+			policy:    UTF8Reject,
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			actual, err := EncWithFormat(tc.input, &Format{InvalidUTF8: tc.policy})
+			if tc.expectErr {
+				assert.ErrorIs(err, ErrInvalidUTF8)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_DecWithFormat_InvalidUTF8(t *testing.T) {
+	testCases := []struct {
+		name         string
+		input        []byte
+		policy       UTF8Policy
+		expect       string
+		expectErr    bool
+		expectOffset int
+	}{
+		{
+			name:   "preserve invalid (v1)",
+			input:  []byte{0x01, 0x02, 0x41, 0xc3},
+			policy: UTF8Preserve,
+			expect: "A\xc3",
+		},
+		{
+			name:   "preserve invalid (v2)",
+			input:  []byte{0x41, 0x80, 0x04, 0x41, 0xc3, 0x28, 0xff},
+			policy: UTF8Preserve,
+			expect: "A\xc3\x28\xff",
+		},
+		{
+			name:   "preserve replacement character",
+			input:  []byte{0x41, 0x80, 0x03, 0xef, 0xbf, 0xbd},
+			policy: UTF8Preserve,
+			expect: "�",
+		},
+		{
+			name:   "replace invalid (v1)",
+			input:  []byte{0x01, 0x02, 0x41, 0xc3},
+			policy: UTF8Replace,
+			expect: "A�",
+		},
+		{
+			name:   "replace invalid (v2)",
+			input:  []byte{0x41, 0x80, 0x04, 0x41, 0xc3, 0x28, 0xff},
+			policy: UTF8Replace,
+			expect: "A�(�",
+		},
+		{
+			name:   "reject valid",
+			input:  []byte{0x41, 0x80, 0x03, 0xef, 0xbf, 0xbd},
+			policy: UTF8Reject,
+			expect: "�",
+		},
+		{
+			name:         "reject invalid (v1)",
+			input:        []byte{0x01, 0x02, 0x41, 0xc3},
+			policy:       UTF8Reject,
+			expectErr:    true,
+			expectOffset: 3,
+		},
+		{
+			name:         "reject invalid (v2)",
+			input:        []byte{0x41, 0x80, 0x04, 0x41, 0xc3, 0x28, 0xff},
+			policy:       UTF8Reject,
+			expectErr:    true,
+			expectOffset: 4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			var actual string
+			n, err := DecWithFormat(tc.input, &actual, &Format{InvalidUTF8: tc.policy})
+			if tc.expectErr {
+				assert.ErrorIs(err, ErrInvalidUTF8)
+				assert.ErrorIs(err, ErrMalformedData)
+
+				rErr, ok := err.(reziError)
+				if !assert.True(ok) {
+					return
+				}
+				offset, _ := rErr.totalOffset()
+				assert.Equal(tc.expectOffset, offset)
+				return
+			}
+			if !assert.NoError(err) {
+				return
+			}
+
+			assert.Equal(len(tc.input), n)
+			assert.Equal(tc.expect, actual)
+		})
+	}
+}
+
+func Test_InvalidUTF8_RoundTrip(t *testing.T) {
+	binary := string([]byte{0x00, 0xff, 0xfe, 0x80, 0xc3, 0x28, 0xed, 0xa0, 0x80})
+
+	t.Run("string", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var actual string
+		_, err := Dec(MustEnc(binary), &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(binary, actual)
+		assert.Len(actual, len(binary))
+	})
+
+	t.Run("map key and value", func(t *testing.T) {
+		assert := assert.New(t)
+		input := map[string]string{binary: binary}
+
+		var actual map[string]string
+		_, err := Dec(MustEnc(input), &actual)
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(input, actual)
+	})
+
+	t.Run("Writer and Reader", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, nil)
+		if !assert.NoError(err) {
+			return
+		}
+		if !assert.NoError(w.EncString(binary)) {
+			return
+		}
+		if !assert.NoError(w.Close()) {
+			return
+		}
+
+		r, err := NewReader(&buf, nil)
+		if !assert.NoError(err) {
+			return
+		}
+		actual, err := r.DecString()
+		if !assert.NoError(err) {
+			return
+		}
+
+		assert.Equal(binary, actual)
+	})
+
+	t.Run("Writer rejects", func(t *testing.T) {
+		assert := assert.New(t)
+
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, &Format{InvalidUTF8: UTF8Reject})
+		if !assert.NoError(err) {
+			return
+		}
+
+		err = w.EncString(binary)
+		assert.ErrorIs(err, ErrInvalidUTF8)
+	})
+}